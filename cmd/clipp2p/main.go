@@ -2,17 +2,129 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/owenHochwald/clipp2p/internal/app"
+	"github.com/owenHochwald/clipp2p/internal/clipboard"
+	"github.com/owenHochwald/clipp2p/internal/inspect"
 )
 
 func main() {
+	cfg := app.DefaultConfig()
+
+	backends := append([]string{app.BackendAuto, app.BackendSystem, app.BackendCommand, app.BackendOSC52, app.BackendFile}, clipboard.CommandPresetNames()...)
+	flag.StringVar(&cfg.PeerName, "name", cfg.PeerName, "name shown to other peers")
+	flag.StringVar(&cfg.Backend, "backend", cfg.Backend, "clipboard backend: "+strings.Join(backends, ", "))
+	flag.StringVar(&cfg.ReadCommand, "read-cmd", cfg.ReadCommand, "command printing the clipboard, for -backend=command")
+	flag.StringVar(&cfg.WriteCommand, "write-cmd", cfg.WriteCommand, "command reading new clipboard content from stdin, for -backend=command")
+	flag.DurationVar(&cfg.CommandTimeout, "cmd-timeout", cfg.CommandTimeout, "timeout for clipboard commands")
+	flag.StringVar(&cfg.FilePath, "file", cfg.FilePath, "file holding the clipboard, for -backend=file")
+	flag.BoolVar(&cfg.OSC52Query, "osc52-query", cfg.OSC52Query, "let -backend=osc52 query the terminal clipboard (write-only otherwise)")
+	flag.BoolVar(&cfg.SyncPrimary, "primary", cfg.SyncPrimary, "also sync the PRIMARY selection where available")
+	flag.DurationVar(&cfg.ClearAfter, "clear-after", cfg.ClearAfter, "remove received clips from the clipboard after this long (0 keeps them)")
+	flag.BoolVar(&cfg.ClearRestores, "clear-restore", cfg.ClearRestores, "restore the previous clipboard when a received clip expires, instead of clearing it")
+	flag.DurationVar(&cfg.SendTTL, "send-ttl", cfg.SendTTL, "ask peers to remove clips you send after this long (0 never)")
+	flag.Int64Var(&cfg.MaxClipSize, "max-clip-size", cfg.MaxClipSize, "largest clip in bytes to send or accept")
+	flag.BoolVar(&cfg.SyncConcealed, "sync-concealed", cfg.SyncConcealed, "also sync content password managers mark as secret")
+	flag.Func("secret-policy", "set a secret detector's policy, e.g. high-entropy=allow (repeatable)", func(value string) error {
+		detector, policy, ok := strings.Cut(value, "=")
+		if !ok {
+			return fmt.Errorf("want detector=policy, got %q", value)
+		}
+		p, err := inspect.ParsePolicy(policy)
+		if err != nil {
+			return err
+		}
+		cfg.SecretPolicies[detector] = p
+		return nil
+	})
+	flag.DurationVar(&cfg.ForgetPeersAfter, "forget-after", cfg.ForgetPeersAfter, "forget known peers not seen for this long (0 keeps them)")
+	flag.IntVar(&cfg.ListenPort, "port", cfg.ListenPort, "port to listen on, so others can bootstrap from this device (0 picks one)")
+	flag.Func("transports", "comma separated transports to listen on: tcp, quic, ws (on -port + 1) (default tcp)", func(value string) error {
+		cfg.Transports = strings.Split(value, ",")
+		return nil
+	})
+	flag.Func("peer", "dial this multiaddr, ending in /p2p/<peer-id>, and keep it connected (repeatable)", func(value string) error {
+		cfg.Bootstrap = append(cfg.Bootstrap, value)
+		return nil
+	})
+	flag.Func("relay", "reach peers on other networks through this circuit relay, a multiaddr ending in /p2p/<peer-id> (repeatable)", func(value string) error {
+		cfg.Relays = append(cfg.Relays, value)
+		return nil
+	})
+	flag.BoolVar(&cfg.RelayUnlimited, "relay-unlimited", cfg.RelayUnlimited, "in relay mode, don't limit relayed connections in time or data (only where just your devices can reach the relay)")
+	flag.IntVar(&cfg.OutboxSize, "outbox", cfg.OutboxSize, "keep this many of the latest clips for each known peer that is offline, sent when it reconnects (0 disables)")
+	flag.Func("queued-clips", "what clips queued for this device while it was offline do: overwrite the clipboard, or history to only list them (default overwrite)", func(value string) error {
+		if value != app.QueuedOverwrite && value != app.QueuedHistory {
+			return fmt.Errorf("want %s or %s, got %q", app.QueuedOverwrite, app.QueuedHistory, value)
+		}
+		cfg.QueuedClips = value
+		return nil
+	})
+	flag.StringVar(&cfg.GroupInvite, "join", cfg.GroupInvite, "join the private group of this invite, see the group command")
+	flag.BoolVar(&cfg.RequirePairing, "require-pairing", cfg.RequirePairing, "only sync with devices paired with a code")
+	flag.StringVar(&cfg.DataDir, "data-dir", cfg.DataDir, "directory for the identity key and other state")
+	logFile := flag.String("log", "", "write logs to this file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [command]\n\nCommands:\n  doctor                    show which clipboard backends work here\n  relay                     run only a relay for peers behind NATs, on -port or %d\n  %s\n  %s\n  %s\n\nFlags:\n", os.Args[0], app.DefaultRelayPort, app.IdentityUsage, app.TrustedUsage, app.GroupUsage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	switch flag.Arg(0) {
+	case "":
+	case "doctor":
+		app.Diagnose(os.Stdout)
+		return
+	case "identity":
+		if err := app.Identity(os.Stdout, cfg, flag.Args()[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "identity: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "relay":
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		if err := app.Relay(ctx, os.Stdout, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "relay: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "group":
+		if err := app.Group(os.Stdout, cfg, flag.Args()[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "group: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "trusted":
+		if err := app.Trusted(os.Stdout, cfg, flag.Args()[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "trusted: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *logFile != "" {
+		f, err := tea.LogToFile(*logFile, "clipp2p")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open log file: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -23,7 +135,6 @@ func main() {
 		cancel()
 	}()
 
-	cfg := app.DefaultConfig()
 	application := app.New(cfg)
 
 	// Start the app
@@ -33,6 +144,11 @@ func main() {
 	}
 	defer application.Stop()
 
+	// Keep stray log output from corrupting the TUI
+	if *logFile == "" {
+		log.SetOutput(io.Discard)
+	}
+
 	model := application.GetModel()
 	p := tea.NewProgram(model, tea.WithAltScreen())
 
@@ -1,33 +1,135 @@
 package app
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"log"
 	"os"
 	"sync"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 
 	"github.com/owenHochwald/clipp2p/internal/clipboard"
+	"github.com/owenHochwald/clipp2p/internal/datadir"
+	"github.com/owenHochwald/clipp2p/internal/identity"
+	"github.com/owenHochwald/clipp2p/internal/inspect"
+	"github.com/owenHochwald/clipp2p/internal/knownpeers"
+	"github.com/owenHochwald/clipp2p/internal/outbox"
 	"github.com/owenHochwald/clipp2p/internal/p2p"
+	"github.com/owenHochwald/clipp2p/internal/pairing"
 	"github.com/owenHochwald/clipp2p/internal/ui"
 )
 
 type Config struct {
 	PeerName     string
 	PollInterval time.Duration
+	WatchMode    clipboard.WatchMode
+	// SyncPrimary also syncs the X11 PRIMARY selection where available
+	SyncPrimary bool
+
+	// Backend selects the clipboard implementation: BackendAuto,
+	// BackendSystem, BackendCommand with ReadCommand/WriteCommand,
+	// or a command preset
+	Backend        string
+	ReadCommand    string
+	WriteCommand   string
+	CommandTimeout time.Duration
+	// OSC52Query lets the OSC 52 backend read the terminal clipboard.
+	// The answer competes with the TUI for terminal input.
+	OSC52Query bool
+	// FilePath is the file used by the file backend
+	FilePath string
+
+	// SecretPolicies decides, per detector, what happens to copied
+	// secrets before they are broadcast
+	SecretPolicies map[string]inspect.Policy
+	// SyncConcealed also syncs content password managers mark as secret
+	SyncConcealed bool
+
+	// ClearAfter removes received clips from the clipboard after this
+	// long, zero keeps them. ClearRestores puts back what was there
+	// before instead of leaving the clipboard empty.
+	ClearAfter    time.Duration
+	ClearRestores bool
+	// SendTTL marks outgoing clips as ephemeral, receivers remove them
+	// after this long
+	SendTTL time.Duration
+
+	// MaxClipSize is the largest clip in bytes that is sent or accepted.
+	// Larger clips stay local or are refused before anything is read.
+	MaxClipSize int64
+
+	// DataDir holds state kept across restarts, such as the identity key
+	DataDir string
+
+	// GroupInvite joins the private group it encodes, replacing the group
+	// kept in DataDir. In a group only members are discovered and can
+	// connect.
+	GroupInvite string
+
+	// ForgetPeersAfter drops known peers not seen for this long, zero
+	// keeps them forever
+	ForgetPeersAfter time.Duration
+
+	// ListenPort fixes the port, so Bootstrap addresses of this device stay
+	// valid across restarts. Zero picks a free port.
+	ListenPort int
+	// Transports to listen on: p2p.TransportTCP, TransportQUIC and
+	// TransportWebSocket, which uses ListenPort+1
+	Transports []string
+	// Bootstrap lists multiaddrs ending in /p2p/<peer-id> that are dialed
+	// and kept connected, for networks mDNS doesn't reach
+	Bootstrap []string
+
+	// Relays lists circuit relays, multiaddrs ending in /p2p/<peer-id>,
+	// that peers outside the local network reach this device through
+	Relays []string
+	// RelayUnlimited lifts the limits on connections relayed in relay mode
+	RelayUnlimited bool
+
+	// RequirePairing only syncs with devices paired with a code, other
+	// peers are turned away when they connect
+	RequirePairing bool
+
+	// OutboxSize is how many of the latest clips are kept for each known
+	// peer that missed them and sent when it reconnects, zero disables it
+	OutboxSize int
+	// QueuedClips decides what clips queued for this device while it was
+	// offline do: QueuedOverwrite or QueuedHistory
+	QueuedClips string
 }
 
+// greetTimeout bounds the hello exchange with a new peer
+const greetTimeout = 10 * time.Second
+
+// notSyncedTooLarge is shown in the history for clips over MaxClipSize
+const notSyncedTooLarge = "too large"
+
 // DefaultConfig returns sensible defaults
 func DefaultConfig() Config {
 	hostname, _ := os.Hostname()
 	if hostname == "" {
 		hostname = "ClipP2P"
 	}
+	dataDir, _ := datadir.Default()
 	return Config{
-		PeerName:     hostname,
-		PollInterval: 500 * time.Millisecond,
+		PeerName:         hostname,
+		DataDir:          dataDir,
+		PollInterval:     500 * time.Millisecond,
+		WatchMode:        clipboard.WatchAuto,
+		Backend:          BackendAuto,
+		CommandTimeout:   clipboard.DefaultCommandTimeout,
+		SecretPolicies:   inspect.DefaultPolicies(),
+		ClearRestores:    true,
+		MaxClipSize:      p2p.DefaultMaxClipSize,
+		RequirePairing:   true,
+		ForgetPeersAfter: 30 * 24 * time.Hour,
+		OutboxSize:       1,
+		QueuedClips:      QueuedOverwrite,
 	}
 }
 
@@ -36,44 +138,160 @@ type App struct {
 	config        Config
 	ctx           context.Context
 	cancel        context.CancelFunc
-	clipboard     clipboard.Clipboard
-	watcher       *clipboard.Watcher
+	selections    map[clipboard.Selection]*selectionSync
 	node          *p2p.Node
 	discovery     *p2p.Discovery
+	bootstrap     *p2p.Bootstrapper
 	streamHandler *p2p.StreamHandler
+	inspector     *inspect.Inspector
 	program       *tea.Program
 	model         ui.Model
+	trust         *pairing.TrustStore
+	known         *knownpeers.Store
+	outbox        *outbox.Store
+	pairing       *p2p.Pairing
+
+	mu            sync.Mutex
+	pendingSecret *pendingClip
+	nextPromptID  uint64
+	nextExpiryID  uint64
 
-	mu              sync.Mutex
-	lastClipContent string
-	ignoreNextClip  bool
+	pairingRequests map[peer.ID]time.Time
+	pairingTimer    *time.Timer
+
+	// flushing holds the peers whose outbox is being sent
+	flushing map[peer.ID]bool
 }
 
 func New(cfg Config) *App {
-	return &App{
-		config: cfg,
-		model:  ui.NewModel(cfg.PeerName),
+	a := &App{
+		config:          cfg,
+		model:           ui.NewModel(cfg.PeerName),
+		selections:      make(map[clipboard.Selection]*selectionSync),
+		inspector:       inspect.NewInspector(inspect.DefaultDetectors(), cfg.SecretPolicies),
+		pairingRequests: make(map[peer.ID]time.Time),
+		flushing:        make(map[peer.ID]bool),
+	}
+
+	// Answers arrive on the TUI goroutine, don't broadcast from there
+	a.model.OnSecretDecision = func(id uint64, send bool) {
+		go a.resolveSecret(id, send)
+	}
+	if cfg.RequirePairing {
+		a.model.OnPairingOpen = func() { go a.openPairing() }
+		a.model.OnPairingClose = func() { go a.closePairing() }
+		a.model.OnPair = func(id peer.ID, code string) { go a.pair(id, code) }
 	}
+	a.model.OnAddPeer = func(addr string) { go a.addPeer(addr) }
+	a.model.OnAddPeerOpen = func() { go a.sendListenAddrs() }
+
+	return a
 }
 
 func (a *App) Start(ctx context.Context) error {
 	a.ctx, a.cancel = context.WithCancel(ctx)
 
 	// Initialize clipboard
-	cb, err := clipboard.NewSystemClipboard()
+	backend, err := newClipboard(a.config)
 	if err != nil {
 		return err
 	}
-	a.clipboard = cb
+	a.selections[clipboard.SelectionClipboard] = &selectionSync{
+		selection: clipboard.SelectionClipboard,
+		clipboard: backend.Clipboard,
+	}
+	a.model.Backend = backend.Name
+
+	// PRIMARY is best effort, most platforms don't have it
+	if a.config.SyncPrimary {
+		if primary, err := clipboard.NewPrimaryClipboard(); err == nil {
+			a.selections[clipboard.SelectionPrimary] = &selectionSync{
+				selection: clipboard.SelectionPrimary,
+				clipboard: primary,
+			}
+		}
+	}
+
+	// A stable identity lets peers recognise this device across restarts
+	id, err := identity.LoadOrCreate(a.config.DataDir)
+	if err != nil {
+		return fmt.Errorf("failed to load identity: %w", err)
+	}
+	a.model.Fingerprint = id.Fingerprint()
+
+	opts := []p2p.Option{p2p.WithIdentity(id.PrivKey)}
+	if len(a.config.Relays) > 0 {
+		relays, err := p2p.ParseRelays(a.config.Relays)
+		if err != nil {
+			return fmt.Errorf("invalid relay address: %w", err)
+		}
+		opts = append(opts, p2p.WithRelays(relays...))
+	}
+	listen, err := p2p.ListenAddrs(a.config.ListenPort, a.config.Transports...)
+	if err != nil {
+		return err
+	}
+	opts = append(opts, p2p.WithListenAddrs(listen...))
+
+	// A private group hides the node from everyone without its secret
+	g, err := loadGroup(a.config)
+	if err != nil {
+		return fmt.Errorf("failed to load group: %w", err)
+	}
+	if g != nil {
+		opts = append(opts, p2p.WithPrivateNetwork(g.PSK()), p2p.WithServiceTag(g.ServiceTag()))
+		a.model.Group = g.Name
+	}
+
+	// Only paired devices get past the gater, others are listed as
+	// waiting to pair
+	if a.config.RequirePairing {
+		a.trust, err = pairing.LoadTrustStore(a.config.DataDir)
+		if err != nil {
+			return fmt.Errorf("failed to load trusted peers: %w", err)
+		}
+		gater := p2p.NewGater(a.trust.Trusted)
+		gater.SetUntrustedHandler(a.handlePairingCandidate)
+		opts = append(opts, p2p.WithGater(gater))
+	}
+
+	// Peers from earlier runs are redialed once the node is up
+	a.known, err = knownpeers.Load(a.config.DataDir)
+	if err != nil {
+		return fmt.Errorf("failed to load known peers: %w", err)
+	}
+	a.forgetPeers()
+	a.model.KnownPeers = knownPeersForUI(a.known.List())
+
+	// Clips known peers missed are kept until they reconnect
+	if a.config.OutboxSize > 0 {
+		a.outbox, err = outbox.Load(a.config.DataDir, a.config.OutboxSize)
+		if err != nil {
+			return fmt.Errorf("failed to load outbox: %w", err)
+		}
+	}
 
 	// Initialize P2P node
-	a.node, err = p2p.NewNode(a.ctx)
+	a.node, err = p2p.NewNode(a.ctx, opts...)
 	if err != nil {
 		return err
 	}
 
+	if a.config.RequirePairing {
+		a.pairing, err = a.node.SetupPairing(a.trust, a.config.PeerName, a.handlePaired, a.handlePairingCode)
+		if err != nil {
+			a.node.Close()
+			return err
+		}
+		go a.watchTrust()
+	}
+
 	a.node.SetupConnectionNotifier(a.handlePeerConnected, a.handlePeerDisconnected)
 	a.streamHandler = p2p.NewStreamHandler(a.node, a.handleIncomingClip)
+	a.streamHandler.SetName(a.config.PeerName)
+	a.streamHandler.SetMaxClipSize(a.config.MaxClipSize)
+	a.streamHandler.SetProgressHandler(a.handleTransferProgress)
+	a.streamHandler.SetHelloHandler(a.handlePeerHello)
 	a.discovery, err = a.node.SetupDiscovery(a.handlePeerFound)
 
 	if err != nil {
@@ -81,42 +299,160 @@ func (a *App) Start(ctx context.Context) error {
 		return err
 	}
 
-	a.watcher = clipboard.NewWatcher(a.clipboard, a.config.PollInterval, a.handleClipboardChange)
+	// Peers mDNS can't reach are dialed at known addresses
+	a.bootstrap, err = a.node.SetupBootstrap(a.config.Bootstrap)
+	if err != nil {
+		a.discovery.Close()
+		a.node.Close()
+		return err
+	}
+	a.model.ListenAddrs = a.node.DialAddrs()
+
+	known := a.known.List()
+	infos := make([]peer.AddrInfo, 0, len(known))
+	for _, p := range known {
+		infos = append(infos, p.AddrInfo())
+	}
+	a.node.Redial(infos)
+	if a.config.ForgetPeersAfter > 0 {
+		go a.expireKnownPeers()
+	}
 
-	go a.watcher.Start(a.ctx)
+	for _, sel := range a.selections {
+		sel.watcher = clipboard.NewWatcher(sel.clipboard, a.config.PollInterval, a.clipboardChangeHandler(sel))
+		sel.watcher.SetMode(a.config.WatchMode)
+
+		go sel.watcher.Start(a.ctx)
+	}
 
 	return nil
 }
 
-func (a *App) handleClipboardChange(change clipboard.ClipboardChange) {
+// clipboardChangeHandler returns the watcher callback for a selection
+func (a *App) clipboardChangeHandler(sel *selectionSync) func(clipboard.ClipboardChange) {
+	return func(change clipboard.ClipboardChange) {
+		a.handleClipboardChange(sel, change)
+	}
+}
+
+func (a *App) handleClipboardChange(sel *selectionSync, change clipboard.ClipboardChange) {
 	a.mu.Lock()
-	if a.ignoreNextClip {
-		a.ignoreNextClip = false
+	if sel.ignoreNextClip {
+		sel.ignoreNextClip = false
 		a.mu.Unlock()
 		return
 	}
 
+	// A local copy replaces any received clip waiting to expire
+	if a.cancelExpiryLocked(sel) {
+		defer a.sendToUI(ui.ClipExpiryCancelledMsg{Selection: sel.selection.String()})
+	}
+
 	if !a.model.IsSyncActive() {
 		a.mu.Unlock()
 		return
 	}
 
-	a.lastClipContent = change.Content
-	a.mu.Unlock()
+	// Password managers flag secrets, keep them off the network and
+	// out of the history unless explicitly allowed
+	if change.Concealed && !a.config.SyncConcealed {
+		a.mu.Unlock()
+		return
+	}
 
 	msg := p2p.ClipMessage{
+		Selection: wireSelection(sel.selection),
+		Format:    p2p.FormatText,
 		Content:   change.Content,
 		Timestamp: change.Timestamp,
 		PeerName:  a.config.PeerName,
+		TTL:       a.config.SendTTL,
+	}
+	sent := ui.ClipSentMsg{
+		Content:   change.Content,
+		Timestamp: change.Timestamp,
+		Selection: sel.selection.String(),
+		TTL:       a.config.SendTTL,
+	}
+
+	if change.Format == clipboard.FormatImage {
+		sel.lastClipImage = change.Data
+		msg.Format = p2p.FormatImage
+		msg.Data = change.Data
+		sent.Image = imageInfo(change.Data)
+	} else {
+		sel.lastClipContent = change.Content
+	}
+	a.mu.Unlock()
+
+	if size := int64(len(change.Content) + len(change.Data)); size > a.config.MaxClipSize {
+		sent.NotSynced = notSyncedTooLarge
+		a.sendToUI(sent)
+		return
+	}
+
+	if change.Format == clipboard.FormatText && !a.screenSecrets(&msg, &sent) {
+		return
+	}
+
+	a.sendClip(msg, sent)
+}
+
+// sendClip records a local clip in the history and broadcasts it. The
+// broadcast doesn't hold up the watcher, a newer clip cancels it.
+func (a *App) sendClip(msg p2p.ClipMessage, sent ui.ClipSentMsg) {
+	msg.ID = p2p.NewMessageID()
+	sent.ID = msg.ID
+	a.sendToUI(sent)
+
+	go a.broadcast(msg)
+}
+
+// broadcast sends a clip to all peers and then shows which acked it and
+// for which it was queued. Broadcasts of successive clips may finish in any
+// order, the outbox keeps clips ordered by when they were copied.
+func (a *App) broadcast(msg p2p.ClipMessage) {
+	results := a.streamHandler.Broadcast(a.ctx, msg)
+	delivered := ui.ClipDeliveredMsg{ID: msg.ID, Deliveries: make([]ui.Delivery, 0, len(results))}
+	for _, r := range results {
+		d := ui.Delivery{
+			PeerName:       a.knownName(r.Peer),
+			Delivered:      r.Delivered(),
+			Unacknowledged: r.Status == p2p.DeliverySent,
+			Status:         r.Status.String(),
+			Reason:         r.Reason,
+		}
+		if !d.Delivered && !d.Unacknowledged {
+			log.Printf("clip %s %s by %s: %s", msg.ID, d.Status, d.PeerName, d.Reason)
+		}
+		delivered.Deliveries = append(delivered.Deliveries, d)
+		a.sendPeerTiming(r.Peer)
 	}
+	delivered.QueuedFor = a.queueMissed(msg, results)
+	a.sendToUI(delivered)
+}
 
-	a.streamHandler.Broadcast(a.ctx, msg)
+// sendPeerTiming shows how long clips sent to a peer take to be acked
+func (a *App) sendPeerTiming(peerID peer.ID) {
+	stats, ok := a.streamHandler.PeerStats(peerID)
+	if !ok {
+		return
+	}
+	a.sendToUI(ui.PeerTimingMsg{
+		ID: peerID,
+		Timing: ui.PeerTiming{
+			Delivered: stats.Delivered,
+			Failed:    stats.Failed,
+			Last:      stats.Last,
+			Average:   stats.Average,
+		},
+	})
+}
 
+// sendToUI forwards a message to the TUI if it is running
+func (a *App) sendToUI(msg tea.Msg) {
 	if a.program != nil {
-		a.program.Send(ui.ClipSentMsg{
-			Content:   change.Content,
-			Timestamp: change.Timestamp,
-		})
+		a.program.Send(msg)
 	}
 }
 
@@ -127,25 +463,125 @@ func (a *App) handleIncomingClip(from peer.ID, msg p2p.ClipMessage) {
 		return
 	}
 
-	if msg.Content == a.lastClipContent {
+	// Selections we don't sync, e.g. PRIMARY when opted out, are dropped
+	sel, ok := a.selections[localSelection(msg)]
+	if !ok {
 		a.mu.Unlock()
 		return
 	}
 
-	a.ignoreNextClip = true
-	a.mu.Unlock()
+	received := ui.ClipReceivedMsg{
+		Content:   msg.Content,
+		Timestamp: msg.Timestamp,
+		PeerName:  msg.PeerName,
+		PeerID:    from,
+		Selection: sel.selection.String(),
+		Queued:    msg.Queued,
+	}
+
+	// Clips queued while this device was offline may be older than what
+	// was copied since, the policy decides whether they replace it
+	if msg.Queued && a.config.QueuedClips == QueuedHistory {
+		a.mu.Unlock()
+		received.HistoryOnly = true
+		if msg.IsImage() {
+			received.Image = imageInfo(msg.Data)
+		}
+		a.sendToUI(received)
+		return
+	}
+
+	if msg.IsImage() {
+		img, ok := sel.clipboard.(clipboard.ImageClipboard)
+		if !ok || bytes.Equal(msg.Data, sel.lastClipImage) {
+			a.mu.Unlock()
+			return
+		}
+		a.mu.Unlock()
+
+		received.ExpiresAt = a.prepareExpiry(sel, msg)
+
+		// The same clip may have come in from another peer meanwhile
+		a.mu.Lock()
+		if bytes.Equal(msg.Data, sel.lastClipImage) {
+			a.mu.Unlock()
+			return
+		}
+		sel.lastClipImage = msg.Data
+		sel.ignoreNextClip = true
+		a.mu.Unlock()
+
+		img.WriteImage(msg.Data)
+		received.Image = imageInfo(msg.Data)
+	} else {
+		if msg.Content == sel.lastClipContent {
+			a.mu.Unlock()
+			return
+		}
+		a.mu.Unlock()
 
-	a.clipboard.Write(msg.Content)
+		received.ExpiresAt = a.prepareExpiry(sel, msg)
+
+		a.mu.Lock()
+		if msg.Content == sel.lastClipContent {
+			a.mu.Unlock()
+			return
+		}
+		sel.lastClipContent = msg.Content
+		sel.ignoreNextClip = true
+		a.mu.Unlock()
+
+		sel.clipboard.Write(msg.Content)
+	}
 
 	// Update UI
 	if a.program != nil {
-		a.program.Send(ui.ClipReceivedMsg{
-			Content:   msg.Content,
-			Timestamp: msg.Timestamp,
-			PeerName:  msg.PeerName,
-			PeerID:    from,
-		})
+		a.program.Send(received)
+	}
+}
+
+// prepareExpiry arms or cancels the expiry for a clip about to be written
+// and returns when it expires, zero if it doesn't
+func (a *App) prepareExpiry(sel *selectionSync, msg p2p.ClipMessage) time.Time {
+	if ttl := a.clipTTL(msg); ttl > 0 {
+		return a.scheduleExpiry(sel, msg, ttl)
+	}
+
+	a.mu.Lock()
+	cancelled := a.cancelExpiryLocked(sel)
+	a.mu.Unlock()
+
+	if cancelled {
+		a.sendToUI(ui.ClipExpiryCancelledMsg{Selection: sel.selection.String()})
+	}
+	return time.Time{}
+}
+
+// imageInfo summarizes PNG data for display in the history
+func imageInfo(data []byte) *ui.ImageInfo {
+	info := &ui.ImageInfo{Size: len(data)}
+	if width, height, err := clipboard.ImageSize(data); err == nil {
+		info.Width = width
+		info.Height = height
+	}
+	return info
+}
+
+// handleTransferProgress shows chunked transfers in the TUI
+func (a *App) handleTransferProgress(p p2p.Progress) {
+	msg := ui.TransferProgressMsg{
+		ID:       p.ID,
+		PeerName: a.streamHandler.GetPeerName(p.Peer),
+		Incoming: p.Incoming,
+		Bytes:    p.Bytes,
+		Total:    p.Total,
+		Done:     p.Done,
+	}
+	if p.Err != nil {
+		log.Printf("transfer %s with %s failed: %v", p.ID, msg.PeerName, p.Err)
+		msg.Err = p.Err.Error()
 	}
+	a.sendToUI(msg)
 }
 
 func (a *App) handlePeerFound(info peer.AddrInfo) {
@@ -154,7 +590,8 @@ func (a *App) handlePeerFound(info peer.AddrInfo) {
 }
 
 func (a *App) handlePeerConnected(peerID peer.ID) {
-	if a.streamHandler == nil {
+	// Peers let in to pair show up once they are trusted
+	if a.streamHandler == nil || !a.node.Authorized(peerID) {
 		return
 	}
 
@@ -162,10 +599,72 @@ func (a *App) handlePeerConnected(peerID peer.ID) {
 
 	if a.program != nil {
 		a.program.Send(ui.PeerConnectedMsg{
-			ID:   peerID,
-			Name: name,
+			ID:        peerID,
+			Name:      name,
+			Bootstrap: a.isBootstrap(peerID),
+			Transport: a.node.Transport(peerID),
 		})
 	}
+
+	// Notifications must not block, the name shows up once the peer answers
+	go a.greetPeer(peerID)
+	go a.rememberPeer(peerID, name)
+	go a.flushOutbox(peerID)
+}
+
+// greetPeer asks a newly connected peer who it is
+func (a *App) greetPeer(peerID peer.ID) {
+	ctx, cancel := context.WithTimeout(a.ctx, greetTimeout)
+	defer cancel()
+
+	if _, err := a.streamHandler.Greet(ctx, peerID); err != nil {
+		log.Printf("hello with %s failed: %v", a.streamHandler.GetPeerName(peerID), err)
+	}
+}
+
+// handlePeerHello updates a connected peer with what it announced
+func (a *App) handlePeerHello(peerID peer.ID, hello p2p.Hello) {
+	// A late answer must not bring back a peer that already left
+	if a.node.Host().Network().Connectedness(peerID) != network.Connected {
+		return
+	}
+
+	a.sendToUI(ui.PeerConnectedMsg{
+		ID:        peerID,
+		Name:      a.streamHandler.GetPeerName(peerID),
+		OS:        hello.OS,
+		Version:   hello.Version,
+		Bootstrap: a.isBootstrap(peerID),
+		Transport: a.node.Transport(peerID),
+	})
+	go a.rememberPeer(peerID, hello.Name)
+}
+
+// isBootstrap reports whether a peer was dialed at a known address
+func (a *App) isBootstrap(peerID peer.ID) bool {
+	return a.bootstrap != nil && a.bootstrap.IsBootstrap(peerID)
+}
+
+// sendListenAddrs shows where other devices can add this one, relay
+// addresses only show up once a reservation is held
+func (a *App) sendListenAddrs() {
+	addrs := append(a.node.DialAddrs(), a.node.RelayAddrs()...)
+	a.sendToUI(ui.ListenAddrsMsg{Addrs: addrs})
+}
+
+// addPeer keeps a peer entered in the TUI connected
+func (a *App) addPeer(addr string) {
+	info, err := a.bootstrap.Add(addr)
+	if err != nil {
+		a.sendToUI(ui.AddPeerResultMsg{Addr: addr, Err: err.Error()})
+		return
+	}
+	a.sendToUI(ui.AddPeerResultMsg{Addr: addr})
+
+	// Already connected through mDNS, mark it as a bootstrap peer
+	if a.node.Host().Network().Connectedness(info.ID) == network.Connected {
+		a.handlePeerConnected(info.ID)
+	}
 }
 
 func (a *App) handlePeerDisconnected(peerID peer.ID) {
@@ -174,6 +673,77 @@ func (a *App) handlePeerDisconnected(peerID peer.ID) {
 			ID: peerID,
 		})
 	}
+
+	// Peers we synced with are listed as offline from now on
+	if _, ok := a.known.Get(peerID); ok {
+		go a.rememberPeer(peerID, "")
+	}
+}
+
+// rememberPeer records where a peer can be reached, for redialing it after
+// a restart
+func (a *App) rememberPeer(peerID peer.ID, name string) {
+	addrs := a.node.Host().Peerstore().Addrs(peerID)
+	if err := a.known.Seen(peerID, name, addrs, time.Now()); err != nil {
+		log.Printf("failed to save known peers: %v", err)
+	}
+	a.sendToUI(ui.KnownPeersMsg{Peers: knownPeersForUI(a.known.List())})
+}
+
+// forgetInterval is how often known peers are checked for expiry while
+// running
+const forgetInterval = time.Hour
+
+// forgetPeers drops known peers not seen for Config.ForgetPeersAfter and
+// reports whether any were
+func (a *App) forgetPeers() bool {
+	if a.config.ForgetPeersAfter <= 0 {
+		return false
+	}
+	forgotten, err := a.known.Forget(a.config.ForgetPeersAfter, time.Now())
+	if err != nil {
+		log.Printf("failed to forget old peers: %v", err)
+	}
+	return forgotten > 0
+}
+
+// expireKnownPeers forgets old peers periodically, so a long running
+// instance doesn't keep redialing devices that are gone
+func (a *App) expireKnownPeers() {
+	ticker := time.NewTicker(forgetInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Peers connected all along are still being seen
+		now := time.Now()
+		for _, id := range a.node.Host().Network().Peers() {
+			if _, ok := a.known.Get(id); !ok {
+				continue
+			}
+			if err := a.known.Seen(id, "", nil, now); err != nil {
+				log.Printf("failed to save known peers: %v", err)
+			}
+		}
+
+		if a.forgetPeers() {
+			a.sendToUI(ui.KnownPeersMsg{Peers: knownPeersForUI(a.known.List())})
+		}
+	}
+}
+
+// knownPeersForUI converts known peers for the TUI
+func knownPeersForUI(peers []knownpeers.Peer) []ui.KnownPeer {
+	known := make([]ui.KnownPeer, 0, len(peers))
+	for _, p := range peers {
+		known = append(known, ui.KnownPeer{ID: p.ID, Name: p.Name, LastSeen: p.LastSeen})
+	}
+	return known
 }
 
 func (a *App) SetProgram(p *tea.Program) {
@@ -185,8 +755,16 @@ func (a *App) GetModel() ui.Model {
 }
 
 func (a *App) Stop() {
-	if a.watcher != nil {
-		a.watcher.Stop()
+	if a.pairing != nil {
+		a.closePairing()
+	}
+	for _, sel := range a.selections {
+		if sel.watcher != nil {
+			sel.watcher.Stop()
+		}
+	}
+	if a.bootstrap != nil {
+		a.bootstrap.Close()
 	}
 	if a.discovery != nil {
 		a.discovery.Close()
@@ -0,0 +1,145 @@
+package app
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/owenHochwald/clipp2p/internal/clipboard"
+	"github.com/owenHochwald/clipp2p/internal/knownpeers"
+	"github.com/owenHochwald/clipp2p/internal/outbox"
+	"github.com/owenHochwald/clipp2p/internal/p2p"
+	"github.com/owenHochwald/clipp2p/internal/peertest"
+)
+
+// newTestApp returns an app on a node listening on localhost, without the
+// TUI, clipboard watchers or discovery. Its clipboard is a MockClipboard.
+func newTestApp(t *testing.T, cfg Config) (*App, *clipboard.MockClipboard) {
+	t.Helper()
+	cfg.DataDir = t.TempDir()
+	a := New(cfg)
+	a.ctx, a.cancel = context.WithCancel(t.Context())
+	t.Cleanup(a.cancel)
+
+	cb := clipboard.NewMockClipboard()
+	a.selections[clipboard.SelectionClipboard] = &selectionSync{
+		selection: clipboard.SelectionClipboard,
+		clipboard: cb,
+	}
+
+	var err error
+	a.known, err = knownpeers.Load(cfg.DataDir)
+	assert.NoError(t, err)
+	if cfg.OutboxSize > 0 {
+		a.outbox, err = outbox.Load(cfg.DataDir, cfg.OutboxSize)
+		assert.NoError(t, err)
+	}
+
+	a.node, err = p2p.NewNode(a.ctx, p2p.WithListenAddrs("/ip4/127.0.0.1/tcp/0"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { a.node.Close() })
+	a.streamHandler = p2p.NewStreamHandler(a.node, a.handleIncomingClip)
+	return a, cb
+}
+
+// newTestPeer returns a node connected to the app's that hands the clips
+// it receives to onReceive
+func newTestPeer(t *testing.T, a *App, onReceive func(msg p2p.ClipMessage)) *p2p.Node {
+	t.Helper()
+	node, err := p2p.NewNode(t.Context(), p2p.WithListenAddrs("/ip4/127.0.0.1/tcp/0"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { node.Close() })
+	p2p.NewStreamHandler(node, func(_ peer.ID, msg p2p.ClipMessage) { onReceive(msg) })
+
+	assert.NoError(t, a.node.Host().Connect(t.Context(), node.AddrInfo()))
+	return node
+}
+
+// receivedClips collects the content of the clips a test peer gets
+type receivedClips struct {
+	mu       sync.Mutex
+	contents []string
+}
+
+func (r *receivedClips) add(msg p2p.ClipMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.contents = append(r.contents, msg.Content)
+}
+
+func (r *receivedClips) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.contents...)
+}
+
+// recordingClipboard is a MockClipboard that remembers what was written
+type recordingClipboard struct {
+	*clipboard.MockClipboard
+	mu     sync.Mutex
+	writes []string
+}
+
+func (c *recordingClipboard) Write(content string) error {
+	c.mu.Lock()
+	c.writes = append(c.writes, content)
+	c.mu.Unlock()
+	return c.MockClipboard.Write(content)
+}
+
+// copyText reports a text copy to the app as the watcher does
+func copyText(a *App, content string) {
+	a.handleClipboardChange(a.selections[clipboard.SelectionClipboard], clipboard.ClipboardChange{
+		Format:    clipboard.FormatText,
+		Content:   content,
+		Timestamp: time.Now(),
+	})
+}
+
+func receiveText(a *App, from peer.ID, content string) {
+	a.handleIncomingClip(from, p2p.ClipMessage{
+		ID:        p2p.NewMessageID(),
+		Content:   content,
+		Timestamp: time.Now(),
+	})
+}
+
+func TestIncomingClip_WrittenOnce(t *testing.T) {
+	a, mock := newTestApp(t, DefaultConfig())
+	cb := &recordingClipboard{MockClipboard: mock}
+	a.selections[clipboard.SelectionClipboard].clipboard = cb
+
+	// The same clip relayed by a second peer
+	alice, carol := peertest.NewID(t), peertest.NewID(t)
+	receiveText(a, alice, "hello")
+	receiveText(a, carol, "hello")
+	receiveText(a, carol, "bye")
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	assert.Equal(t, []string{"hello", "bye"}, cb.writes)
+}
+
+func TestIncomingClip_NotSentBack(t *testing.T) {
+	a, cb := newTestApp(t, DefaultConfig())
+	var got receivedClips
+	bob := newTestPeer(t, a, got.add)
+
+	receiveText(a, bob.ID(), "from bob")
+	content, _ := cb.Read()
+	assert.Equal(t, "from bob", content)
+
+	// The watcher sees our own write first, then a real copy
+	copyText(a, "from bob")
+	copyText(a, "mine")
+
+	assert.Eventually(t, func() bool {
+		contents := got.get()
+		return len(contents) == 1 && contents[0] == "mine"
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, []string{"mine"}, got.get())
+}
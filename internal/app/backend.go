@@ -0,0 +1,112 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/owenHochwald/clipp2p/internal/clipboard"
+)
+
+// Clipboard backends selectable through Config.Backend, besides the
+// command preset names
+const (
+	BackendAuto    = "auto"
+	BackendSystem  = "system"
+	BackendCommand = "command"
+	BackendOSC52   = "osc52"
+	BackendFile    = "file"
+)
+
+// newClipboard creates the clipboard backend selected in the config
+func newClipboard(cfg Config) (clipboard.Resolution, error) {
+	switch cfg.Backend {
+	case "", BackendAuto:
+		env := clipboard.DetectEnvironment()
+		res, err := clipboard.Resolve(env, clipboard.DefaultCandidates())
+		for _, attempt := range res.Rejected {
+			log.Printf("clipboard: rejected %s backend: %v", attempt.Name, attempt.Err)
+		}
+		if err != nil {
+			return res, fmt.Errorf("%w (%s)", err, env)
+		}
+		log.Printf("clipboard: using %s backend (%s)", res.Name, env)
+		return res, nil
+	case BackendSystem:
+		cb, err := clipboard.NewSystemClipboard()
+		if err != nil {
+			return clipboard.Resolution{}, err
+		}
+		return clipboard.Resolution{Name: BackendSystem, Clipboard: cb}, nil
+	case BackendOSC52:
+		cb, err := clipboard.OpenOSC52Clipboard(clipboard.OSC52Config{Query: cfg.OSC52Query})
+		if err != nil {
+			return clipboard.Resolution{}, err
+		}
+		return clipboard.Resolution{Name: BackendOSC52, Clipboard: cb}, nil
+	case BackendFile:
+		cb, err := clipboard.NewFileClipboard(cfg.FilePath)
+		if err != nil {
+			return clipboard.Resolution{}, err
+		}
+		return clipboard.Resolution{Name: BackendFile, Clipboard: cb}, nil
+	case BackendCommand:
+		cmd, err := clipboard.ParseCommandConfig(cfg.ReadCommand, cfg.WriteCommand)
+		if err != nil {
+			return clipboard.Resolution{}, err
+		}
+		cmd.Timeout = cfg.CommandTimeout
+		cb, err := clipboard.NewCommandClipboard(cmd)
+		if err != nil {
+			return clipboard.Resolution{}, err
+		}
+		return clipboard.Resolution{Name: BackendCommand, Clipboard: cb}, nil
+	default:
+		cmd, err := clipboard.CommandPreset(cfg.Backend, clipboard.SelectionClipboard)
+		if err != nil {
+			return clipboard.Resolution{}, err
+		}
+		cmd.Timeout = cfg.CommandTimeout
+		cb, err := clipboard.NewCommandClipboard(cmd)
+		if err != nil {
+			return clipboard.Resolution{}, err
+		}
+		return clipboard.Resolution{Name: cfg.Backend, Clipboard: cb}, nil
+	}
+}
+
+// Diagnose writes a report of the detected environment and of every
+// built-in clipboard backend, marking the one auto-detection would pick.
+func Diagnose(w io.Writer) {
+	env := clipboard.DetectEnvironment()
+	fmt.Fprintf(w, "Environment: %s\n\n", env)
+	fmt.Fprintln(w, "Clipboard backends (in order of preference):")
+
+	chosen := ""
+	for _, c := range clipboard.DefaultCandidates() {
+		if err := c.Check(env); err != nil {
+			fmt.Fprintf(w, "  [ ] %-14s %v\n", c.Name, err)
+			continue
+		}
+		if chosen != "" {
+			fmt.Fprintf(w, "  [ ] %-14s usable\n", c.Name)
+			continue
+		}
+		if _, err := c.Open(); err != nil {
+			fmt.Fprintf(w, "  [ ] %-14s %v\n", c.Name, err)
+			continue
+		}
+		chosen = c.Name
+		fmt.Fprintf(w, "  [x] %-14s selected\n", c.Name)
+	}
+
+	if chosen == "" {
+		fmt.Fprintf(w, "\n%v\n", clipboard.ErrNoBackend)
+	}
+
+	if _, err := clipboard.NewPrimaryClipboard(); err != nil {
+		fmt.Fprintf(w, "\nPrimary selection: %v\n", err)
+	} else {
+		fmt.Fprintln(w, "\nPrimary selection: available")
+	}
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/owenHochwald/clipp2p/internal/clipboard"
+	"github.com/owenHochwald/clipp2p/internal/p2p"
+	"github.com/owenHochwald/clipp2p/internal/ui"
+)
+
+// expiringClip is a received clip that has to leave the clipboard once
+// its TTL runs out
+type expiringClip struct {
+	id      uint64
+	timer   *time.Timer
+	content string
+	image   []byte
+
+	// What the clipboard held before the first of a chain of expiring clips
+	previous      string
+	previousImage []byte
+}
+
+// clipTTL returns how long a received clip may stay in the clipboard, or
+// zero if it may stay forever. The shorter of our own limit and the
+// sender's wins.
+func (a *App) clipTTL(msg p2p.ClipMessage) time.Duration {
+	ttl := a.config.ClearAfter
+	if msg.TTL > 0 && (ttl == 0 || msg.TTL < ttl) {
+		ttl = msg.TTL
+	}
+	return ttl
+}
+
+// scheduleExpiry arms the expiry of a received clip. It must run before the
+// clip is written so the content it replaces can be saved.
+func (a *App) scheduleExpiry(sel *selectionSync, msg p2p.ClipMessage, ttl time.Duration) time.Time {
+	previous, previousImage := readSelection(sel)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if pending := sel.expiry; pending != nil {
+		pending.timer.Stop()
+		previous, previousImage = pending.previous, pending.previousImage
+	}
+
+	a.nextExpiryID++
+	id := a.nextExpiryID
+	sel.expiry = &expiringClip{
+		id:            id,
+		content:       msg.Content,
+		image:         msg.Data,
+		previous:      previous,
+		previousImage: previousImage,
+	}
+	sel.expiry.timer = time.AfterFunc(ttl, func() {
+		a.expireClip(sel, id)
+	})
+
+	return time.Now().Add(ttl)
+}
+
+// cancelExpiryLocked drops a pending expiry because the clipboard moved on.
+// It reports whether there was one. Callers must hold a.mu.
+func (a *App) cancelExpiryLocked(sel *selectionSync) bool {
+	if sel.expiry == nil {
+		return false
+	}
+	sel.expiry.timer.Stop()
+	sel.expiry = nil
+	return true
+}
+
+// expireClip restores the previous clipboard content, or clears it, if the
+// expired clip is still in the clipboard
+func (a *App) expireClip(sel *selectionSync, id uint64) {
+	a.mu.Lock()
+	expired := sel.expiry
+	if expired == nil || expired.id != id {
+		a.mu.Unlock()
+		return
+	}
+	sel.expiry = nil
+	a.mu.Unlock()
+
+	current, currentImage := readSelection(sel)
+	stillThere := current == expired.content
+	if expired.image != nil {
+		stillThere = bytes.Equal(currentImage, expired.image)
+	}
+	if !stillThere {
+		a.sendToUI(ui.ClipExpiryCancelledMsg{Selection: sel.selection.String()})
+		return
+	}
+
+	var restoreText string
+	var restoreImage []byte
+	if a.config.ClearRestores {
+		restoreText, restoreImage = expired.previous, expired.previousImage
+	}
+
+	a.mu.Lock()
+	sel.lastClipContent = restoreText
+	sel.lastClipImage = restoreImage
+	// Clearing isn't reported by the watcher, so there is nothing to ignore
+	sel.ignoreNextClip = restoreText != "" || restoreImage != nil
+	a.mu.Unlock()
+
+	if img, ok := sel.clipboard.(clipboard.ImageClipboard); ok && restoreImage != nil {
+		img.WriteImage(restoreImage)
+	} else {
+		sel.clipboard.Write(restoreText)
+	}
+
+	a.sendToUI(ui.ClipExpiredMsg{
+		Selection: sel.selection.String(),
+		Restored:  restoreText != "" || restoreImage != nil,
+	})
+}
+
+// readSelection returns the current text, or image if there is no text
+func readSelection(sel *selectionSync) (string, []byte) {
+	text, _ := sel.clipboard.Read()
+	if text != "" {
+		return text, nil
+	}
+	if img, ok := sel.clipboard.(clipboard.ImageClipboard); ok {
+		data, _ := img.ReadImage()
+		if len(data) > 0 {
+			return "", data
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/owenHochwald/clipp2p/internal/clipboard"
+	"github.com/owenHochwald/clipp2p/internal/p2p"
+	"github.com/owenHochwald/clipp2p/internal/peertest"
+)
+
+// assertClipboard waits for the clipboard to hold content
+func assertClipboard(t *testing.T, cb *clipboard.MockClipboard, content string) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		current, _ := cb.Read()
+		return current == content
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestExpiry_RestoresPreviousClip(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ClearAfter = 50 * time.Millisecond
+	a, cb := newTestApp(t, cfg)
+	cb.SetContent("mine")
+
+	receiveText(a, peertest.NewID(t), "password")
+	assertClipboard(t, cb, "password")
+	assertClipboard(t, cb, "mine")
+}
+
+func TestExpiry_ClearsWithoutRestore(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ClearAfter = 50 * time.Millisecond
+	cfg.ClearRestores = false
+	a, cb := newTestApp(t, cfg)
+	cb.SetContent("mine")
+
+	receiveText(a, peertest.NewID(t), "password")
+	assertClipboard(t, cb, "")
+}
+
+// Ephemeral clips received in a row restore what was there before the first
+func TestExpiry_ChainRestoresFirstPrevious(t *testing.T) {
+	a, cb := newTestApp(t, DefaultConfig())
+	cb.SetContent("mine")
+	from := peertest.NewID(t)
+
+	for _, content := range []string{"first", "second"} {
+		a.handleIncomingClip(from, p2p.ClipMessage{
+			ID:        p2p.NewMessageID(),
+			Content:   content,
+			Timestamp: time.Now(),
+			TTL:       100 * time.Millisecond,
+		})
+	}
+	assertClipboard(t, cb, "second")
+	assertClipboard(t, cb, "mine")
+}
+
+func TestExpiry_KeepsLocalCopy(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ClearAfter = 50 * time.Millisecond
+	a, cb := newTestApp(t, cfg)
+
+	receiveText(a, peertest.NewID(t), "password")
+	copyText(a, "password")
+	cb.SetContent("newer")
+	copyText(a, "newer")
+
+	time.Sleep(150 * time.Millisecond)
+	content, _ := cb.Read()
+	assert.Equal(t, "newer", content)
+}
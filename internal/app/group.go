@@ -0,0 +1,102 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/owenHochwald/clipp2p/internal/group"
+)
+
+// GroupUsage describes the group subcommands
+const GroupUsage = `group [show]              print the group this device syncs in
+  group create <name>       start a private group and print its invite
+  group join <invite>       join a group, only its members are seen
+  group invite              print the invite of the current group
+  group leave               leave the group and sync with everyone again`
+
+// Group runs a group subcommand, args start after "group"
+func Group(w io.Writer, cfg Config, args []string) error {
+	if cfg.DataDir == "" {
+		return errors.New("no data directory, set -data-dir")
+	}
+	if len(args) == 0 {
+		args = []string{"show"}
+	}
+
+	switch args[0] {
+	case "show", "invite":
+		g, err := group.Load(cfg.DataDir)
+		if errors.Is(err, group.ErrNoGroup) {
+			fmt.Fprintln(w, "Not in a group, syncing with every ClipP2P device on the network")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if args[0] == "invite" {
+			fmt.Fprintln(w, g.Invite())
+			return nil
+		}
+		fmt.Fprintf(w, "Group: %s\n", g.Name)
+
+	case "create":
+		if len(args) < 2 {
+			return errors.New("create needs a group name")
+		}
+		g, err := group.New(args[1])
+		if err != nil {
+			return err
+		}
+		if err := group.Save(cfg.DataDir, g); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Created group %s. Join it on your other devices with:\n\n  clipp2p group join %s\n\nKeep the invite secret, anyone holding it can join.\n", g.Name, g.Invite())
+
+	case "join":
+		if len(args) < 2 {
+			return errors.New("join needs an invite")
+		}
+		g, err := joinGroup(cfg.DataDir, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Joined group %s\n", g.Name)
+
+	case "leave":
+		left, err := group.Leave(cfg.DataDir)
+		if err != nil {
+			return err
+		}
+		if !left {
+			return group.ErrNoGroup
+		}
+		fmt.Fprintln(w, "Left the group, syncing with every ClipP2P device on the network")
+
+	default:
+		return fmt.Errorf("unknown group command %q", args[0])
+	}
+	return nil
+}
+
+// joinGroup makes the group of an invite the device's group
+func joinGroup(dir, invite string) (*group.Group, error) {
+	g, err := group.ParseInvite(invite)
+	if err != nil {
+		return nil, err
+	}
+	return g, group.Save(dir, g)
+}
+
+// loadGroup returns the group to sync in, joining cfg.GroupInvite first if
+// set. Nil means no group.
+func loadGroup(cfg Config) (*group.Group, error) {
+	if cfg.GroupInvite != "" {
+		return joinGroup(cfg.DataDir, cfg.GroupInvite)
+	}
+	g, err := group.Load(cfg.DataDir)
+	if errors.Is(err, group.ErrNoGroup) {
+		return nil, nil
+	}
+	return g, err
+}
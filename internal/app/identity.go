@@ -0,0 +1,91 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/owenHochwald/clipp2p/internal/identity"
+)
+
+// IdentityUsage describes the identity subcommands
+const IdentityUsage = `identity show             print this device's peer ID and fingerprint
+  identity rotate           replace the key, peers will see a new device
+  identity export [file]    write the key to file or stdout, for migration
+  identity import <file|->  replace the key with an exported one`
+
+// Identity runs an identity subcommand, args start after "identity"
+func Identity(w io.Writer, cfg Config, args []string) error {
+	if cfg.DataDir == "" {
+		return errors.New("no data directory, set -data-dir")
+	}
+	if len(args) == 0 {
+		args = []string{"show"}
+	}
+
+	switch args[0] {
+	case "show":
+		id, err := identity.LoadOrCreate(cfg.DataDir)
+		if err != nil {
+			return err
+		}
+		printIdentity(w, cfg, id)
+
+	case "rotate":
+		id, err := identity.Rotate(cfg.DataDir)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "New identity created, the previous key was kept as "+identity.KeyFile+".old")
+		printIdentity(w, cfg, id)
+
+	case "export":
+		if len(args) < 2 || args[1] == "-" {
+			return identity.Export(cfg.DataDir, w)
+		}
+		f, err := os.OpenFile(args[1], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		if err := identity.Export(cfg.DataDir, f); err != nil {
+			f.Close()
+			os.Remove(args[1])
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Identity exported to %s, keep it secret\n", args[1])
+
+	case "import":
+		if len(args) < 2 {
+			return errors.New("import needs a file, or - for stdin")
+		}
+		r := io.Reader(os.Stdin)
+		if args[1] != "-" {
+			f, err := os.Open(args[1])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		id, err := identity.Import(cfg.DataDir, r)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "Identity imported")
+		printIdentity(w, cfg, id)
+
+	default:
+		return fmt.Errorf("unknown identity command %q", args[0])
+	}
+	return nil
+}
+
+func printIdentity(w io.Writer, cfg Config, id *identity.Identity) {
+	fmt.Fprintf(w, "Peer ID:     %s\n", id.ID)
+	fmt.Fprintf(w, "Fingerprint: %s\n", id.Fingerprint())
+	fmt.Fprintf(w, "Key file:    %s\n", identity.Path(cfg.DataDir))
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"log"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/owenHochwald/clipp2p/internal/p2p"
+	"github.com/owenHochwald/clipp2p/internal/ui"
+)
+
+// Policies for clips queued for this device while it was offline, see
+// Config.QueuedClips
+const (
+	// QueuedOverwrite writes queued clips to the clipboard like any other
+	QueuedOverwrite = "overwrite"
+	// QueuedHistory only lists queued clips in the history, leaving the
+	// clipboard as it is
+	QueuedHistory = "history"
+)
+
+// queueMissed keeps a clip for the known peers that didn't get it, offline
+// or not answering, until they reconnect. It returns their names. Peers
+// that got it drop the older clips queued for them.
+func (a *App) queueMissed(msg p2p.ClipMessage, results []p2p.DeliveryResult) []string {
+	if a.outbox == nil {
+		return nil
+	}
+
+	// Peers that refused the clip would refuse it again, and those it was
+	// cancelled for get the newer one
+	reached := make(map[peer.ID]bool)
+	for _, r := range results {
+		reached[r.Peer] = r.Status != p2p.DeliveryTimedOut && r.Status != p2p.DeliveryFailed
+		if r.Status != p2p.DeliveryDelivered && r.Status != p2p.DeliverySent {
+			continue
+		}
+		if err := a.outbox.MarkDelivered(r.Peer, msg.Timestamp); err != nil {
+			log.Printf("failed to save outbox: %v", err)
+		}
+	}
+
+	// Ephemeral clips are not worth delivering late
+	if msg.TTL > 0 {
+		return nil
+	}
+
+	var queued []string
+	for _, p := range a.known.List() {
+		if reached[p.ID] || (a.trust != nil && !a.trust.Trusted(p.ID)) {
+			continue
+		}
+		if err := a.outbox.Push(p.ID, msg); err != nil {
+			log.Printf("failed to queue clip for %s: %v", a.knownName(p.ID), err)
+			continue
+		}
+		queued = append(queued, a.knownName(p.ID))
+	}
+	return queued
+}
+
+// flushOutbox sends a reconnected peer the clips it missed, oldest first,
+// stopping at the first that doesn't get through and skipping those a
+// newer live clip superseded meanwhile
+func (a *App) flushOutbox(peerID peer.ID) {
+	if a.outbox == nil {
+		return
+	}
+
+	a.mu.Lock()
+	if a.flushing[peerID] {
+		a.mu.Unlock()
+		return
+	}
+	a.flushing[peerID] = true
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		delete(a.flushing, peerID)
+		a.mu.Unlock()
+	}()
+
+	pending, err := a.outbox.Pending(peerID)
+	if err != nil {
+		log.Printf("failed to read outbox: %v", err)
+		return
+	}
+	for _, msg := range pending {
+		// A newer clip may have reached the peer live since the queue
+		// was read, which already dropped this one from it
+		if a.outbox.Superseded(peerID, msg.Timestamp) {
+			continue
+		}
+
+		msg.Queued = true
+		r := a.streamHandler.Deliver(a.ctx, peerID, msg)
+		name := a.knownName(peerID)
+		if r.Status != p2p.DeliveryDelivered && r.Status != p2p.DeliverySent && r.Status != p2p.DeliveryRejected {
+			log.Printf("queued clip %s %s by %s: %s", msg.ID, r.Status, name, r.Reason)
+			return
+		}
+
+		a.sendPeerTiming(peerID)
+		if err := a.outbox.Remove(peerID, msg.ID); err != nil {
+			log.Printf("failed to save outbox: %v", err)
+		}
+		a.sendToUI(ui.QueuedClipSentMsg{
+			ID: msg.ID,
+			Delivery: ui.Delivery{
+				PeerName:       name,
+				Delivered:      r.Delivered(),
+				Unacknowledged: r.Status == p2p.DeliverySent,
+				Status:         r.Status.String(),
+				Reason:         r.Reason,
+			},
+		})
+	}
+}
+
+// knownName names a peer as it was last seen, it may be offline or not
+// have said hello yet
+func (a *App) knownName(peerID peer.ID) string {
+	if p, ok := a.known.Get(peerID); ok && p.Name != "" {
+		return p.Name
+	}
+	return a.streamHandler.GetPeerName(peerID)
+}
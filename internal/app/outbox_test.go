@@ -0,0 +1,107 @@
+package app
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/owenHochwald/clipp2p/internal/p2p"
+	"github.com/owenHochwald/clipp2p/internal/peertest"
+)
+
+func TestFlushOutbox(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.OutboxSize = 3
+	a, _ := newTestApp(t, cfg)
+
+	var mu sync.Mutex
+	var received []p2p.ClipMessage
+	bob := newTestPeer(t, a, func(msg p2p.ClipMessage) {
+		mu.Lock()
+		received = append(received, msg)
+		mu.Unlock()
+	})
+
+	now := time.Now()
+	for i, content := range []string{"first", "second"} {
+		msg := p2p.ClipMessage{ID: p2p.NewMessageID(), Content: content, Timestamp: now.Add(time.Duration(i) * time.Second)}
+		assert.NoError(t, a.outbox.Push(bob.ID(), msg))
+	}
+
+	a.flushOutbox(bob.ID())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, received, 2)
+	for i, content := range []string{"first", "second"} {
+		assert.Equal(t, content, received[i].Content)
+		assert.True(t, received[i].Queued)
+	}
+	pending, err := a.outbox.Pending(bob.ID())
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+// A live clip delivered while the queue is sent stops the older queued
+// clips, they would overwrite it
+func TestFlushOutbox_SkipsSupersededClips(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.OutboxSize = 3
+	a, _ := newTestApp(t, cfg)
+
+	now := time.Now()
+	var mu sync.Mutex
+	var received []string
+	var bob *p2p.Node
+	bob = newTestPeer(t, a, func(msg p2p.ClipMessage) {
+		mu.Lock()
+		received = append(received, msg.Content)
+		mu.Unlock()
+		// A broadcast of a clip copied after the queued ones got through
+		assert.NoError(t, a.outbox.MarkDelivered(bob.ID(), now.Add(time.Minute)))
+	})
+
+	for i, content := range []string{"first", "second", "third"} {
+		msg := p2p.ClipMessage{ID: p2p.NewMessageID(), Content: content, Timestamp: now.Add(time.Duration(i) * time.Second)}
+		assert.NoError(t, a.outbox.Push(bob.ID(), msg))
+	}
+
+	a.flushOutbox(bob.ID())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"first"}, received)
+	pending, err := a.outbox.Pending(bob.ID())
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestQueueMissed(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.OutboxSize = 3
+	a, _ := newTestApp(t, cfg)
+
+	bob, carol := peertest.NewID(t), peertest.NewID(t)
+	assert.NoError(t, a.known.Seen(bob, "Bob", nil, time.Now()))
+	assert.NoError(t, a.known.Seen(carol, "Carol", nil, time.Now()))
+
+	// Bob got the clip, Carol is offline
+	msg := p2p.ClipMessage{ID: p2p.NewMessageID(), Content: "hello", Timestamp: time.Now()}
+	queued := a.queueMissed(msg, []p2p.DeliveryResult{{Peer: bob, Status: p2p.DeliveryDelivered}})
+	assert.Equal(t, []string{"Carol"}, queued)
+
+	pending, err := a.outbox.Pending(carol)
+	assert.NoError(t, err)
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, "hello", pending[0].Content)
+	}
+	pending, err = a.outbox.Pending(bob)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// Ephemeral clips are not queued
+	ephemeral := p2p.ClipMessage{ID: p2p.NewMessageID(), Content: "soon gone", Timestamp: time.Now(), TTL: time.Minute}
+	assert.Empty(t, a.queueMissed(ephemeral, nil))
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/owenHochwald/clipp2p/internal/pairing"
+	"github.com/owenHochwald/clipp2p/internal/ui"
+)
+
+// pairingWindow is how long a code is shown before pairing closes itself
+const pairingWindow = 5 * time.Minute
+
+// pairingRequestTTL drops devices from the pending list once they stop
+// trying to reach us
+const pairingRequestTTL = 10 * time.Minute
+
+// trustReloadInterval is how often the trusted peers file is reread, so
+// devices removed with "clipp2p trusted remove" are dropped while running
+const trustReloadInterval = 2 * time.Second
+
+// watchTrust disconnects peers once they are no longer trusted
+func (a *App) watchTrust() {
+	ticker := time.NewTicker(trustReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		removed, err := a.trust.Reload()
+		if err != nil {
+			log.Printf("failed to reload trusted peers: %v", err)
+			continue
+		}
+		for _, id := range removed {
+			log.Printf("%s is no longer trusted, disconnecting", id)
+			a.node.Host().Network().ClosePeer(id)
+		}
+	}
+}
+
+// handlePairingCandidate records an untrusted peer that was turned away.
+// It runs on libp2p's goroutines.
+func (a *App) handlePairingCandidate(id peer.ID) {
+	a.mu.Lock()
+	_, known := a.pairingRequests[id]
+	a.pairingRequests[id] = time.Now()
+	a.mu.Unlock()
+
+	if !known {
+		go a.sendPairingRequests()
+	}
+}
+
+// sendPairingRequests shows the devices waiting to pair, newest first
+func (a *App) sendPairingRequests() {
+	a.mu.Lock()
+	requests := make([]ui.PairingRequest, 0, len(a.pairingRequests))
+	for id, seen := range a.pairingRequests {
+		if time.Since(seen) > pairingRequestTTL {
+			delete(a.pairingRequests, id)
+			continue
+		}
+		requests = append(requests, ui.PairingRequest{ID: id, Seen: seen})
+	}
+	a.mu.Unlock()
+
+	slices.SortFunc(requests, func(x, y ui.PairingRequest) int {
+		return y.Seen.Compare(x.Seen)
+	})
+	a.sendToUI(ui.PairingRequestsMsg{Requests: requests})
+}
+
+// openPairing shows a code until the user leaves the pairing screen
+func (a *App) openPairing() {
+	if _, err := a.pairing.Open(); err != nil {
+		a.sendToUI(ui.PairingResultMsg{Err: err.Error()})
+		return
+	}
+
+	a.mu.Lock()
+	if a.pairingTimer != nil {
+		a.pairingTimer.Stop()
+	}
+	a.pairingTimer = time.AfterFunc(pairingWindow, a.closePairing)
+	a.mu.Unlock()
+
+	a.sendPairingRequests()
+}
+
+// closePairing stops showing the code
+func (a *App) closePairing() {
+	a.mu.Lock()
+	if a.pairingTimer != nil {
+		a.pairingTimer.Stop()
+		a.pairingTimer = nil
+	}
+	a.mu.Unlock()
+
+	a.pairing.Close()
+}
+
+// handlePairingCode shows the current code, empty once pairing closed
+func (a *App) handlePairingCode(code string) {
+	if code != "" {
+		code = pairing.FormatCode(code)
+	}
+	a.sendToUI(ui.PairingCodeMsg{Code: code})
+}
+
+// pair types the code shown on another device
+func (a *App) pair(id peer.ID, code string) {
+	result := ui.PairingResultMsg{PeerID: id}
+
+	info := a.pairingAddrInfo(id)
+	if len(info.Addrs) == 0 {
+		result.Err = "no known address for this device"
+		a.sendToUI(result)
+		return
+	}
+
+	// Success is reported by handlePaired
+	_, err := a.pairing.Pair(a.ctx, info, code)
+	if errors.Is(err, pairing.ErrConfirmation) {
+		result.Err = "wrong code"
+	} else if err != nil {
+		result.Err = err.Error()
+	}
+	if result.Err != "" {
+		a.sendToUI(result)
+	}
+}
+
+// pairingAddrInfo finds where a device waiting to pair can be reached
+func (a *App) pairingAddrInfo(id peer.ID) peer.AddrInfo {
+	for _, info := range a.discovery.Peers() {
+		if info.ID == id {
+			return info
+		}
+	}
+	return a.node.Host().Peerstore().PeerInfo(id)
+}
+
+// handlePaired starts syncing with a newly paired peer. It runs on the
+// pairing stream's goroutine.
+func (a *App) handlePaired(trusted pairing.TrustedPeer) {
+	a.mu.Lock()
+	delete(a.pairingRequests, trusted.ID)
+	a.mu.Unlock()
+
+	a.sendToUI(ui.PairingResultMsg{PeerID: trusted.ID, Name: trusted.Name})
+	a.sendPairingRequests()
+
+	if len(a.node.Host().Network().ConnsToPeer(trusted.ID)) > 0 {
+		a.handlePeerConnected(trusted.ID)
+	}
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/owenHochwald/clipp2p/internal/identity"
+	"github.com/owenHochwald/clipp2p/internal/p2p"
+)
+
+// DefaultRelayPort is where relay mode listens unless -port is set
+const DefaultRelayPort = 4001
+
+// Relay runs only the circuit relay service, for peers behind NATs to
+// reach each other through, until ctx is done
+func Relay(ctx context.Context, w io.Writer, cfg Config) error {
+	// The relay's address is handed to peers, keep its peer ID stable
+	id, err := identity.LoadOrCreate(cfg.DataDir)
+	if err != nil {
+		return fmt.Errorf("failed to load identity: %w", err)
+	}
+
+	port := cfg.ListenPort
+	if port == 0 {
+		port = DefaultRelayPort
+	}
+	listen, err := p2p.ListenAddrs(port, cfg.Transports...)
+	if err != nil {
+		return err
+	}
+	opts := []p2p.Option{
+		p2p.WithIdentity(id.PrivKey),
+		p2p.WithRelayService(),
+		p2p.WithListenAddrs(listen...),
+	}
+	if cfg.RelayUnlimited {
+		opts = append(opts, p2p.WithUnlimitedRelay())
+	}
+
+	// In a group only members can use the relay
+	g, err := loadGroup(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load group: %w", err)
+	}
+	if g != nil {
+		opts = append(opts, p2p.WithPrivateNetwork(g.PSK()))
+	}
+
+	node, err := p2p.NewNode(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	defer node.Close()
+
+	if g != nil {
+		fmt.Fprintf(w, "Relaying for group %s\n", g.Name)
+	}
+	fmt.Fprintln(w, "Relay running, start peers with one of:")
+	for _, addr := range node.DialAddrs() {
+		fmt.Fprintf(w, "  clipp2p -relay %s\n", addr)
+	}
+
+	<-ctx.Done()
+	return nil
+}
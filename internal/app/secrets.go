@@ -0,0 +1,101 @@
+package app
+
+import (
+	"github.com/owenHochwald/clipp2p/internal/inspect"
+	"github.com/owenHochwald/clipp2p/internal/p2p"
+	"github.com/owenHochwald/clipp2p/internal/ui"
+)
+
+// notSyncedSecret is shown in the history for clips kept local by a
+// secret policy
+const notSyncedSecret = "secret"
+
+// pendingClip is a clip held back until the user confirms it
+type pendingClip struct {
+	id     uint64
+	msg    p2p.ClipMessage
+	sent   ui.ClipSentMsg
+	masked string
+}
+
+// screenSecrets applies the secret policies to an outgoing text clip,
+// redacting msg and sent in place. It returns false if the clip must not
+// be sent now.
+func (a *App) screenSecrets(msg *p2p.ClipMessage, sent *ui.ClipSentMsg) bool {
+	verdict := a.inspector.Inspect(msg.Content)
+
+	sent.Redacted = verdict.Content != msg.Content
+	msg.Content = verdict.Content
+	sent.Content = verdict.Content
+
+	switch verdict.Action {
+	case inspect.PolicyAsk:
+		// Without a TUI there is nobody to ask
+		if a.program == nil {
+			a.keepLocal(*sent, verdict.Masked)
+			return false
+		}
+		a.holdForConfirmation(*msg, *sent, verdict)
+		return false
+	case inspect.PolicyBlock:
+		a.keepLocal(*sent, verdict.Masked)
+		return false
+	}
+	return true
+}
+
+// holdForConfirmation parks a clip and asks the user about it. A clip
+// still waiting from before is superseded and kept local.
+func (a *App) holdForConfirmation(msg p2p.ClipMessage, sent ui.ClipSentMsg, verdict inspect.Verdict) {
+	a.mu.Lock()
+	previous := a.pendingSecret
+	a.nextPromptID++
+	a.pendingSecret = &pendingClip{
+		id:     a.nextPromptID,
+		msg:    msg,
+		sent:   sent,
+		masked: verdict.Masked,
+	}
+	id := a.nextPromptID
+	a.mu.Unlock()
+
+	if previous != nil {
+		a.keepLocal(previous.sent, previous.masked)
+	}
+
+	a.program.Send(ui.SecretPromptMsg{
+		ID:        id,
+		Detectors: verdict.Detectors(),
+		Preview:   verdict.Masked,
+	})
+}
+
+// resolveSecret handles the user's answer to a secret prompt
+func (a *App) resolveSecret(id uint64, send bool) {
+	a.mu.Lock()
+	pending := a.pendingSecret
+	if pending == nil || pending.id != id {
+		a.mu.Unlock()
+		return
+	}
+	a.pendingSecret = nil
+	a.mu.Unlock()
+
+	if send {
+		a.sendClip(pending.msg, pending.sent)
+		return
+	}
+	a.keepLocal(pending.sent, pending.masked)
+}
+
+// keepLocal records a clip in the history without sending it
+func (a *App) keepLocal(sent ui.ClipSentMsg, masked string) {
+	if a.program == nil {
+		return
+	}
+
+	sent.Content = masked
+	sent.Redacted = false
+	sent.NotSynced = notSyncedSecret
+	a.program.Send(sent)
+}
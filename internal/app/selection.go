@@ -0,0 +1,36 @@
+package app
+
+import (
+	"github.com/owenHochwald/clipp2p/internal/clipboard"
+	"github.com/owenHochwald/clipp2p/internal/p2p"
+)
+
+// selectionSync holds the clipboard, watcher and loop-prevention state
+// for a single synced selection
+type selectionSync struct {
+	selection clipboard.Selection
+	clipboard clipboard.Clipboard
+	watcher   *clipboard.Watcher
+
+	// Guarded by App.mu
+	lastClipContent string
+	lastClipImage   []byte
+	ignoreNextClip  bool
+	expiry          *expiringClip
+}
+
+// wireSelection maps a local selection to its ClipMessage tag
+func wireSelection(sel clipboard.Selection) string {
+	if sel == clipboard.SelectionPrimary {
+		return p2p.SelectionPrimary
+	}
+	return p2p.SelectionClipboard
+}
+
+// localSelection maps a ClipMessage tag to the local selection
+func localSelection(msg p2p.ClipMessage) clipboard.Selection {
+	if msg.IsPrimary() {
+		return clipboard.SelectionPrimary
+	}
+	return clipboard.SelectionClipboard
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/owenHochwald/clipp2p/internal/pairing"
+)
+
+// TrustedUsage describes the trusted peers subcommands
+const TrustedUsage = `trusted [list]            print the devices paired with this one
+  trusted remove <peer-id>  forget a paired device, it has to pair again`
+
+// Trusted runs a trusted peers subcommand, args start after "trusted"
+func Trusted(w io.Writer, cfg Config, args []string) error {
+	if cfg.DataDir == "" {
+		return errors.New("no data directory, set -data-dir")
+	}
+	store, err := pairing.LoadTrustStore(cfg.DataDir)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 || args[0] == "list" {
+		peers := store.List()
+		if len(peers) == 0 {
+			fmt.Fprintln(w, "No paired devices, press p in the dashboard to pair")
+			return nil
+		}
+		for _, p := range peers {
+			fmt.Fprintf(w, "%s  %-20s  paired %s\n", p.ID, p.Name, p.PairedAt.Format(time.DateTime))
+		}
+		return nil
+	}
+
+	if args[0] != "remove" || len(args) < 2 {
+		return errors.New("usage: trusted [list | remove <peer-id>]")
+	}
+	id, err := peer.Decode(args[1])
+	if err != nil {
+		return err
+	}
+	removed, err := store.Remove(id)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("%s is not a paired device", id)
+	}
+	fmt.Fprintf(w, "Removed %s, it has to pair again to sync. A running clipp2p disconnects it within seconds.\n", id)
+	return nil
+}
@@ -1,5 +1,28 @@
 package clipboard
 
+import "context"
+
+// Format identifies the kind of data held in the clipboard
+type Format int
+
+const (
+	// FormatText is UTF-8 encoded plain text
+	FormatText Format = iota
+	// FormatImage is a PNG encoded image
+	FormatImage
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatText:
+		return "text"
+	case FormatImage:
+		return "image"
+	default:
+		return "unknown"
+	}
+}
+
 // Clipboard defines the interface for OS clipboard operations
 type Clipboard interface {
 	// Read returns the current clipboard content
@@ -8,3 +31,33 @@ type Clipboard interface {
 	// Write sets the clipboard content
 	Write(content string) error
 }
+
+// ImageClipboard is implemented by clipboards that can also hold images.
+// Image data is always PNG encoded.
+type ImageClipboard interface {
+	Clipboard
+
+	// ReadImage returns the current clipboard image, or nil if there is none
+	ReadImage() ([]byte, error)
+
+	// WriteImage sets the clipboard content to the given PNG image
+	WriteImage(data []byte) error
+}
+
+// Notifier is implemented by clipboards that can report changes themselves,
+// letting the Watcher avoid polling.
+type Notifier interface {
+	// Watch returns a channel that receives a value whenever the clipboard
+	// may have changed. The channel is closed once ctx is done or the
+	// clipboard can no longer deliver notifications.
+	Watch(ctx context.Context) <-chan struct{}
+}
+
+// ChangeHinter is implemented by clipboards that learn of some changes
+// without polling, such as content they wrote being replaced. The Watcher
+// checks the clipboard on every hint on top of its usual detection.
+type ChangeHinter interface {
+	// Hints returns a channel that receives a value whenever the clipboard
+	// may have changed. The channel is closed once ctx is done.
+	Hints(ctx context.Context) <-chan struct{}
+}
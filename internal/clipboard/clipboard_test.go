@@ -43,3 +43,18 @@ func TestMockClipboard_Overwrite(t *testing.T) {
 	assert.NoError(t, err, "Read() should succeed after Write()")
 	assert.Equal(t, "second", content, "Read() should return the last written content")
 }
+
+func TestMockClipboard_ImageReplacesText(t *testing.T) {
+	mock := NewMockClipboard()
+
+	assert.NoError(t, mock.Write("some text"), "Write() should succeed")
+	assert.NoError(t, mock.WriteImage([]byte{0x89, 'P', 'N', 'G'}), "WriteImage() should succeed")
+
+	content, err := mock.Read()
+	assert.NoError(t, err, "Read() should succeed after WriteImage()")
+	assert.Empty(t, content, "Read() should return empty string once an image is copied")
+
+	data, err := mock.ReadImage()
+	assert.NoError(t, err, "ReadImage() should succeed after WriteImage()")
+	assert.Equal(t, []byte{0x89, 'P', 'N', 'G'}, data, "ReadImage() should return the written image")
+}
@@ -0,0 +1,207 @@
+package clipboard
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultCommandTimeout bounds how long a clipboard command may run
+const DefaultCommandTimeout = 2 * time.Second
+
+// CommandConfig describes the external programs used to access a clipboard.
+// Read must print the clipboard to stdout, Write must read it from stdin.
+// The optional Types command lists the MIME types offered for the current
+// content, one per line, and is used to spot password manager hints.
+type CommandConfig struct {
+	Read    []string
+	Write   []string
+	Types   []string
+	Timeout time.Duration
+}
+
+// ParseCommandConfig builds a config from generic read and write command
+// templates such as "wl-paste --no-newline". Arguments are split on
+// whitespace, quoting is not supported.
+func ParseCommandConfig(read, write string) (CommandConfig, error) {
+	cfg := CommandConfig{
+		Read:  strings.Fields(read),
+		Write: strings.Fields(write),
+	}
+	if len(cfg.Read) == 0 || len(cfg.Write) == 0 {
+		return CommandConfig{}, errors.New("clipboard command needs both a read and a write command")
+	}
+	return cfg, nil
+}
+
+// commandPreset holds the commands of a well-known clipboard tool
+type commandPreset struct {
+	name string
+	// env must be set for the tool to work, empty if it has no requirement
+	env       string
+	clipboard CommandConfig
+	primary   *CommandConfig
+}
+
+// commandPresets are ordered by preference when probing the session
+var commandPresets = []commandPreset{
+	{
+		name: "wl-clipboard",
+		env:  "WAYLAND_DISPLAY",
+		clipboard: CommandConfig{
+			Read:  []string{"wl-paste", "--no-newline"},
+			Write: []string{"wl-copy"},
+			Types: []string{"wl-paste", "--list-types"},
+		},
+		primary: &CommandConfig{
+			Read:  []string{"wl-paste", "--primary", "--no-newline"},
+			Write: []string{"wl-copy", "--primary"},
+			Types: []string{"wl-paste", "--primary", "--list-types"},
+		},
+	},
+	{
+		name: "xclip",
+		env:  "DISPLAY",
+		clipboard: CommandConfig{
+			Read:  []string{"xclip", "-selection", "clipboard", "-o"},
+			Write: []string{"xclip", "-selection", "clipboard", "-i"},
+			Types: []string{"xclip", "-selection", "clipboard", "-o", "-t", "TARGETS"},
+		},
+		primary: &CommandConfig{
+			Read:  []string{"xclip", "-selection", "primary", "-o"},
+			Write: []string{"xclip", "-selection", "primary", "-i"},
+			Types: []string{"xclip", "-selection", "primary", "-o", "-t", "TARGETS"},
+		},
+	},
+	{
+		name: "xsel",
+		env:  "DISPLAY",
+		clipboard: CommandConfig{
+			Read:  []string{"xsel", "--clipboard", "--output"},
+			Write: []string{"xsel", "--clipboard", "--input"},
+		},
+		primary: &CommandConfig{
+			Read:  []string{"xsel", "--primary", "--output"},
+			Write: []string{"xsel", "--primary", "--input"},
+		},
+	},
+	{
+		name: "pbcopy",
+		clipboard: CommandConfig{
+			Read:  []string{"pbpaste"},
+			Write: []string{"pbcopy"},
+		},
+	},
+}
+
+// CommandPresetNames lists the built-in command presets in preference order
+func CommandPresetNames() []string {
+	names := make([]string, 0, len(commandPresets))
+	for _, p := range commandPresets {
+		names = append(names, p.name)
+	}
+	return names
+}
+
+// CommandPreset returns the commands a built-in preset uses for a selection
+func CommandPreset(name string, sel Selection) (CommandConfig, error) {
+	for _, p := range commandPresets {
+		if p.name != name {
+			continue
+		}
+		if sel == SelectionPrimary {
+			if p.primary == nil {
+				return CommandConfig{}, fmt.Errorf("clipboard preset %q has no primary selection", name)
+			}
+			return *p.primary, nil
+		}
+		return p.clipboard, nil
+	}
+	return CommandConfig{}, fmt.Errorf("unknown clipboard preset %q", name)
+}
+
+// CommandClipboard implements the Clipboard interface by running external
+// programs such as wl-copy, xclip or pbcopy.
+type CommandClipboard struct {
+	cfg CommandConfig
+}
+
+// NewCommandClipboard checks that both commands are installed.
+func NewCommandClipboard(cfg CommandConfig) (*CommandClipboard, error) {
+	if len(cfg.Read) == 0 || len(cfg.Write) == 0 {
+		return nil, errors.New("clipboard command needs both a read and a write command")
+	}
+	if _, err := exec.LookPath(cfg.Read[0]); err != nil {
+		return nil, err
+	}
+	if _, err := exec.LookPath(cfg.Write[0]); err != nil {
+		return nil, err
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultCommandTimeout
+	}
+	return &CommandClipboard{cfg: cfg}, nil
+}
+
+// Read runs the read command and returns its output.
+func (c *CommandClipboard) Read() (string, error) {
+	var stdout bytes.Buffer
+	if err := runCommand(c.cfg.Read, c.cfg.Timeout, nil, &stdout); err != nil {
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// Write runs the write command with content on stdin.
+func (c *CommandClipboard) Write(content string) error {
+	return runCommand(c.cfg.Write, c.cfg.Timeout, strings.NewReader(content), nil)
+}
+
+// Concealed runs the types command, if any, and looks for password
+// manager hints. Content is reported as concealed if the command fails.
+func (c *CommandClipboard) Concealed() (bool, error) {
+	if len(c.cfg.Types) == 0 {
+		return false, nil
+	}
+	return typesConcealed(c.cfg.Types, c.cfg.Timeout)
+}
+
+// runCommand runs args with a timeout, surfacing stderr in errors
+func runCommand(args []string, timeout time.Duration, stdin io.Reader, stdout io.Writer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
+	cmd.Stderr = &stderr
+
+	// Tools like xclip and wl-copy fork a daemon that keeps serving the
+	// selection and inherits our pipes, so don't wait for them to close
+	cmd.WaitDelay = 100 * time.Millisecond
+
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		err = nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s: timed out after %s", args[0], timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %w: %s", args[0], err, msg)
+		}
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package clipboard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeScript creates an executable shell script and returns its path
+func writeScript(t *testing.T, dir, name, body string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755)
+	assert.NoError(t, err)
+	return path
+}
+
+func TestCommandClipboard_ReadWrite(t *testing.T) {
+	dir := t.TempDir()
+	store := filepath.Join(dir, "store")
+	read := writeScript(t, dir, "paste", `cat "`+store+`"`)
+	write := writeScript(t, dir, "copy", `cat > "`+store+`"`)
+
+	cb, err := NewCommandClipboard(CommandConfig{Read: []string{read}, Write: []string{write}})
+	assert.NoError(t, err, "NewCommandClipboard() should accept existing commands")
+
+	assert.NoError(t, cb.Write("from a script"), "Write() should succeed")
+
+	content, err := cb.Read()
+	assert.NoError(t, err, "Read() should succeed after Write()")
+	assert.Equal(t, "from a script", content)
+}
+
+func TestCommandClipboard_StderrSurfaced(t *testing.T) {
+	dir := t.TempDir()
+	read := writeScript(t, dir, "paste", "echo 'no selection owner' >&2\nexit 1")
+
+	cb, err := NewCommandClipboard(CommandConfig{Read: []string{read}, Write: []string{read}})
+	assert.NoError(t, err)
+
+	_, err = cb.Read()
+	assert.ErrorContains(t, err, "no selection owner", "Read() should include stderr in the error")
+}
+
+func TestCommandClipboard_Timeout(t *testing.T) {
+	dir := t.TempDir()
+	read := writeScript(t, dir, "paste", "exec sleep 5")
+
+	cb, err := NewCommandClipboard(CommandConfig{
+		Read:    []string{read},
+		Write:   []string{read},
+		Timeout: 50 * time.Millisecond,
+	})
+	assert.NoError(t, err)
+
+	start := time.Now()
+	_, err = cb.Read()
+	assert.ErrorContains(t, err, "timed out")
+	assert.Less(t, time.Since(start), 2*time.Second, "Read() should give up at the timeout")
+}
+
+func TestCommandClipboard_BackgroundedWriter(t *testing.T) {
+	dir := t.TempDir()
+	store := filepath.Join(dir, "store")
+
+	// Mimic xclip, which keeps a child alive holding our pipes
+	write := writeScript(t, dir, "copy", `cat > "`+store+`"
+(sleep 5) &`)
+
+	cb, err := NewCommandClipboard(CommandConfig{Read: []string{write}, Write: []string{write}})
+	assert.NoError(t, err)
+
+	start := time.Now()
+	assert.NoError(t, cb.Write("daemonized"))
+	assert.Less(t, time.Since(start), 2*time.Second, "Write() should not wait for forked children")
+}
+
+func TestNewCommandClipboard_MissingCommand(t *testing.T) {
+	_, err := NewCommandClipboard(CommandConfig{
+		Read:  []string{"clipp2p-no-such-command"},
+		Write: []string{"clipp2p-no-such-command"},
+	})
+	assert.Error(t, err)
+}
+
+func TestParseCommandConfig(t *testing.T) {
+	cfg, err := ParseCommandConfig("wl-paste --no-newline", "wl-copy")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"wl-paste", "--no-newline"}, cfg.Read)
+	assert.Equal(t, []string{"wl-copy"}, cfg.Write)
+
+	_, err = ParseCommandConfig("wl-paste", "")
+	assert.Error(t, err, "ParseCommandConfig() should require a write command")
+}
+
+func TestCommandPreset(t *testing.T) {
+	cfg, err := CommandPreset("xclip", SelectionPrimary)
+	assert.NoError(t, err)
+	assert.Contains(t, cfg.Read, "primary")
+
+	_, err = CommandPreset("pbcopy", SelectionPrimary)
+	assert.Error(t, err, "macOS has no primary selection")
+
+	_, err = CommandPreset("nonexistent", SelectionClipboard)
+	assert.Error(t, err)
+}
+
+func TestCommandClipboard_Concealed(t *testing.T) {
+	dir := t.TempDir()
+	read := writeScript(t, dir, "paste", "echo hunter2")
+	types := writeScript(t, dir, "types", "printf 'text/plain\\nx-kde-passwordManagerHint\\n'")
+
+	cb, err := NewCommandClipboard(CommandConfig{Read: []string{read}, Write: []string{read}, Types: []string{types}})
+	assert.NoError(t, err)
+
+	concealed, err := cb.Concealed()
+	assert.NoError(t, err)
+	assert.True(t, concealed, "a password manager hint should be reported")
+
+	withoutTypes, err := NewCommandClipboard(CommandConfig{Read: []string{read}, Write: []string{read}})
+	assert.NoError(t, err)
+
+	concealed, err = withoutTypes.Concealed()
+	assert.NoError(t, err)
+	assert.False(t, concealed, "without a types command nothing is concealed")
+
+	failing, err := NewCommandClipboard(CommandConfig{Read: []string{read}, Write: []string{read}, Types: []string{writeScript(t, dir, "broken", "exit 1")}})
+	assert.NoError(t, err)
+
+	concealed, err = failing.Concealed()
+	assert.Error(t, err)
+	assert.True(t, concealed, "content whose types can't be read is treated as secret")
+}
@@ -0,0 +1,76 @@
+package clipboard
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FileClipboard implements the Clipboard interface on top of a plain file,
+// so scripts on headless machines can read the latest received clip and
+// broadcast new content by writing to the file.
+type FileClipboard struct {
+	path string
+}
+
+// NewFileClipboard uses the file at path, creating it and its directory
+// if needed.
+func NewFileClipboard(path string) (*FileClipboard, error) {
+	if path == "" {
+		return nil, errors.New("file clipboard needs a path")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	return &FileClipboard{path: path}, nil
+}
+
+// Path returns the file backing the clipboard.
+func (f *FileClipboard) Path() string {
+	return f.path
+}
+
+// Read returns the file content. A missing file reads as empty.
+func (f *FileClipboard) Read() (string, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Write replaces the file content atomically, readers never see a
+// partially written clip.
+func (f *FileClipboard) Write(content string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), "."+filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
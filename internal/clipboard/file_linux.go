@@ -0,0 +1,69 @@
+package clipboard
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Watch uses inotify on the containing directory, so atomic replacements
+// of the file are seen as well as in-place writes.
+func (f *FileClipboard) Watch(ctx context.Context) <-chan struct{} {
+	out := make(chan struct{}, 1)
+
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		close(out)
+		return out
+	}
+
+	mask := uint32(unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_CREATE | unix.IN_DELETE)
+	if _, err := unix.InotifyAddWatch(fd, filepath.Dir(f.path), mask); err != nil {
+		unix.Close(fd)
+		close(out)
+		return out
+	}
+
+	// A non-blocking descriptor goes through the runtime poller, which
+	// lets Close interrupt a pending Read
+	events := os.NewFile(uintptr(fd), "inotify")
+	name := []byte(filepath.Base(f.path))
+
+	go func() {
+		<-ctx.Done()
+		events.Close()
+	}()
+
+	go func() {
+		defer close(out)
+
+		buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+		for {
+			n, err := events.Read(buf)
+			if err != nil {
+				return
+			}
+
+			for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+				event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				start := offset + unix.SizeofInotifyEvent
+				end := start + int(event.Len)
+				offset = end
+
+				if event.Mask&unix.IN_IGNORED != 0 {
+					// The directory itself went away
+					return
+				}
+				if bytes.Equal(bytes.TrimRight(buf[start:end], "\x00"), name) {
+					signal(out)
+				}
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package clipboard
+
+import "context"
+
+// Watch is not supported without inotify. The closed channel makes the
+// Watcher fall back to polling the file.
+func (f *FileClipboard) Watch(ctx context.Context) <-chan struct{} {
+	out := make(chan struct{})
+	close(out)
+	return out
+}
@@ -0,0 +1,82 @@
+package clipboard
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileClipboard_ReadWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "relay", "clip.txt")
+
+	cb, err := NewFileClipboard(path)
+	assert.NoError(t, err, "NewFileClipboard() should create missing directories")
+
+	content, err := cb.Read()
+	assert.NoError(t, err)
+	assert.Empty(t, content, "a new file clipboard should be empty")
+
+	assert.NoError(t, cb.Write("relayed"))
+
+	content, err = cb.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, "relayed", content)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "clips should only be readable by the owner")
+}
+
+func TestFileClipboard_WriteLeavesNoTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	cb, err := NewFileClipboard(filepath.Join(dir, "clip.txt"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, cb.Write("first"))
+	assert.NoError(t, cb.Write("second"))
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries), "atomic writes should clean up their temp files")
+}
+
+func TestFileClipboard_WatcherSeesExternalWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clip.txt")
+	cb, err := NewFileClipboard(path)
+	assert.NoError(t, err)
+
+	// With inotify a long poll interval proves changes are pushed
+	interval := 10 * time.Millisecond
+	if runtime.GOOS == "linux" {
+		interval = time.Hour
+	}
+
+	var mu sync.Mutex
+	var changes []string
+
+	watcher := NewWatcher(cb, interval, func(change ClipboardChange) {
+		mu.Lock()
+		changes = append(changes, change.Content)
+		mu.Unlock()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watcher.Start(ctx)
+
+	time.Sleep(20 * time.Millisecond)
+
+	assert.NoError(t, os.WriteFile(path, []byte("from a script"), 0o600))
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, []string{"from a script"}, changes)
+}
@@ -0,0 +1,70 @@
+package clipboard
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// concealedTypes are the clipboard types password managers attach to
+// copied secrets, asking clipboard tools not to record or share them. Types
+// are only listed on X11 and Wayland, so macOS and Windows hints such as
+// org.nspasteboard.ConcealedType are not seen.
+var concealedTypes = map[string]bool{
+	// KDE Klipper convention, used by KeePassXC on Linux
+	"x-kde-passwordManagerHint": true,
+	// nspasteboard.org convention, as exposed on X11 and Wayland
+	"application/x-nspasteboard-concealed-type": true,
+}
+
+// ConcealedReporter is implemented by clipboards that can tell whether the
+// application that copied the current content marked it as sensitive
+type ConcealedReporter interface {
+	// Concealed reports whether the current content carries a password
+	// manager hint
+	Concealed() (bool, error)
+}
+
+// hasConcealedType reports whether a newline separated type listing
+// contains a password manager hint
+func hasConcealedType(listing []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(listing))
+	for scanner.Scan() {
+		if concealedTypes[strings.TrimSpace(scanner.Text())] {
+			return true
+		}
+	}
+	return false
+}
+
+// typesConcealed runs a command listing clipboard types and checks it for
+// password manager hints. If the command fails the content is reported as
+// concealed, a secret must not be synced because its types went unread.
+func typesConcealed(args []string, timeout time.Duration) (bool, error) {
+	var stdout bytes.Buffer
+	if err := runCommand(args, timeout, nil, &stdout); err != nil {
+		return true, err
+	}
+	return hasConcealedType(stdout.Bytes()), nil
+}
+
+// findTypesCommand returns an installed command able to list the types of
+// the regular clipboard in this session, or nil
+func findTypesCommand() []string {
+	for _, preset := range commandPresets {
+		types := preset.clipboard.Types
+		if len(types) == 0 {
+			continue
+		}
+		if preset.env != "" && os.Getenv(preset.env) == "" {
+			continue
+		}
+		if _, err := exec.LookPath(types[0]); err == nil {
+			return types
+		}
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package clipboard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasConcealedType(t *testing.T) {
+	keepass := []byte("text/plain;charset=utf-8\nUTF8_STRING\nx-kde-passwordManagerHint\n")
+	assert.True(t, hasConcealedType(keepass))
+
+	plain := []byte("TARGETS\nTIMESTAMP\nUTF8_STRING\ntext/plain\n")
+	assert.False(t, hasConcealedType(plain))
+}
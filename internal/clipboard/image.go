@@ -0,0 +1,17 @@
+package clipboard
+
+import (
+	"bytes"
+	"image"
+	_ "image/png"
+)
+
+// ImageSize decodes the width and height of PNG image data without
+// decoding the full image.
+func ImageSize(data []byte) (width, height int, err error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, err
+	}
+	return cfg.Width, cfg.Height, nil
+}
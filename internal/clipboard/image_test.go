@@ -0,0 +1,34 @@
+package clipboard
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// encodeTestPNG returns a blank PNG of the given size
+func encodeTestPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)))
+	assert.NoError(t, err)
+	return buf.Bytes()
+}
+
+func TestImageSize(t *testing.T) {
+	data := encodeTestPNG(t, 64, 32)
+
+	width, height, err := ImageSize(data)
+	assert.NoError(t, err, "ImageSize() should decode a valid PNG")
+	assert.Equal(t, 64, width)
+	assert.Equal(t, 32, height)
+}
+
+func TestImageSize_InvalidData(t *testing.T) {
+	_, _, err := ImageSize([]byte("not an image"))
+	assert.Error(t, err, "ImageSize() should fail on non-image data")
+}
@@ -1,11 +1,16 @@
 package clipboard
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 // MockClipboard is a mock clipboard that stores content in memory
 type MockClipboard struct {
-	mu      sync.RWMutex
-	content string
+	mu        sync.RWMutex
+	content   string
+	image     []byte
+	concealed bool
 }
 
 func NewMockClipboard() *MockClipboard {
@@ -22,12 +27,115 @@ func (m *MockClipboard) Write(content string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.content = content
+	m.image = nil
+	m.concealed = false
 	return nil
 }
 
+func (m *MockClipboard) ReadImage() ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.image, nil
+}
+
+func (m *MockClipboard) WriteImage(data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.image = data
+	m.content = ""
+	m.concealed = false
+	return nil
+}
+
+func (m *MockClipboard) Concealed() (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.concealed, nil
+}
+
 // SetContent is a test helper to simulate external clipboard changes.
 func (m *MockClipboard) SetContent(content string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.content = content
+	m.image = nil
+	m.concealed = false
+}
+
+// SetConcealedContent is a test helper to simulate a password manager
+// copying a secret.
+func (m *MockClipboard) SetConcealedContent(content string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.content = content
+	m.image = nil
+	m.concealed = true
+}
+
+// SetImage is a test helper to simulate an image being copied externally.
+func (m *MockClipboard) SetImage(data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.image = data
+	m.content = ""
+	m.concealed = false
+}
+
+// NotifyingMockClipboard is a MockClipboard that also implements Notifier,
+// signalling watchers on every external change.
+type NotifyingMockClipboard struct {
+	*MockClipboard
+
+	mu       sync.Mutex
+	watchers []chan struct{}
+}
+
+func NewNotifyingMockClipboard() *NotifyingMockClipboard {
+	return &NotifyingMockClipboard{MockClipboard: NewMockClipboard()}
+}
+
+func (m *NotifyingMockClipboard) Watch(ctx context.Context) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+
+	m.mu.Lock()
+	m.watchers = append(m.watchers, ch)
+	m.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		m.CloseWatchers()
+	}()
+
+	return ch
+}
+
+// SetContent simulates an external text change and notifies watchers.
+func (m *NotifyingMockClipboard) SetContent(content string) {
+	m.MockClipboard.SetContent(content)
+	m.notify()
+}
+
+// SetImage simulates an external image copy and notifies watchers.
+func (m *NotifyingMockClipboard) SetImage(data []byte) {
+	m.MockClipboard.SetImage(data)
+	m.notify()
+}
+
+// CloseWatchers is a test helper to simulate a backend losing the ability
+// to deliver notifications.
+func (m *NotifyingMockClipboard) CloseWatchers() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ch := range m.watchers {
+		close(ch)
+	}
+	m.watchers = nil
+}
+
+func (m *NotifyingMockClipboard) notify() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ch := range m.watchers {
+		signal(ch)
+	}
 }
@@ -0,0 +1,220 @@
+package clipboard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aymanbagabas/go-osc52/v2"
+	"github.com/charmbracelet/x/term"
+)
+
+// DefaultOSC52QueryTimeout is how long to wait for a terminal to answer
+// an OSC 52 clipboard query
+const DefaultOSC52QueryTimeout = 200 * time.Millisecond
+
+// OSC52Config controls how the OSC 52 clipboard talks to the terminal
+type OSC52Config struct {
+	// Query enables reading the clipboard by asking the terminal for it.
+	// Leave it off while something else, like the TUI, reads the terminal,
+	// since the answer would be consumed by whoever reads first.
+	Query bool
+	// QueryTimeout bounds the wait for an answer, after which the
+	// clipboard degrades to write-only
+	QueryTimeout time.Duration
+}
+
+// OSC52Clipboard implements the Clipboard interface by sending OSC 52
+// escape sequences to a terminal, which places the text in the clipboard of
+// the machine the terminal emulator runs on. This works across SSH without
+// a display server on the remote side.
+//
+// Many terminals refuse OSC 52 queries. When querying is disabled or the
+// terminal doesn't answer, the clipboard is write-only: Read returns the
+// last written text, so received clips are delivered but local copies made
+// in the terminal are not detected.
+type OSC52Clipboard struct {
+	tty  *os.File
+	cfg  OSC52Config
+	mode osc52.Mode
+
+	mu        sync.Mutex
+	last      string
+	writeOnly bool
+}
+
+// OpenOSC52Clipboard uses the controlling terminal of the process.
+func OpenOSC52Clipboard(cfg OSC52Config) (*OSC52Clipboard, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return NewOSC52Clipboard(tty, cfg), nil
+}
+
+// NewOSC52Clipboard uses the given terminal. Sequences are wrapped for tmux
+// or screen when running inside them.
+func NewOSC52Clipboard(tty *os.File, cfg OSC52Config) *OSC52Clipboard {
+	if cfg.QueryTimeout <= 0 {
+		cfg.QueryTimeout = DefaultOSC52QueryTimeout
+	}
+
+	mode := osc52.DefaultMode
+	if os.Getenv("TMUX") != "" {
+		mode = osc52.TmuxMode
+	} else if strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		mode = osc52.ScreenMode
+	}
+
+	return &OSC52Clipboard{
+		tty:       tty,
+		cfg:       cfg,
+		mode:      mode,
+		writeOnly: !cfg.Query,
+	}
+}
+
+// WriteOnly reports whether reads have been given up on.
+func (o *OSC52Clipboard) WriteOnly() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.writeOnly
+}
+
+// Write sends the text to the terminal clipboard.
+func (o *OSC52Clipboard) Write(content string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, err := osc52.New(content).Mode(o.mode).WriteTo(o.tty); err != nil {
+		return err
+	}
+	o.last = content
+	return nil
+}
+
+// Read asks the terminal for its clipboard. In write-only mode it returns
+// the last written text instead.
+func (o *OSC52Clipboard) Read() (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.writeOnly {
+		return o.last, nil
+	}
+
+	content, err := o.query()
+	if err != nil {
+		// Terminals that don't support queries stay silent, stop asking
+		o.writeOnly = true
+		return o.last, nil
+	}
+	return content, nil
+}
+
+// Close releases the terminal.
+func (o *OSC52Clipboard) Close() error {
+	return o.tty.Close()
+}
+
+// query sends an OSC 52 query and parses the terminal's answer
+func (o *OSC52Clipboard) query() (string, error) {
+	// Answers arrive as input, which must not wait for a newline. Fd()
+	// would switch the file to blocking mode and break read deadlines,
+	// so the raw file descriptor is only borrowed.
+	restore, err := o.makeRaw()
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	if _, err := osc52.Query().Mode(o.mode).WriteTo(o.tty); err != nil {
+		return "", err
+	}
+
+	if err := o.tty.SetReadDeadline(time.Now().Add(o.cfg.QueryTimeout)); err != nil {
+		return "", err
+	}
+	defer o.tty.SetReadDeadline(time.Time{})
+
+	var resp []byte
+	buf := make([]byte, 4096)
+	for {
+		n, err := o.tty.Read(buf)
+		resp = append(resp, buf[:n]...)
+		if content, ok, perr := parseOSC52Response(resp); ok || perr != nil {
+			return content, perr
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// makeRaw switches the terminal to raw mode and returns a function
+// restoring the previous state
+func (o *OSC52Clipboard) makeRaw() (func(), error) {
+	conn, err := o.tty.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var state *term.State
+	var rawErr error
+	err = conn.Control(func(fd uintptr) {
+		if term.IsTerminal(fd) {
+			state, rawErr = term.MakeRaw(fd)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rawErr != nil {
+		return nil, rawErr
+	}
+
+	return func() {
+		if state != nil {
+			conn.Control(func(fd uintptr) {
+				term.Restore(fd, state)
+			})
+		}
+	}, nil
+}
+
+var errMalformedOSC52 = errors.New("malformed OSC 52 response")
+
+// parseOSC52Response extracts the clipboard from a complete answer of the
+// form ESC ] 52 ; c ; base64 terminated by BEL or ESC \. It reports false
+// while the answer is still incomplete.
+func parseOSC52Response(resp []byte) (string, bool, error) {
+	start := bytes.Index(resp, []byte("\x1b]52;"))
+	if start < 0 {
+		return "", false, nil
+	}
+	body := resp[start+len("\x1b]52;"):]
+
+	end := bytes.IndexByte(body, '\a')
+	if st := bytes.Index(body, []byte("\x1b\\")); st >= 0 && (end < 0 || st < end) {
+		end = st
+	}
+	if end < 0 {
+		return "", false, nil
+	}
+	body = body[:end]
+
+	// Skip the selection parameter
+	if i := bytes.IndexByte(body, ';'); i >= 0 {
+		body = body[i+1:]
+	}
+
+	data, err := base64.StdEncoding.DecodeString(string(body))
+	if err != nil {
+		return "", false, errMalformedOSC52
+	}
+	return string(data), true, nil
+}
@@ -0,0 +1,101 @@
+package clipboard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readSequence reads from the terminal side until an OSC 52 sequence ends
+func readSequence(t *testing.T, terminal interface{ Read([]byte) (int, error) }) string {
+	t.Helper()
+
+	var out []byte
+	buf := make([]byte, 1024)
+	for !bytes.ContainsRune(out, '\a') {
+		n, err := terminal.Read(buf)
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		out = append(out, buf[:n]...)
+	}
+	return string(out)
+}
+
+func TestOSC52Clipboard_Write(t *testing.T) {
+	t.Setenv("TMUX", "")
+	t.Setenv("TERM", "xterm-256color")
+	terminal, tty := openTestPty(t)
+
+	cb := NewOSC52Clipboard(tty, OSC52Config{})
+	assert.NoError(t, cb.Write("over ssh"))
+
+	encoded := base64.StdEncoding.EncodeToString([]byte("over ssh"))
+	assert.Equal(t, "\x1b]52;c;"+encoded+"\a", readSequence(t, terminal))
+}
+
+func TestOSC52Clipboard_WriteOnlyRead(t *testing.T) {
+	t.Setenv("TMUX", "")
+	_, tty := openTestPty(t)
+
+	cb := NewOSC52Clipboard(tty, OSC52Config{})
+	assert.True(t, cb.WriteOnly(), "querying is off by default")
+
+	assert.NoError(t, cb.Write("remembered"))
+
+	content, err := cb.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, "remembered", content, "write-only Read() should return the last write")
+}
+
+func TestOSC52Clipboard_Query(t *testing.T) {
+	t.Setenv("TMUX", "")
+	t.Setenv("TERM", "xterm-256color")
+	terminal, tty := openTestPty(t)
+
+	cb := NewOSC52Clipboard(tty, OSC52Config{Query: true, QueryTimeout: time.Second})
+
+	// Play the terminal: answer the query with our clipboard
+	go func() {
+		if readSequence(t, terminal) != "\x1b]52;c;?\a" {
+			return
+		}
+		encoded := base64.StdEncoding.EncodeToString([]byte("local copy"))
+		terminal.Write([]byte("\x1b]52;c;" + encoded + "\x1b\\"))
+	}()
+
+	content, err := cb.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, "local copy", content)
+	assert.False(t, cb.WriteOnly())
+}
+
+func TestOSC52Clipboard_QueryUnanswered(t *testing.T) {
+	t.Setenv("TMUX", "")
+	_, tty := openTestPty(t)
+
+	cb := NewOSC52Clipboard(tty, OSC52Config{Query: true, QueryTimeout: 50 * time.Millisecond})
+
+	_, err := cb.Read()
+	assert.NoError(t, err)
+	assert.True(t, cb.WriteOnly(), "a silent terminal should degrade to write-only")
+}
+
+func TestParseOSC52Response(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte("hello"))
+
+	content, ok, err := parseOSC52Response([]byte("\x1b]52;c;" + encoded + "\a"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "hello", content)
+
+	_, ok, err = parseOSC52Response([]byte("\x1b]52;c;" + encoded))
+	assert.NoError(t, err)
+	assert.False(t, ok, "an unterminated answer is incomplete")
+
+	_, _, err = parseOSC52Response([]byte("\x1b]52;c;!!!\a"))
+	assert.Error(t, err)
+}
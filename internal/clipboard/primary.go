@@ -0,0 +1,48 @@
+package clipboard
+
+import (
+	"errors"
+	"os"
+)
+
+// Selection identifies which system selection a clipboard operates on
+type Selection int
+
+const (
+	// SelectionClipboard is the regular copy/paste clipboard
+	SelectionClipboard Selection = iota
+	// SelectionPrimary is the X11 PRIMARY selection used by middle-click paste
+	SelectionPrimary
+)
+
+func (s Selection) String() string {
+	switch s {
+	case SelectionClipboard:
+		return "clipboard"
+	case SelectionPrimary:
+		return "primary"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrPrimaryUnavailable is returned when no tool to access the PRIMARY
+// selection is available in this session
+var ErrPrimaryUnavailable = errors.New("primary selection unavailable: needs wl-clipboard on Wayland or xclip/xsel on X11")
+
+// NewPrimaryClipboard returns a clipboard for the PRIMARY selection backed
+// by the first command preset that supports it in the current session.
+func NewPrimaryClipboard() (*CommandClipboard, error) {
+	for _, preset := range commandPresets {
+		if preset.primary == nil {
+			continue
+		}
+		if preset.env != "" && os.Getenv(preset.env) == "" {
+			continue
+		}
+		if cb, err := NewCommandClipboard(*preset.primary); err == nil {
+			return cb, nil
+		}
+	}
+	return nil, ErrPrimaryUnavailable
+}
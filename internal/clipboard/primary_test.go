@@ -0,0 +1,55 @@
+package clipboard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// installFakeXclip puts an xclip stand-in on PATH that keeps the
+// selection in a file next to it
+func installFakeXclip(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	store := filepath.Join(dir, "selection")
+	script := `#!/bin/sh
+for arg in "$@"; do
+	if [ "$arg" = "-o" ]; then
+		cat "` + store + `" 2>/dev/null
+		exit 0
+	fi
+done
+cat > "` + store + `"
+`
+	err := os.WriteFile(filepath.Join(dir, "xclip"), []byte(script), 0o755)
+	assert.NoError(t, err)
+
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("WAYLAND_DISPLAY", "")
+	t.Setenv("DISPLAY", ":0")
+}
+
+func TestPrimaryClipboard_ReadWrite(t *testing.T) {
+	installFakeXclip(t)
+
+	primary, err := NewPrimaryClipboard()
+	assert.NoError(t, err, "NewPrimaryClipboard() should find xclip")
+
+	assert.NoError(t, primary.Write("middle click"), "Write() should succeed")
+
+	content, err := primary.Read()
+	assert.NoError(t, err, "Read() should succeed after Write()")
+	assert.Equal(t, "middle click", content)
+}
+
+func TestPrimaryClipboard_Unavailable(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("WAYLAND_DISPLAY", "")
+	t.Setenv("DISPLAY", ":0")
+
+	_, err := NewPrimaryClipboard()
+	assert.ErrorIs(t, err, ErrPrimaryUnavailable)
+}
@@ -0,0 +1,44 @@
+//go:build linux
+
+package clipboard
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// openTestPty returns the terminal side and the application side of a new
+// pseudo terminal
+func openTestPty(t *testing.T) (terminal, app *os.File) {
+	t.Helper()
+
+	ptmx, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("no pty support: %v", err)
+	}
+
+	if err := unix.IoctlSetPointerInt(int(ptmx.Fd()), unix.TIOCSPTLCK, 0); err != nil {
+		ptmx.Close()
+		t.Skipf("unlock pty: %v", err)
+	}
+	n, err := unix.IoctlGetInt(int(ptmx.Fd()), unix.TIOCGPTN)
+	if err != nil {
+		ptmx.Close()
+		t.Skipf("pty number: %v", err)
+	}
+
+	pts, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		ptmx.Close()
+		t.Skipf("open pty: %v", err)
+	}
+
+	t.Cleanup(func() {
+		pts.Close()
+		ptmx.Close()
+	})
+	return ptmx, pts
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package clipboard
+
+import (
+	"os"
+	"testing"
+)
+
+func openTestPty(t *testing.T) (terminal, app *os.File) {
+	t.Skip("pty tests only run on linux")
+	return nil, nil
+}
@@ -0,0 +1,181 @@
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Environment is a snapshot of the session properties that decide which
+// clipboard backends can work
+type Environment struct {
+	GOOS           string
+	Display        string
+	WaylandDisplay string
+	SSH            bool
+	TTY            bool
+}
+
+// DetectEnvironment inspects the current process environment
+func DetectEnvironment() Environment {
+	env := Environment{
+		GOOS:           runtime.GOOS,
+		Display:        os.Getenv("DISPLAY"),
+		WaylandDisplay: os.Getenv("WAYLAND_DISPLAY"),
+		SSH:            os.Getenv("SSH_CONNECTION") != "" || os.Getenv("SSH_TTY") != "",
+	}
+	if info, err := os.Stdout.Stat(); err == nil {
+		env.TTY = info.Mode()&os.ModeCharDevice != 0
+	}
+	return env
+}
+
+func (e Environment) String() string {
+	parts := []string{"os=" + e.GOOS}
+	if e.Display != "" {
+		parts = append(parts, "DISPLAY="+e.Display)
+	}
+	if e.WaylandDisplay != "" {
+		parts = append(parts, "WAYLAND_DISPLAY="+e.WaylandDisplay)
+	}
+	if e.SSH {
+		parts = append(parts, "ssh")
+	}
+	if e.TTY {
+		parts = append(parts, "tty")
+	}
+	return strings.Join(parts, " ")
+}
+
+// Candidate is a clipboard backend the resolver may try
+type Candidate struct {
+	Name string
+	// Check returns why the backend cannot work in env, or nil if it may
+	Check func(env Environment) error
+	// Open creates the backend
+	Open func() (Clipboard, error)
+}
+
+// Attempt records why a candidate was rejected
+type Attempt struct {
+	Name string
+	Err  error
+}
+
+// Resolution is the outcome of resolving a backend
+type Resolution struct {
+	Name      string
+	Clipboard Clipboard
+	// Rejected lists the candidates tried before Name, in order
+	Rejected []Attempt
+}
+
+// ErrNoBackend is returned when no candidate could be opened
+var ErrNoBackend = errors.New("no usable clipboard backend")
+
+// Resolve returns the first candidate that passes its check and opens
+// successfully.
+func Resolve(env Environment, candidates []Candidate) (Resolution, error) {
+	var res Resolution
+
+	for _, c := range candidates {
+		if c.Check != nil {
+			if err := c.Check(env); err != nil {
+				res.Rejected = append(res.Rejected, Attempt{Name: c.Name, Err: err})
+				continue
+			}
+		}
+
+		cb, err := c.Open()
+		if err != nil {
+			res.Rejected = append(res.Rejected, Attempt{Name: c.Name, Err: err})
+			continue
+		}
+
+		res.Name = c.Name
+		res.Clipboard = cb
+		return res, nil
+	}
+
+	return res, ErrNoBackend
+}
+
+// DefaultCandidates lists the built-in backends in order of preference:
+// the native OS clipboard, then the command presets, then a write-only
+// OSC 52 terminal clipboard as a last resort for SSH sessions.
+func DefaultCandidates() []Candidate {
+	candidates := []Candidate{
+		{
+			Name:  "system",
+			Check: checkSystem,
+			Open: func() (Clipboard, error) {
+				return NewSystemClipboard()
+			},
+		},
+	}
+
+	for _, preset := range commandPresets {
+		candidates = append(candidates, Candidate{
+			Name: preset.name,
+			Check: func(env Environment) error {
+				return checkPreset(env, preset)
+			},
+			Open: func() (Clipboard, error) {
+				return NewCommandClipboard(preset.clipboard)
+			},
+		})
+	}
+
+	candidates = append(candidates, Candidate{
+		Name:  "osc52",
+		Check: checkOSC52,
+		Open: func() (Clipboard, error) {
+			return OpenOSC52Clipboard(OSC52Config{})
+		},
+	})
+
+	return candidates
+}
+
+func checkSystem(env Environment) error {
+	if env.GOOS != "linux" && env.GOOS != "freebsd" {
+		return nil
+	}
+	if env.Display == "" {
+		if env.SSH {
+			return errors.New("SSH session without X forwarding")
+		}
+		return errors.New("DISPLAY is not set")
+	}
+	return nil
+}
+
+func checkPreset(env Environment, preset commandPreset) error {
+	switch preset.env {
+	case "DISPLAY":
+		if env.Display == "" {
+			return errors.New("DISPLAY is not set")
+		}
+	case "WAYLAND_DISPLAY":
+		if env.WaylandDisplay == "" {
+			return errors.New("WAYLAND_DISPLAY is not set")
+		}
+	}
+
+	for _, args := range [][]string{preset.clipboard.Read, preset.clipboard.Write} {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			return fmt.Errorf("%s not installed", args[0])
+		}
+	}
+	return nil
+}
+
+func checkOSC52(env Environment) error {
+	if !env.TTY {
+		return errors.New("not running in a terminal")
+	}
+	return nil
+}
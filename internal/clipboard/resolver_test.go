@@ -0,0 +1,62 @@
+package clipboard
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolve_SkipsRejectedCandidates(t *testing.T) {
+	mock := NewMockClipboard()
+
+	candidates := []Candidate{
+		{
+			Name:  "needs-display",
+			Check: func(env Environment) error { return errors.New("DISPLAY is not set") },
+			Open:  func() (Clipboard, error) { t.Fatal("rejected candidate opened"); return nil, nil },
+		},
+		{
+			Name: "broken",
+			Open: func() (Clipboard, error) { return nil, errors.New("init failed") },
+		},
+		{
+			Name: "mock",
+			Open: func() (Clipboard, error) { return mock, nil },
+		},
+	}
+
+	res, err := Resolve(Environment{}, candidates)
+	assert.NoError(t, err)
+	assert.Equal(t, "mock", res.Name)
+	assert.Same(t, mock, res.Clipboard)
+
+	assert.Equal(t, 2, len(res.Rejected))
+	assert.Equal(t, "needs-display", res.Rejected[0].Name)
+	assert.EqualError(t, res.Rejected[1].Err, "init failed")
+}
+
+func TestResolve_NoBackend(t *testing.T) {
+	_, err := Resolve(Environment{}, []Candidate{
+		{Name: "broken", Open: func() (Clipboard, error) { return nil, errors.New("nope") }},
+	})
+	assert.ErrorIs(t, err, ErrNoBackend)
+}
+
+func TestCheckSystem(t *testing.T) {
+	assert.NoError(t, checkSystem(Environment{GOOS: "darwin"}))
+	assert.NoError(t, checkSystem(Environment{GOOS: "linux", Display: ":0"}))
+	assert.ErrorContains(t, checkSystem(Environment{GOOS: "linux", SSH: true}), "SSH")
+	assert.Error(t, checkSystem(Environment{GOOS: "linux"}))
+}
+
+func TestDefaultCandidates_PreferSystem(t *testing.T) {
+	candidates := DefaultCandidates()
+	assert.NotEmpty(t, candidates)
+	assert.Equal(t, "system", candidates[0].Name)
+}
+
+func TestCheckOSC52(t *testing.T) {
+	assert.NoError(t, checkOSC52(Environment{TTY: true, SSH: true}))
+	assert.Error(t, checkOSC52(Environment{SSH: true}), "OSC 52 needs a terminal to write to")
+}
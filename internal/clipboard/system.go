@@ -1,11 +1,21 @@
 package clipboard
 
 import (
+	"context"
+	"sync"
+
 	"golang.design/x/clipboard"
 )
 
 // SystemClipboard implements the Clipboard interface using the OS clipboard.
-type SystemClipboard struct{}
+type SystemClipboard struct {
+	mu       sync.Mutex
+	watchers []chan struct{}
+
+	// types lists the clipboard's MIME types, the clipboard library
+	// itself only exposes text and images
+	types []string
+}
 
 // NewSystemClipboard creates a new SystemClipboard.
 // It initializes the underlying clipboard library.
@@ -13,7 +23,7 @@ func NewSystemClipboard() (*SystemClipboard, error) {
 	if err := clipboard.Init(); err != nil {
 		return nil, err
 	}
-	return &SystemClipboard{}, nil
+	return &SystemClipboard{types: findTypesCommand()}, nil
 }
 
 // Read returns the current OS clipboard content as text.
@@ -24,6 +34,83 @@ func (s *SystemClipboard) Read() (string, error) {
 
 // Write sets the OS clipboard content to the given text.
 func (s *SystemClipboard) Write(content string) error {
-	clipboard.Write(clipboard.FmtText, []byte(content))
+	s.watchOwnership(clipboard.Write(clipboard.FmtText, []byte(content)))
+	return nil
+}
+
+// ReadImage returns the current OS clipboard content as a PNG image.
+func (s *SystemClipboard) ReadImage() ([]byte, error) {
+	return clipboard.Read(clipboard.FmtImage), nil
+}
+
+// WriteImage sets the OS clipboard content to the given PNG image.
+func (s *SystemClipboard) WriteImage(data []byte) error {
+	s.watchOwnership(clipboard.Write(clipboard.FmtImage, data))
 	return nil
 }
+
+// Concealed checks the clipboard types for password manager hints. It
+// needs wl-clipboard or xclip, without them content is never concealed.
+// Content whose types can't be listed is reported as concealed.
+func (s *SystemClipboard) Concealed() (bool, error) {
+	if s.types == nil {
+		return false, nil
+	}
+	return typesConcealed(s.types, DefaultCommandTimeout)
+}
+
+// Hints signals as soon as another application takes ownership of content
+// we wrote. SystemClipboard is deliberately not a Notifier: the clipboard
+// library's own watch re-reads the whole clipboard every second, slower
+// and costlier than the Watcher's polling.
+func (s *SystemClipboard) Hints(ctx context.Context) <-chan struct{} {
+	out := make(chan struct{}, 1)
+
+	s.mu.Lock()
+	s.watchers = append(s.watchers, out)
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.removeWatcher(out)
+	}()
+
+	return out
+}
+
+// watchOwnership signals watchers once the given write is overwritten
+func (s *SystemClipboard) watchOwnership(lost <-chan struct{}) {
+	if lost == nil {
+		return
+	}
+
+	go func() {
+		<-lost
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, ch := range s.watchers {
+			signal(ch)
+		}
+	}()
+}
+
+func (s *SystemClipboard) removeWatcher(ch chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, w := range s.watchers {
+		if w == ch {
+			s.watchers = append(s.watchers[:i], s.watchers[i+1:]...)
+			break
+		}
+	}
+	close(ch)
+}
+
+// signal performs a non-blocking send, coalescing pending notifications
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
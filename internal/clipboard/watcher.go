@@ -1,15 +1,47 @@
 package clipboard
 
 import (
+	"bytes"
 	"context"
+	"log"
 	"sync"
 	"time"
 )
 
 // ClipboardChange is a message to indicate clipboard content changed
 type ClipboardChange struct {
+	Format    Format
 	Content   string
+	Data      []byte
 	Timestamp time.Time
+	// Concealed is set when a password manager marked the content as secret
+	Concealed bool
+}
+
+// WatchMode selects how the Watcher detects clipboard changes
+type WatchMode int
+
+const (
+	// WatchAuto uses notifications when the clipboard supports them
+	// and falls back to polling otherwise
+	WatchAuto WatchMode = iota
+	// WatchPoll always polls on the configured interval
+	WatchPoll
+	// WatchNotify waits for change notifications from the clipboard
+	WatchNotify
+)
+
+func (m WatchMode) String() string {
+	switch m {
+	case WatchAuto:
+		return "auto"
+	case WatchPoll:
+		return "poll"
+	case WatchNotify:
+		return "notify"
+	default:
+		return "unknown"
+	}
 }
 
 // Watcher listens to clipboard changes with a callback function
@@ -18,8 +50,13 @@ type Watcher struct {
 	pollInterval time.Duration
 	onChange     func(ClipboardChange)
 
-	mu          sync.Mutex
+	mu sync.Mutex
+	// mode is the configured mode, active the one in use while running,
+	// which drops to polling if notifications stop
+	mode        WatchMode
+	active      WatchMode
 	lastContent string
+	lastImage   []byte
 	running     bool
 	stopCh      chan struct{}
 	doneCh      chan struct{}
@@ -30,12 +67,44 @@ func NewWatcher(cb Clipboard, interval time.Duration, onChange func(ClipboardCha
 		clipboard:    cb,
 		pollInterval: interval,
 		onChange:     onChange,
+		mode:         WatchAuto,
 		stopCh:       make(chan struct{}),
 		doneCh:       make(chan struct{}),
 	}
 }
 
-// Start polls the clipboard for changes.
+// SetMode overrides how changes are detected. It takes effect on the next
+// Start. WatchNotify falls back to polling if the clipboard cannot notify.
+func (w *Watcher) SetMode(mode WatchMode) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.mode = mode
+}
+
+// Mode returns the detection mode in use. Before Start it reports the mode
+// that will be used.
+func (w *Watcher) Mode() WatchMode {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.running {
+		return w.active
+	}
+	return w.resolveMode()
+}
+
+// resolveMode picks the concrete mode for the configured one
+func (w *Watcher) resolveMode() WatchMode {
+	if w.mode == WatchPoll {
+		return WatchPoll
+	}
+	if _, ok := w.clipboard.(Notifier); ok {
+		return WatchNotify
+	}
+	return WatchPoll
+}
+
+// Start watches the clipboard for changes, either by waiting for
+// notifications or by polling.
 func (w *Watcher) Start(ctx context.Context) error {
 	w.mu.Lock()
 	if w.running {
@@ -43,6 +112,7 @@ func (w *Watcher) Start(ctx context.Context) error {
 		return nil
 	}
 	w.running = true
+	w.active = w.resolveMode()
 	w.stopCh = make(chan struct{})
 	w.doneCh = make(chan struct{})
 	w.mu.Unlock()
@@ -53,6 +123,13 @@ func (w *Watcher) Start(ctx context.Context) error {
 		w.lastContent = content
 		w.mu.Unlock()
 	}
+	if img, ok := w.clipboard.(ImageClipboard); ok {
+		if data, err := img.ReadImage(); err == nil {
+			w.mu.Lock()
+			w.lastImage = data
+			w.mu.Unlock()
+		}
+	}
 
 	defer func() {
 		w.mu.Lock()
@@ -61,8 +138,27 @@ func (w *Watcher) Start(ctx context.Context) error {
 		w.mu.Unlock()
 	}()
 
-	ticker := time.NewTicker(w.pollInterval)
-	defer ticker.Stop()
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var events <-chan struct{}
+	var ticks <-chan time.Time
+	var hints <-chan struct{}
+
+	w.mu.Lock()
+	mode := w.active
+	w.mu.Unlock()
+
+	if hinter, ok := w.clipboard.(ChangeHinter); ok {
+		hints = hinter.Hints(watchCtx)
+	}
+	if mode == WatchNotify {
+		events = w.clipboard.(Notifier).Watch(watchCtx)
+	} else {
+		ticker := time.NewTicker(w.pollInterval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
 
 	for {
 		select {
@@ -70,7 +166,31 @@ func (w *Watcher) Start(ctx context.Context) error {
 			return ctx.Err()
 		case <-w.stopCh:
 			return nil
-		case <-ticker.C:
+		case _, ok := <-events:
+			if !ok {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+
+				// Notifications stopped, keep going by polling
+				events = nil
+				ticker := time.NewTicker(w.pollInterval)
+				defer ticker.Stop()
+				ticks = ticker.C
+
+				w.mu.Lock()
+				w.active = WatchPoll
+				w.mu.Unlock()
+				continue
+			}
+			w.poll()
+		case _, ok := <-hints:
+			if !ok {
+				hints = nil
+				continue
+			}
+			w.poll()
+		case <-ticks:
 			w.poll()
 		}
 	}
@@ -78,6 +198,11 @@ func (w *Watcher) Start(ctx context.Context) error {
 
 // poll checks for clipboard changes and fires the callback if changed
 func (w *Watcher) poll() {
+	w.pollText()
+	w.pollImage()
+}
+
+func (w *Watcher) pollText() {
 	content, err := w.clipboard.Read()
 	if err != nil {
 		return
@@ -92,15 +217,73 @@ func (w *Watcher) poll() {
 		w.lastContent = content
 		w.mu.Unlock()
 
+		// An empty read means the clipboard was cleared or now holds
+		// a non-text format, neither of which is worth reporting
+		if content == "" {
+			return
+		}
+
 		if w.onChange != nil {
 			w.onChange(ClipboardChange{
+				Format:    FormatText,
 				Content:   content,
 				Timestamp: time.Now(),
+				Concealed: w.concealed(),
+			})
+		}
+	}
+}
+
+func (w *Watcher) pollImage() {
+	img, ok := w.clipboard.(ImageClipboard)
+	if !ok {
+		return
+	}
+
+	data, err := img.ReadImage()
+	if err != nil {
+		return
+	}
+
+	w.mu.Lock()
+	lastImage := w.lastImage
+	w.mu.Unlock()
+
+	if !bytes.Equal(data, lastImage) {
+		w.mu.Lock()
+		w.lastImage = data
+		w.mu.Unlock()
+
+		if len(data) == 0 {
+			return
+		}
+
+		if w.onChange != nil {
+			w.onChange(ClipboardChange{
+				Format:    FormatImage,
+				Data:      data,
+				Timestamp: time.Now(),
 			})
 		}
 	}
 }
 
+// concealed checks the clipboard for password manager hints. A failed
+// check is logged and treated as concealed, so a secret isn't synced
+// because its hint couldn't be read.
+func (w *Watcher) concealed() bool {
+	reporter, ok := w.clipboard.(ConcealedReporter)
+	if !ok {
+		return false
+	}
+	concealed, err := reporter.Concealed()
+	if err != nil {
+		log.Printf("failed to check clipboard for password manager hints: %v", err)
+		return true
+	}
+	return concealed
+}
+
 // Stop waits and stops the watcher
 func (w *Watcher) Stop() {
 	w.mu.Lock()
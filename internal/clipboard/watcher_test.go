@@ -2,6 +2,7 @@ package clipboard
 
 import (
 	"context"
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"sync"
 	"sync/atomic"
@@ -120,3 +121,224 @@ func TestWatcher_Stop(t *testing.T) {
 
 	assert.False(t, watcher.IsRunning())
 }
+
+func TestWatcher_ImageChange(t *testing.T) {
+	mock := NewMockClipboard()
+	mock.SetContent("initial")
+
+	var mu sync.Mutex
+	var changes []ClipboardChange
+
+	watcher := NewWatcher(mock, 10*time.Millisecond, func(change ClipboardChange) {
+		mu.Lock()
+		changes = append(changes, change)
+		mu.Unlock()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go watcher.Start(ctx)
+
+	time.Sleep(20 * time.Millisecond)
+
+	img := encodeTestPNG(t, 4, 4)
+	mock.SetImage(img)
+	time.Sleep(30 * time.Millisecond)
+
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Copying an image clears the text, which should not be reported
+	assert.Equal(t, 1, len(changes))
+	assert.Equal(t, FormatImage, changes[0].Format)
+	assert.Equal(t, img, changes[0].Data)
+}
+
+func TestWatcher_ModeSelection(t *testing.T) {
+	polling := NewWatcher(NewMockClipboard(), time.Second, nil)
+	assert.Equal(t, WatchPoll, polling.Mode(), "clipboards without notifications should be polled")
+
+	notifying := NewWatcher(NewNotifyingMockClipboard(), time.Second, nil)
+	assert.Equal(t, WatchNotify, notifying.Mode(), "notifying clipboards should be watched")
+
+	notifying.SetMode(WatchPoll)
+	assert.Equal(t, WatchPoll, notifying.Mode(), "an explicit poll mode should be honored")
+}
+
+func TestWatcher_NotifyMode(t *testing.T) {
+	mock := NewNotifyingMockClipboard()
+	mock.SetContent("initial")
+
+	var mu sync.Mutex
+	var changes []string
+
+	// A long poll interval proves changes arrive through notifications
+	watcher := NewWatcher(mock, time.Hour, func(change ClipboardChange) {
+		mu.Lock()
+		changes = append(changes, change.Content)
+		mu.Unlock()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go watcher.Start(ctx)
+
+	time.Sleep(20 * time.Millisecond)
+
+	mock.SetContent("notified")
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, []string{"notified"}, changes)
+}
+
+func TestWatcher_NotifyFallsBackToPolling(t *testing.T) {
+	mock := NewNotifyingMockClipboard()
+	mock.SetContent("initial")
+
+	var ops atomic.Uint64
+
+	watcher := NewWatcher(mock, 10*time.Millisecond, func(change ClipboardChange) {
+		ops.Add(1)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watcher.Start(ctx)
+
+	time.Sleep(20 * time.Millisecond)
+
+	mock.CloseWatchers()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, WatchPoll, watcher.Mode())
+
+	// Bypass notifications entirely, only polling can see this
+	mock.MockClipboard.SetContent("polled")
+	time.Sleep(30 * time.Millisecond)
+
+	assert.Equal(t, 1, int(ops.Load()))
+
+	// The fallback lasts until the watcher stops, not for good
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, WatchNotify, watcher.Mode())
+}
+
+// hintingMockClipboard is a MockClipboard that hints at changes on demand
+type hintingMockClipboard struct {
+	*MockClipboard
+	hints chan struct{}
+}
+
+func (m *hintingMockClipboard) Hints(ctx context.Context) <-chan struct{} {
+	return m.hints
+}
+
+func TestWatcher_PollsOnHint(t *testing.T) {
+	mock := &hintingMockClipboard{MockClipboard: NewMockClipboard(), hints: make(chan struct{}, 1)}
+	mock.SetContent("initial")
+
+	var ops atomic.Uint64
+
+	// A long poll interval proves the hint triggered the check
+	watcher := NewWatcher(mock, time.Hour, func(change ClipboardChange) {
+		ops.Add(1)
+	})
+	assert.Equal(t, WatchPoll, watcher.Mode(), "hints don't replace polling")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watcher.Start(ctx)
+
+	time.Sleep(20 * time.Millisecond)
+
+	mock.SetContent("replaced")
+	mock.hints <- struct{}{}
+	time.Sleep(20 * time.Millisecond)
+
+	assert.Equal(t, 1, int(ops.Load()))
+}
+
+func TestSystemClipboard_NotANotifier(t *testing.T) {
+	var cb Clipboard = &SystemClipboard{}
+	_, notifies := cb.(Notifier)
+	assert.False(t, notifies, "the system clipboard must be polled")
+	_, hints := cb.(ChangeHinter)
+	assert.True(t, hints)
+}
+
+// failingConcealedClipboard can't tell whether its content is concealed
+type failingConcealedClipboard struct {
+	*MockClipboard
+}
+
+func (failingConcealedClipboard) Concealed() (bool, error) {
+	return false, errors.New("types command failed")
+}
+
+func TestWatcher_ConcealsWhenCheckFails(t *testing.T) {
+	mock := NewMockClipboard()
+	mock.SetContent("initial")
+
+	var mu sync.Mutex
+	var changes []ClipboardChange
+	watcher := NewWatcher(failingConcealedClipboard{mock}, 10*time.Millisecond, func(change ClipboardChange) {
+		mu.Lock()
+		changes = append(changes, change)
+		mu.Unlock()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watcher.Start(ctx)
+
+	time.Sleep(20 * time.Millisecond)
+	mock.SetContent("hunter2")
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(changes) == 1 && changes[0].Concealed
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestWatcher_ReportsConcealedContent(t *testing.T) {
+	mock := NewMockClipboard()
+	mock.SetContent("initial")
+
+	var mu sync.Mutex
+	var changes []ClipboardChange
+
+	watcher := NewWatcher(mock, 10*time.Millisecond, func(change ClipboardChange) {
+		mu.Lock()
+		changes = append(changes, change)
+		mu.Unlock()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go watcher.Start(ctx)
+
+	time.Sleep(20 * time.Millisecond)
+
+	mock.SetConcealedContent("hunter2")
+	time.Sleep(30 * time.Millisecond)
+
+	mock.SetContent("public")
+	time.Sleep(30 * time.Millisecond)
+
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, 2, len(changes))
+	assert.True(t, changes[0].Concealed)
+	assert.False(t, changes[1].Concealed)
+}
@@ -0,0 +1,101 @@
+// Package datadir locates the per-user directory where clipp2p keeps its
+// state and writes files there safely.
+package datadir
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// name is the directory created under the platform's data directory
+const name = "clipp2p"
+
+// Default returns the per-user data directory: $XDG_DATA_HOME/clipp2p,
+// ~/.local/share/clipp2p on other Unix systems, and the user config
+// directory on macOS and Windows.
+func Default() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, name), nil
+	}
+
+	switch runtime.GOOS {
+	case "darwin", "windows", "ios", "plan9":
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, name), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", name), nil
+}
+
+// Ensure creates dir, readable only by the user, if it doesn't exist
+func Ensure(dir string) error {
+	if dir == "" {
+		return errors.New("no data directory")
+	}
+	return os.MkdirAll(dir, 0o700)
+}
+
+// WriteFile replaces the file at path atomically with data. The file gets
+// perm even if it existed with a wider mode.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// ReadJSON decodes the JSON file at path into v. A missing file leaves v
+// as it is and reports false.
+func ReadJSON(path string, v any) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// WriteJSON replaces the file at path with v as indented JSON, readable
+// only by the user, creating its directory if needed
+func WriteJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := Ensure(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return WriteFile(path, data, 0o600)
+}
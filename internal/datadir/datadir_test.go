@@ -0,0 +1,95 @@
+package datadir
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefault_XDGDataHome(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dir)
+
+	got, err := Default()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "clipp2p"), got)
+}
+
+func TestDefault_Home(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("home layout is Linux specific")
+	}
+	home := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("HOME", home)
+
+	got, err := Default()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, ".local", "share", "clipp2p"), got)
+}
+
+func TestEnsure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "a", "b")
+
+	assert.NoError(t, Ensure(dir))
+	info, err := os.Stat(dir)
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+	}
+
+	assert.Error(t, Ensure(""))
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	assert.NoError(t, os.WriteFile(path, []byte("old"), 0o644))
+
+	assert.NoError(t, WriteFile(path, []byte("new"), 0o600))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "state.json")
+
+	var missing []string
+	found, err := ReadJSON(path, &missing)
+	assert.NoError(t, err)
+	assert.False(t, found)
+	assert.Nil(t, missing)
+
+	assert.NoError(t, WriteJSON(path, []string{"a", "b"}))
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+
+	var got []string
+	found, err = ReadJSON(path, &got)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []string{"a", "b"}, got)
+
+	assert.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+	_, err = ReadJSON(path, &got)
+	assert.Error(t, err)
+}
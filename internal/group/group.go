@@ -0,0 +1,149 @@
+// Package group keeps the private sync group this device belongs to. A
+// group's secret derives both its mDNS service tag and the libp2p
+// private network key, so only members find each other or complete a
+// handshake.
+package group
+
+import (
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/owenHochwald/clipp2p/internal/datadir"
+)
+
+const (
+	// GroupFile is the name of the group file in the data directory
+	GroupFile = "group.json"
+	// SecretSize is the length of a group secret in bytes
+	SecretSize = 32
+	// MaxNameLength bounds group names so invites stay short
+	MaxNameLength = 64
+
+	// invitePrefix marks invite strings, the version follows it
+	invitePrefix  = "clipp2p:"
+	inviteVersion = 1
+)
+
+var (
+	// ErrNoGroup is returned when the device hasn't joined a group
+	ErrNoGroup = errors.New("not in a group")
+	// ErrInvalidInvite is returned for strings that aren't group invites
+	ErrInvalidInvite = errors.New("invalid group invite")
+	// ErrInvalidName is returned for empty or overlong group names
+	ErrInvalidName = errors.New("group name must be 1 to 64 bytes")
+)
+
+// Group is a private sync group
+type Group struct {
+	Name   string `json:"name"`
+	Secret []byte `json:"secret"`
+}
+
+// New creates a group with a fresh secret
+func New(name string) (*Group, error) {
+	if name == "" || len(name) > MaxNameLength {
+		return nil, ErrInvalidName
+	}
+	secret := make([]byte, SecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return &Group{Name: name, Secret: secret}, nil
+}
+
+// Invite encodes the group for sharing with a new member. It carries the
+// secret, so anyone holding it can join.
+func (g *Group) Invite() string {
+	b := []byte{inviteVersion, byte(len(g.Name))}
+	b = append(b, g.Name...)
+	b = append(b, g.Secret...)
+	return invitePrefix + base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ParseInvite decodes a string produced by Invite
+func ParseInvite(invite string) (*Group, error) {
+	encoded, ok := strings.CutPrefix(strings.TrimSpace(invite), invitePrefix)
+	if !ok {
+		return nil, ErrInvalidInvite
+	}
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(b) < 2 || b[0] != inviteVersion {
+		return nil, ErrInvalidInvite
+	}
+	nameLen := int(b[1])
+	if nameLen == 0 || nameLen > MaxNameLength || len(b) != 2+nameLen+SecretSize {
+		return nil, ErrInvalidInvite
+	}
+	return &Group{
+		Name:   string(b[2 : 2+nameLen]),
+		Secret: b[2+nameLen:],
+	}, nil
+}
+
+// ServiceTag returns the mDNS service name members advertise, other
+// instances on the network never see it
+func (g *Group) ServiceTag() string {
+	return "clipp2p-" + hex.EncodeToString(g.derive("clipp2p mdns service tag", 8))
+}
+
+// PSK returns the libp2p private network key
+func (g *Group) PSK() []byte {
+	return g.derive("clipp2p private network key", 32)
+}
+
+func (g *Group) derive(info string, length int) []byte {
+	key, err := hkdf.Key(sha256.New, g.Secret, nil, info, length)
+	if err != nil {
+		// Only fails for lengths beyond what HKDF-SHA256 can produce
+		panic(err)
+	}
+	return key
+}
+
+// Path returns the group file in dir
+func Path(dir string) string {
+	return filepath.Join(dir, GroupFile)
+}
+
+// Load reads the group kept in dir, ErrNoGroup if none was joined
+func Load(dir string) (*Group, error) {
+	var g Group
+	found, err := datadir.ReadJSON(Path(dir), &g)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", GroupFile, err)
+	}
+	if !found {
+		return nil, ErrNoGroup
+	}
+	if len(g.Secret) != SecretSize {
+		return nil, fmt.Errorf("%s holds a secret of %d bytes", GroupFile, len(g.Secret))
+	}
+	return &g, nil
+}
+
+// Save makes g the group of the device, replacing any other
+func Save(dir string, g *Group) error {
+	if err := datadir.Ensure(dir); err != nil {
+		return err
+	}
+	// The file holds the secret, WriteJSON keeps it private
+	return datadir.WriteJSON(Path(dir), g)
+}
+
+// Leave removes the group from dir, reporting whether one was joined
+func Leave(dir string) (bool, error) {
+	err := os.Remove(Path(dir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,87 @@
+package group
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvite_RoundTrip(t *testing.T) {
+	g, err := New("home office")
+	assert.NoError(t, err)
+
+	parsed, err := ParseInvite("  " + g.Invite() + "\n")
+	assert.NoError(t, err)
+	assert.Equal(t, g, parsed)
+}
+
+func TestParseInvite_Invalid(t *testing.T) {
+	g, err := New("home")
+	assert.NoError(t, err)
+	invite := g.Invite()
+
+	for _, s := range []string{
+		"",
+		"home",
+		strings.TrimPrefix(invite, invitePrefix),
+		invite[:len(invite)-4],
+		invite + "AAAA",
+		invitePrefix + "!!!",
+	} {
+		_, err := ParseInvite(s)
+		assert.ErrorIs(t, err, ErrInvalidInvite, s)
+	}
+}
+
+func TestNew_InvalidName(t *testing.T) {
+	_, err := New("")
+	assert.ErrorIs(t, err, ErrInvalidName)
+	_, err = New(strings.Repeat("x", MaxNameLength+1))
+	assert.ErrorIs(t, err, ErrInvalidName)
+}
+
+func TestDerivedKeys(t *testing.T) {
+	g1, _ := New("home")
+	g2, _ := New("home")
+
+	assert.Len(t, g1.PSK(), 32)
+	assert.True(t, strings.HasPrefix(g1.ServiceTag(), "clipp2p-"))
+
+	// The name plays no part, only members know the secret
+	assert.NotEqual(t, g1.ServiceTag(), g2.ServiceTag())
+	assert.NotEqual(t, g1.PSK(), g2.PSK())
+
+	same := &Group{Name: "other", Secret: g1.Secret}
+	assert.Equal(t, g1.ServiceTag(), same.ServiceTag())
+	assert.Equal(t, g1.PSK(), same.PSK())
+}
+
+func TestSaveLoadLeave(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := Load(dir)
+	assert.ErrorIs(t, err, ErrNoGroup)
+
+	g, _ := New("home")
+	assert.NoError(t, Save(dir, g))
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(Path(dir))
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+
+	loaded, err := Load(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, g, loaded)
+
+	left, err := Leave(dir)
+	assert.NoError(t, err)
+	assert.True(t, left)
+
+	left, err = Leave(dir)
+	assert.NoError(t, err)
+	assert.False(t, left)
+}
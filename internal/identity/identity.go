@@ -0,0 +1,223 @@
+// Package identity keeps the node's Ed25519 key across restarts, so its
+// peer ID stays the same and peers can recognise the device.
+package identity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/owenHochwald/clipp2p/internal/datadir"
+)
+
+const (
+	// KeyFile is the name of the key file in the data directory
+	KeyFile = "identity.key"
+	// backupSuffix is appended to the previous key on rotation
+	backupSuffix = ".old"
+	// pemType labels exported and stored keys
+	pemType = "CLIPP2P IDENTITY KEY"
+)
+
+var (
+	// ErrNoIdentity is returned when the data directory has no key yet
+	ErrNoIdentity = errors.New("no identity key")
+	// ErrInsecurePermissions is returned for key files other users can read
+	ErrInsecurePermissions = errors.New("identity key is accessible by other users")
+	// ErrNotEd25519 is returned when importing a key of another type
+	ErrNotEd25519 = errors.New("identity key is not Ed25519")
+)
+
+// Identity is the node's key pair
+type Identity struct {
+	PrivKey crypto.PrivKey
+	ID      peer.ID
+}
+
+func newIdentity(priv crypto.PrivKey) (*Identity, error) {
+	if priv.Type() != crypto.Ed25519 {
+		return nil, ErrNotEd25519
+	}
+	id, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{PrivKey: priv, ID: id}, nil
+}
+
+// Fingerprint returns a short, human comparable digest of the public key
+func (i *Identity) Fingerprint() string {
+	raw, err := i.PrivKey.GetPublic().Raw()
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(raw)
+
+	digest := hex.EncodeToString(sum[:8])
+	groups := make([]string, 0, len(digest)/4)
+	for j := 0; j < len(digest); j += 4 {
+		groups = append(groups, digest[j:j+4])
+	}
+	return strings.ToUpper(strings.Join(groups, ":"))
+}
+
+// Path returns the key file in dir
+func Path(dir string) string {
+	return filepath.Join(dir, KeyFile)
+}
+
+// Load reads the identity stored in dir
+func Load(dir string) (*Identity, error) {
+	path := Path(dir)
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNoIdentity
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Like ssh, refuse keys others could have copied
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		return nil, fmt.Errorf("%w: %s has mode %v, run chmod 600", ErrInsecurePermissions, path, info.Mode().Perm())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
+
+// LoadOrCreate reads the identity stored in dir, generating and storing a
+// new one on first run
+func LoadOrCreate(dir string) (*Identity, error) {
+	id, err := Load(dir)
+	if !errors.Is(err, ErrNoIdentity) {
+		return id, err
+	}
+
+	id, err = generate()
+	if err != nil {
+		return nil, err
+	}
+	if err := store(dir, id); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// Rotate replaces the identity in dir with a new key. The previous key is
+// kept next to it with a .old suffix.
+func Rotate(dir string) (*Identity, error) {
+	id, err := generate()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := backup(dir); err != nil {
+		return nil, err
+	}
+	if err := store(dir, id); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// Export writes the identity in dir to w, to be imported on another device
+func Export(dir string, w io.Writer) error {
+	id, err := Load(dir)
+	if err != nil {
+		return err
+	}
+	data, err := encode(id)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Import replaces the identity in dir with an exported one read from r.
+// The previous key is kept next to it with a .old suffix.
+func Import(dir string, r io.Reader) (*Identity, error) {
+	data, err := io.ReadAll(io.LimitReader(r, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+	id, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := backup(dir); err != nil {
+		return nil, err
+	}
+	if err := store(dir, id); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+func generate() (*Identity, error) {
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return newIdentity(priv)
+}
+
+// store writes the identity to dir, readable only by the user
+func store(dir string, id *Identity) error {
+	if err := datadir.Ensure(dir); err != nil {
+		return err
+	}
+	data, err := encode(id)
+	if err != nil {
+		return err
+	}
+	return datadir.WriteFile(Path(dir), data, 0o600)
+}
+
+// backup keeps a copy of the current key, if any, before it is replaced
+func backup(dir string) error {
+	data, err := os.ReadFile(Path(dir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return datadir.WriteFile(Path(dir)+backupSuffix, data, 0o600)
+}
+
+func encode(id *Identity) ([]byte, error) {
+	raw, err := crypto.MarshalPrivateKey(id.PrivKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemType, Bytes: raw}), nil
+}
+
+func decode(data []byte) (*Identity, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != pemType {
+		return nil, fmt.Errorf("not a clipp2p identity key")
+	}
+	priv, err := crypto.UnmarshalPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity key: %w", err)
+	}
+	return newIdentity(priv)
+}
@@ -0,0 +1,120 @@
+package identity
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadOrCreate_Persists(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "clipp2p")
+
+	first, err := LoadOrCreate(dir)
+	assert.NoError(t, err)
+	assert.EqualValues(t, crypto.Ed25519, first.PrivKey.Type())
+
+	second, err := LoadOrCreate(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(Path(dir))
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+}
+
+func TestLoad_Missing(t *testing.T) {
+	_, err := Load(t.TempDir())
+	assert.ErrorIs(t, err, ErrNoIdentity)
+}
+
+func TestLoad_InsecurePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not enforced on Windows")
+	}
+	dir := t.TempDir()
+	_, err := LoadOrCreate(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chmod(Path(dir), 0o644))
+
+	_, err = Load(dir)
+	assert.ErrorIs(t, err, ErrInsecurePermissions)
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	old, err := LoadOrCreate(dir)
+	assert.NoError(t, err)
+
+	rotated, err := Rotate(dir)
+	assert.NoError(t, err)
+	assert.NotEqual(t, old.ID, rotated.ID)
+
+	loaded, err := Load(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, rotated.ID, loaded.ID)
+
+	// The previous key is kept as a backup
+	data, err := os.ReadFile(Path(dir) + ".old")
+	assert.NoError(t, err)
+	backup, err := decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, old.ID, backup.ID)
+}
+
+func TestExportImport(t *testing.T) {
+	src := t.TempDir()
+	original, err := LoadOrCreate(src)
+	assert.NoError(t, err)
+
+	var exported bytes.Buffer
+	assert.NoError(t, Export(src, &exported))
+
+	dst := t.TempDir()
+	_, err = LoadOrCreate(dst)
+	assert.NoError(t, err)
+
+	imported, err := Import(dst, &exported)
+	assert.NoError(t, err)
+	assert.Equal(t, original.ID, imported.ID)
+	assert.Equal(t, original.Fingerprint(), imported.Fingerprint())
+
+	loaded, err := Load(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, original.ID, loaded.ID)
+}
+
+func TestImport_Rejects(t *testing.T) {
+	_, err := Import(t.TempDir(), bytes.NewBufferString("not a key"))
+	assert.Error(t, err)
+
+	priv, _, err := crypto.GenerateSecp256k1Key(rand.Reader)
+	assert.NoError(t, err)
+	raw, err := crypto.MarshalPrivateKey(priv)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	_, err = Import(dir, bytes.NewReader(pem.EncodeToMemory(&pem.Block{Type: pemType, Bytes: raw})))
+	assert.ErrorIs(t, err, ErrNotEd25519)
+
+	// Nothing was stored
+	_, err = Load(dir)
+	assert.ErrorIs(t, err, ErrNoIdentity)
+}
+
+func TestFingerprint(t *testing.T) {
+	id, err := generate()
+	assert.NoError(t, err)
+
+	fp := id.Fingerprint()
+	assert.Regexp(t, `^[0-9A-F]{4}(:[0-9A-F]{4}){3}$`, fp)
+	assert.Equal(t, fp, id.Fingerprint())
+}
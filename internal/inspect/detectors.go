@@ -0,0 +1,178 @@
+package inspect
+
+import (
+	"math"
+	"regexp"
+)
+
+// Names of the built-in detectors
+const (
+	DetectorPrivateKey  = "private-key"
+	DetectorKnownKey    = "known-key"
+	DetectorCreditCard  = "credit-card"
+	DetectorHighEntropy = "high-entropy"
+)
+
+// DefaultDetectors returns the built-in detectors
+func DefaultDetectors() []Detector {
+	return []Detector{
+		PrivateKeyDetector{},
+		KnownKeyDetector{},
+		CreditCardDetector{},
+		HighEntropyDetector{MinLength: 24, MinEntropy: 4.0},
+	}
+}
+
+// DefaultPolicies are applied to the built-in detectors unless configured
+// otherwise. Detectors with few false positives block outright, heuristic
+// ones ask first.
+func DefaultPolicies() map[string]Policy {
+	return map[string]Policy{
+		DetectorPrivateKey:  PolicyBlock,
+		DetectorKnownKey:    PolicyBlock,
+		DetectorCreditCard:  PolicyRedact,
+		DetectorHighEntropy: PolicyAsk,
+	}
+}
+
+// regexpFindings turns regexp matches into findings
+func regexpFindings(detector string, re *regexp.Regexp, content string) []Finding {
+	var findings []Finding
+	for _, loc := range re.FindAllStringIndex(content, -1) {
+		findings = append(findings, Finding{Detector: detector, Start: loc[0], End: loc[1]})
+	}
+	return findings
+}
+
+// PrivateKeyDetector finds PEM and OpenSSH private key blocks, including
+// blocks that were only partially copied
+type PrivateKeyDetector struct{}
+
+var privateKeyPattern = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----(?s:.*?)(?:-----END [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----|\z)`)
+
+func (PrivateKeyDetector) Name() string { return DetectorPrivateKey }
+
+func (PrivateKeyDetector) Detect(content string) []Finding {
+	return regexpFindings(DetectorPrivateKey, privateKeyPattern, content)
+}
+
+// KnownKeyDetector finds credentials with well-known prefixes such as AWS
+// access keys, GitHub and Slack tokens, and JWTs
+type KnownKeyDetector struct{}
+
+var knownKeyPattern = regexp.MustCompile(`\b(?:` +
+	`(?:AKIA|ASIA)[0-9A-Z]{16}` + // AWS access key ID
+	`|gh[pousr]_[A-Za-z0-9]{36,}` + // GitHub token
+	`|github_pat_[A-Za-z0-9_]{22,}` + // GitHub fine-grained token
+	`|xox[abprs]-[A-Za-z0-9-]{10,}` + // Slack token
+	`|[rs]k_(?:live|test)_[A-Za-z0-9]{16,}` + // Stripe key
+	`|AIza[0-9A-Za-z_-]{35}` + // Google API key
+	`|sk-[A-Za-z0-9_-]{20,}` + // OpenAI style secret key
+	`|eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+` + // JWT
+	`)`)
+
+func (KnownKeyDetector) Name() string { return DetectorKnownKey }
+
+func (KnownKeyDetector) Detect(content string) []Finding {
+	return regexpFindings(DetectorKnownKey, knownKeyPattern, content)
+}
+
+// CreditCardDetector finds card numbers that pass the Luhn checksum
+type CreditCardDetector struct{}
+
+var cardPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+
+func (CreditCardDetector) Name() string { return DetectorCreditCard }
+
+func (CreditCardDetector) Detect(content string) []Finding {
+	var findings []Finding
+	for _, loc := range cardPattern.FindAllStringIndex(content, -1) {
+		if luhnValid(content[loc[0]:loc[1]]) {
+			findings = append(findings, Finding{Detector: DetectorCreditCard, Start: loc[0], End: loc[1]})
+		}
+	}
+	return findings
+}
+
+// luhnValid checks the Luhn checksum of the digits in s
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+// HighEntropyDetector finds long random-looking tokens such as generated
+// passwords and API secrets without a recognizable prefix
+type HighEntropyDetector struct {
+	// MinLength is the shortest token considered
+	MinLength int
+	// MinEntropy is the Shannon entropy in bits per character above
+	// which a token counts as random
+	MinEntropy float64
+}
+
+var tokenPattern = regexp.MustCompile(`[A-Za-z0-9+/=_\-]+`)
+
+func (HighEntropyDetector) Name() string { return DetectorHighEntropy }
+
+func (d HighEntropyDetector) Detect(content string) []Finding {
+	var findings []Finding
+	for _, loc := range tokenPattern.FindAllStringIndex(content, -1) {
+		token := content[loc[0]:loc[1]]
+		if len(token) < d.MinLength || !mixedClasses(token) {
+			continue
+		}
+		if shannonEntropy(token) >= d.MinEntropy {
+			findings = append(findings, Finding{Detector: DetectorHighEntropy, Start: loc[0], End: loc[1]})
+		}
+	}
+	return findings
+}
+
+// mixedClasses requires letters and digits, which rules out long words
+// and identifiers that are merely unusual
+func mixedClasses(s string) bool {
+	var letter, digit bool
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			digit = true
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+			letter = true
+		}
+	}
+	return letter && digit
+}
+
+// shannonEntropy returns the entropy of s in bits per byte
+func shannonEntropy(s string) float64 {
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+
+	entropy := 0.0
+	n := float64(len(s))
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
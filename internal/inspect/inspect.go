@@ -0,0 +1,176 @@
+package inspect
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Policy decides what happens to a clip containing a detected secret.
+// Policies are ordered from least to most restrictive.
+type Policy int
+
+const (
+	// PolicyAllow syncs the clip unchanged
+	PolicyAllow Policy = iota
+	// PolicyRedact syncs the clip with the secret masked out
+	PolicyRedact
+	// PolicyAsk holds the clip until the user confirms it
+	PolicyAsk
+	// PolicyBlock never syncs the clip
+	PolicyBlock
+)
+
+func (p Policy) String() string {
+	switch p {
+	case PolicyAllow:
+		return "allow"
+	case PolicyRedact:
+		return "redact"
+	case PolicyAsk:
+		return "ask"
+	case PolicyBlock:
+		return "block"
+	default:
+		return "unknown"
+	}
+}
+
+// ParsePolicy parses a policy name as returned by Policy.String
+func ParsePolicy(s string) (Policy, error) {
+	for _, p := range []Policy{PolicyAllow, PolicyRedact, PolicyAsk, PolicyBlock} {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return PolicyAllow, fmt.Errorf("unknown secret policy %q (want allow, redact, ask or block)", s)
+}
+
+// Finding is a secret located in clipboard text
+type Finding struct {
+	Detector string
+	// Start and End are byte offsets into the inspected text
+	Start int
+	End   int
+}
+
+// Detector finds one kind of secret in clipboard text
+type Detector interface {
+	Name() string
+	Detect(content string) []Finding
+}
+
+// Verdict is the outcome of inspecting a clip
+type Verdict struct {
+	// Action is the most restrictive policy among the findings
+	Action   Policy
+	Findings []Finding
+	// Content is what may be synced: the input with findings under
+	// PolicyRedact masked out
+	Content string
+	// Masked has every finding masked out, safe for display
+	Masked string
+}
+
+// Detectors returns the distinct names of the detectors that matched
+func (v Verdict) Detectors() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, f := range v.Findings {
+		if !seen[f.Detector] {
+			seen[f.Detector] = true
+			names = append(names, f.Detector)
+		}
+	}
+	return names
+}
+
+// Inspector runs detectors over clips and applies per-detector policies
+type Inspector struct {
+	detectors []Detector
+	policies  map[string]Policy
+}
+
+// NewInspector creates an inspector. Detectors without an entry in
+// policies are blocked.
+func NewInspector(detectors []Detector, policies map[string]Policy) *Inspector {
+	return &Inspector{
+		detectors: detectors,
+		policies:  policies,
+	}
+}
+
+// Policy returns the policy applied to a detector's findings
+func (i *Inspector) Policy(detector string) Policy {
+	if p, ok := i.policies[detector]; ok {
+		return p
+	}
+	return PolicyBlock
+}
+
+// Inspect runs every detector over content
+func (i *Inspector) Inspect(content string) Verdict {
+	verdict := Verdict{
+		Action:  PolicyAllow,
+		Content: content,
+		Masked:  content,
+	}
+
+	var redacted []Finding
+	for _, d := range i.detectors {
+		policy := i.Policy(d.Name())
+		if policy == PolicyAllow {
+			continue
+		}
+
+		findings := d.Detect(content)
+		if len(findings) == 0 {
+			continue
+		}
+
+		verdict.Findings = append(verdict.Findings, findings...)
+		if policy == PolicyRedact {
+			redacted = append(redacted, findings...)
+		}
+		if policy > verdict.Action {
+			verdict.Action = policy
+		}
+	}
+
+	verdict.Content = mask(content, redacted)
+	verdict.Masked = mask(content, verdict.Findings)
+	return verdict
+}
+
+// mask replaces each finding with a placeholder naming its detector,
+// merging findings that overlap
+func mask(content string, findings []Finding) string {
+	if len(findings) == 0 {
+		return content
+	}
+
+	sorted := append([]Finding(nil), findings...)
+	sort.Slice(sorted, func(a, b int) bool {
+		return sorted[a].Start < sorted[b].Start
+	})
+
+	var b strings.Builder
+	pos := 0
+	for i := 0; i < len(sorted); {
+		f := sorted[i]
+		end := f.End
+		i++
+		for i < len(sorted) && sorted[i].Start < end {
+			if sorted[i].End > end {
+				end = sorted[i].End
+			}
+			i++
+		}
+
+		b.WriteString(content[pos:f.Start])
+		b.WriteString("[REDACTED:" + f.Detector + "]")
+		pos = end
+	}
+	b.WriteString(content[pos:])
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package inspect
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// wordDetector flags every occurrence of a fixed word
+type wordDetector struct {
+	name string
+	word string
+}
+
+func (d wordDetector) Name() string { return d.name }
+
+func (d wordDetector) Detect(content string) []Finding {
+	var findings []Finding
+	for i := 0; i+len(d.word) <= len(content); i++ {
+		if content[i:i+len(d.word)] == d.word {
+			findings = append(findings, Finding{Detector: d.name, Start: i, End: i + len(d.word)})
+		}
+	}
+	return findings
+}
+
+func TestInspector_NoFindings(t *testing.T) {
+	inspector := NewInspector(DefaultDetectors(), DefaultPolicies())
+
+	verdict := inspector.Inspect("just some notes about lunch")
+	assert.Equal(t, PolicyAllow, verdict.Action)
+	assert.Empty(t, verdict.Findings)
+	assert.Equal(t, "just some notes about lunch", verdict.Content)
+}
+
+func TestInspector_StrictestPolicyWins(t *testing.T) {
+	inspector := NewInspector(
+		[]Detector{wordDetector{"soft", "hunter2"}, wordDetector{"hard", "swordfish"}},
+		map[string]Policy{"soft": PolicyRedact, "hard": PolicyBlock},
+	)
+
+	verdict := inspector.Inspect("hunter2 and swordfish")
+	assert.Equal(t, PolicyBlock, verdict.Action)
+	assert.Equal(t, []string{"soft", "hard"}, verdict.Detectors())
+}
+
+func TestInspector_RedactOnlyMasksRedactFindings(t *testing.T) {
+	inspector := NewInspector(
+		[]Detector{wordDetector{"soft", "hunter2"}, wordDetector{"asked", "swordfish"}},
+		map[string]Policy{"soft": PolicyRedact, "asked": PolicyAsk},
+	)
+
+	verdict := inspector.Inspect("hunter2 and swordfish")
+	assert.Equal(t, PolicyAsk, verdict.Action)
+	assert.Equal(t, "[REDACTED:soft] and swordfish", verdict.Content)
+	assert.Equal(t, "[REDACTED:soft] and [REDACTED:asked]", verdict.Masked)
+}
+
+func TestInspector_AllowSkipsDetector(t *testing.T) {
+	inspector := NewInspector(
+		[]Detector{wordDetector{"soft", "hunter2"}},
+		map[string]Policy{"soft": PolicyAllow},
+	)
+
+	verdict := inspector.Inspect("hunter2")
+	assert.Equal(t, PolicyAllow, verdict.Action)
+	assert.Empty(t, verdict.Findings)
+}
+
+func TestInspector_UnconfiguredDetectorBlocks(t *testing.T) {
+	inspector := NewInspector([]Detector{wordDetector{"custom", "hunter2"}}, nil)
+
+	verdict := inspector.Inspect("hunter2")
+	assert.Equal(t, PolicyBlock, verdict.Action)
+}
+
+func TestMask_MergesOverlaps(t *testing.T) {
+	masked := mask("abcdefgh", []Finding{
+		{Detector: "x", Start: 4, End: 7},
+		{Detector: "y", Start: 1, End: 5},
+	})
+	assert.Equal(t, "a[REDACTED:y]h", masked)
+}
+
+func TestParsePolicy(t *testing.T) {
+	p, err := ParsePolicy("redact")
+	assert.NoError(t, err)
+	assert.Equal(t, PolicyRedact, p)
+
+	_, err = ParsePolicy("shred")
+	assert.Error(t, err)
+}
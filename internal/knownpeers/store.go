@@ -0,0 +1,134 @@
+// Package knownpeers remembers the peers this device synced with, so they
+// can be redialed on startup instead of waiting for mDNS.
+package knownpeers
+
+import (
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+
+	"github.com/owenHochwald/clipp2p/internal/datadir"
+)
+
+// PeersFile is the name of the known peers file in the data directory
+const PeersFile = "known_peers.json"
+
+// Peer is a peer seen before
+type Peer struct {
+	ID       peer.ID   `json:"id"`
+	Name     string    `json:"name,omitempty"`
+	Addrs    []string  `json:"addrs,omitempty"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// AddrInfo returns where the peer was last reachable, skipping addresses
+// that no longer parse
+func (p Peer) AddrInfo() peer.AddrInfo {
+	info := peer.AddrInfo{ID: p.ID}
+	for _, s := range p.Addrs {
+		if addr, err := multiaddr.NewMultiaddr(s); err == nil {
+			info.Addrs = append(info.Addrs, addr)
+		}
+	}
+	return info
+}
+
+// Store is the persisted list of known peers
+type Store struct {
+	path  string
+	mu    sync.RWMutex
+	peers map[peer.ID]Peer
+}
+
+// Load reads the known peers kept in dir. A missing file is an empty list.
+func Load(dir string) (*Store, error) {
+	s := &Store{
+		path:  filepath.Join(dir, PeersFile),
+		peers: make(map[peer.ID]Peer),
+	}
+
+	var peers []Peer
+	if _, err := datadir.ReadJSON(s.path, &peers); err != nil {
+		return nil, err
+	}
+	for _, p := range peers {
+		s.peers[p.ID] = p
+	}
+	return s, nil
+}
+
+// Seen records that a peer was connected at a time. An empty name or
+// address list keeps what was known.
+func (s *Store) Seen(id peer.ID, name string, addrs []multiaddr.Multiaddr, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.peers[id]
+	p.ID = id
+	p.LastSeen = at
+	if name != "" {
+		p.Name = name
+	}
+	if len(addrs) > 0 {
+		// A new slice, callers of Get and List may still hold the old one
+		p.Addrs = make([]string, 0, len(addrs))
+		for _, addr := range addrs {
+			p.Addrs = append(p.Addrs, addr.String())
+		}
+	}
+	s.peers[id] = p
+	return s.saveLocked()
+}
+
+// Get returns a known peer
+func (s *Store) Get(id peer.ID) (Peer, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.peers[id]
+	return p, ok
+}
+
+// List returns the known peers, most recently seen first
+func (s *Store) List() []Peer {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	peers := make([]Peer, 0, len(s.peers))
+	for _, p := range s.peers {
+		peers = append(peers, p)
+	}
+	slices.SortFunc(peers, func(a, b Peer) int {
+		return b.LastSeen.Compare(a.LastSeen)
+	})
+	return peers
+}
+
+// Forget drops peers not seen since before now minus maxAge and returns
+// how many were dropped
+func (s *Store) Forget(maxAge time.Duration, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	forgotten := 0
+	for id, p := range s.peers {
+		if now.Sub(p.LastSeen) > maxAge {
+			delete(s.peers, id)
+			forgotten++
+		}
+	}
+	if forgotten == 0 {
+		return 0, nil
+	}
+	return forgotten, s.saveLocked()
+}
+
+func (s *Store) saveLocked() error {
+	peers := make([]Peer, 0, len(s.peers))
+	for _, p := range s.peers {
+		peers = append(peers, p)
+	}
+	return datadir.WriteJSON(s.path, peers)
+}
@@ -0,0 +1,97 @@
+package knownpeers
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/owenHochwald/clipp2p/internal/peertest"
+)
+
+func TestStore_Persists(t *testing.T) {
+	dir := t.TempDir()
+	alice := peertest.NewID(t)
+	bob := peertest.NewID(t)
+	addr := multiaddr.StringCast("/ip4/192.168.1.20/tcp/4001")
+
+	store, err := Load(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, store.List())
+
+	now := time.Now().Round(0)
+	assert.NoError(t, store.Seen(alice, "Alice", []multiaddr.Multiaddr{addr}, now.Add(-time.Hour)))
+	assert.NoError(t, store.Seen(bob, "Bob", nil, now))
+
+	reloaded, err := Load(dir)
+	assert.NoError(t, err)
+	peers := reloaded.List()
+	assert.Len(t, peers, 2)
+	assert.Equal(t, bob, peers[0].ID, "most recently seen first")
+	assert.Equal(t, "Alice", peers[1].Name)
+
+	info := peers[1].AddrInfo()
+	assert.Equal(t, alice, info.ID)
+	assert.Equal(t, []multiaddr.Multiaddr{addr}, info.Addrs)
+
+	peertest.AssertPrivate(t, filepath.Join(dir, PeersFile))
+}
+
+func TestStore_SeenKeepsKnownDetails(t *testing.T) {
+	store, err := Load(t.TempDir())
+	assert.NoError(t, err)
+	alice := peertest.NewID(t)
+	addr := multiaddr.StringCast("/ip4/10.0.0.2/tcp/4001")
+
+	assert.NoError(t, store.Seen(alice, "Alice", []multiaddr.Multiaddr{addr}, time.Now()))
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, store.Seen(alice, "", nil, later))
+
+	p, ok := store.Get(alice)
+	assert.True(t, ok)
+	assert.Equal(t, "Alice", p.Name)
+	assert.Equal(t, []string{addr.String()}, p.Addrs)
+	assert.Equal(t, later, p.LastSeen)
+}
+
+// A peer returned earlier keeps its addresses when new ones are seen
+func TestStore_SeenDoesNotRewriteReturnedPeers(t *testing.T) {
+	store, err := Load(t.TempDir())
+	assert.NoError(t, err)
+	alice := peertest.NewID(t)
+	before := multiaddr.StringCast("/ip4/10.0.0.2/tcp/4001")
+	after := multiaddr.StringCast("/ip4/10.0.0.3/tcp/4001")
+
+	assert.NoError(t, store.Seen(alice, "Alice", []multiaddr.Multiaddr{before}, time.Now()))
+	p, _ := store.Get(alice)
+	assert.NoError(t, store.Seen(alice, "", []multiaddr.Multiaddr{after}, time.Now()))
+
+	assert.Equal(t, []string{before.String()}, p.Addrs)
+	p, _ = store.Get(alice)
+	assert.Equal(t, []string{after.String()}, p.Addrs)
+}
+
+func TestStore_Forget(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Load(dir)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	fresh := peertest.NewID(t)
+	stale := peertest.NewID(t)
+	assert.NoError(t, store.Seen(fresh, "", nil, now.Add(-time.Hour)))
+	assert.NoError(t, store.Seen(stale, "", nil, now.Add(-48*time.Hour)))
+
+	n, err := store.Forget(24*time.Hour, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	reloaded, err := Load(dir)
+	assert.NoError(t, err)
+	_, ok := reloaded.Get(stale)
+	assert.False(t, ok)
+	_, ok = reloaded.Get(fresh)
+	assert.True(t, ok)
+}
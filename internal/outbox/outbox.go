@@ -0,0 +1,282 @@
+// Package outbox holds the clips a known peer missed while it was offline,
+// so they are delivered when it reconnects.
+package outbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/owenHochwald/clipp2p/internal/datadir"
+	"github.com/owenHochwald/clipp2p/internal/p2p"
+)
+
+const (
+	// OutboxFile is the name of the outbox index in the data directory
+	OutboxFile = "outbox.json"
+	// ClipsDir holds the queued clips, one file each and shared by the
+	// peers they are queued for, so saving the index never rewrites them
+	ClipsDir = "outbox"
+)
+
+// ErrInvalidID is returned for clips whose ID can't name a file
+var ErrInvalidID = errors.New("invalid clip ID")
+
+// entry is a queued clip in the index
+type entry struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// queue is how a peer's clips are kept in the index
+type queue struct {
+	Peer  peer.ID `json:"peer"`
+	Clips []entry `json:"clips"`
+}
+
+// Store is the persisted outbox of every peer, each keeping at most the
+// latest size clips, oldest first by the time they were copied
+type Store struct {
+	path  string
+	dir   string
+	size  int
+	mu    sync.Mutex
+	clips map[peer.ID][]entry
+	// delivered is when the newest clip each peer got live was copied,
+	// older clips are no use to it anymore
+	delivered map[peer.ID]time.Time
+}
+
+// Load reads the outbox kept in dir. A missing file is an empty outbox.
+// Queues longer than size, e.g. after it was lowered, lose their oldest
+// clips.
+func Load(dir string, size int) (*Store, error) {
+	s := &Store{
+		path:      filepath.Join(dir, OutboxFile),
+		dir:       filepath.Join(dir, ClipsDir),
+		size:      size,
+		clips:     make(map[peer.ID][]entry),
+		delivered: make(map[peer.ID]time.Time),
+	}
+
+	var queues []queue
+	found, err := datadir.ReadJSON(s.path, &queues)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return s, nil
+	}
+	for _, q := range queues {
+		// Clips whose file is gone can't be sent
+		clips := slices.DeleteFunc(q.Clips, func(e entry) bool {
+			_, err := os.Stat(s.clipPath(e.ID))
+			return !validID(e.ID) || err != nil
+		})
+		slices.SortStableFunc(clips, compareEntries)
+		if clips = s.latest(clips); len(clips) > 0 {
+			s.clips[q.Peer] = clips
+		}
+	}
+	s.pruneLocked()
+	return s, nil
+}
+
+// Push queues a clip for a peer, in the order clips were copied, dropping
+// the oldest beyond the size. A clip queued again replaces its earlier copy.
+// Clips older than one the peer already got are not queued.
+func (s *Store) Push(id peer.ID, clip p2p.ClipMessage) error {
+	if !validID(clip.ID) {
+		return fmt.Errorf("%w: %q", ErrInvalidID, clip.ID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.supersededLocked(id, clip.Timestamp) {
+		return nil
+	}
+	if err := s.writeClip(clip); err != nil {
+		return err
+	}
+
+	clips := slices.DeleteFunc(s.clips[id], func(e entry) bool {
+		return e.ID == clip.ID
+	})
+	e := entry{ID: clip.ID, Timestamp: clip.Timestamp}
+	i, _ := slices.BinarySearchFunc(clips, e, func(x, y entry) int {
+		// Equal times keep the order they were queued in
+		if c := compareEntries(x, y); c != 0 {
+			return c
+		}
+		return -1
+	})
+	s.clips[id] = s.latest(slices.Insert(clips, i, e))
+	return s.saveLocked()
+}
+
+// MarkDelivered records that a peer got a clip copied at ts live, dropping
+// the older clips queued for it. Clips older than that aren't queued for
+// it afterwards, e.g. by a slower send of an earlier clip.
+func (s *Store) MarkDelivered(id peer.ID, ts time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.supersededLocked(id, ts) {
+		return nil
+	}
+	s.delivered[id] = ts
+
+	clips, ok := s.clips[id]
+	if !ok {
+		return nil
+	}
+	kept := slices.DeleteFunc(slices.Clone(clips), func(e entry) bool {
+		return !e.Timestamp.After(ts)
+	})
+	if len(kept) == len(clips) {
+		return nil
+	}
+	s.setLocked(id, kept)
+	return s.saveLocked()
+}
+
+// Superseded reports whether a peer already got a clip copied at ts or
+// later live, so a queued clip copied at ts is no use to it anymore
+func (s *Store) Superseded(id peer.ID, ts time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.supersededLocked(id, ts)
+}
+
+func (s *Store) supersededLocked(id peer.ID, ts time.Time) bool {
+	mark, ok := s.delivered[id]
+	return ok && !ts.After(mark)
+}
+
+// Pending returns the clips queued for a peer, oldest first
+func (s *Store) Pending(id peer.ID) ([]p2p.ClipMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clips := make([]p2p.ClipMessage, 0, len(s.clips[id]))
+	for _, e := range s.clips[id] {
+		var clip p2p.ClipMessage
+		found, err := datadir.ReadJSON(s.clipPath(e.ID), &clip)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("queued clip %s is missing", e.ID)
+		}
+		clips = append(clips, clip)
+	}
+	return clips, nil
+}
+
+// Remove drops a clip from a peer's queue once it was delivered
+func (s *Store) Remove(id peer.ID, clipID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clips, ok := s.clips[id]
+	if !ok {
+		return nil
+	}
+	s.setLocked(id, slices.DeleteFunc(clips, func(e entry) bool {
+		return e.ID == clipID
+	}))
+	return s.saveLocked()
+}
+
+// setLocked replaces a peer's queue, forgetting the peer once it is empty
+func (s *Store) setLocked(id peer.ID, clips []entry) {
+	if len(clips) == 0 {
+		delete(s.clips, id)
+	} else {
+		s.clips[id] = clips
+	}
+}
+
+// latest keeps the last size clips
+func (s *Store) latest(clips []entry) []entry {
+	if len(clips) > s.size {
+		clips = clips[len(clips)-s.size:]
+	}
+	return clips
+}
+
+func (s *Store) clipPath(clipID string) string {
+	return filepath.Join(s.dir, clipID+".json")
+}
+
+// writeClip saves a clip's file unless an earlier push already did
+func (s *Store) writeClip(clip p2p.ClipMessage) error {
+	path := s.clipPath(clip.ID)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	return datadir.WriteJSON(path, clip)
+}
+
+// saveLocked writes the index and deletes the clips no queue holds anymore
+func (s *Store) saveLocked() error {
+	queues := make([]queue, 0, len(s.clips))
+	for id, clips := range s.clips {
+		queues = append(queues, queue{Peer: id, Clips: clips})
+	}
+	if err := datadir.WriteJSON(s.path, queues); err != nil {
+		return err
+	}
+	s.pruneLocked()
+	return nil
+}
+
+// pruneLocked deletes clip files that aren't queued for any peer. Errors
+// are ignored, the files are pruned again on the next save.
+func (s *Store) pruneLocked() {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+
+	queued := make(map[string]bool)
+	for _, clips := range s.clips {
+		for _, e := range clips {
+			queued[e.ID] = true
+		}
+	}
+	for _, f := range files {
+		clipID, ok := strings.CutSuffix(f.Name(), ".json")
+		if ok && !queued[clipID] {
+			os.Remove(filepath.Join(s.dir, f.Name()))
+		}
+	}
+}
+
+// compareEntries orders clips by the time they were copied
+func compareEntries(x, y entry) int {
+	return x.Timestamp.Compare(y.Timestamp)
+}
+
+// validID reports whether a clip ID can safely name a file. IDs come from
+// p2p.NewMessageID.
+func validID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,195 @@
+package outbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/owenHochwald/clipp2p/internal/peertest"
+
+	"github.com/owenHochwald/clipp2p/internal/p2p"
+)
+
+func clip(id, content string) p2p.ClipMessage {
+	return p2p.ClipMessage{ID: id, Content: content, Timestamp: time.Now().Round(0)}
+}
+
+// pending returns the contents of the clips queued for a peer
+func pending(t *testing.T, store *Store, id peer.ID) []string {
+	t.Helper()
+	clips, err := store.Pending(id)
+	assert.NoError(t, err)
+	var s []string
+	for _, c := range clips {
+		s = append(s, c.Content)
+	}
+	return s
+}
+
+func TestStore_KeepsLatest(t *testing.T) {
+	store, err := Load(t.TempDir(), 2)
+	assert.NoError(t, err)
+	alice := peertest.NewID(t)
+
+	assert.NoError(t, store.Push(alice, clip("1", "one")))
+	assert.NoError(t, store.Push(alice, clip("2", "two")))
+	assert.NoError(t, store.Push(alice, clip("3", "three")))
+
+	assert.Equal(t, []string{"two", "three"}, pending(t, store, alice))
+}
+
+func TestStore_LatestOnly(t *testing.T) {
+	store, err := Load(t.TempDir(), 1)
+	assert.NoError(t, err)
+	alice := peertest.NewID(t)
+	bob := peertest.NewID(t)
+
+	assert.NoError(t, store.Push(alice, clip("1", "one")))
+	assert.NoError(t, store.Push(alice, clip("2", "two")))
+	assert.NoError(t, store.Push(bob, clip("2", "two")))
+
+	assert.Equal(t, []string{"two"}, pending(t, store, alice))
+	assert.Equal(t, []string{"two"}, pending(t, store, bob))
+}
+
+func TestStore_PushSameClipOnce(t *testing.T) {
+	store, err := Load(t.TempDir(), 3)
+	assert.NoError(t, err)
+	alice := peertest.NewID(t)
+
+	assert.NoError(t, store.Push(alice, clip("1", "one")))
+	assert.NoError(t, store.Push(alice, clip("2", "two")))
+	assert.NoError(t, store.Push(alice, clip("1", "one")))
+
+	assert.Equal(t, []string{"two", "one"}, pending(t, store, alice))
+}
+
+func TestStore_Persists(t *testing.T) {
+	dir := t.TempDir()
+	alice := peertest.NewID(t)
+
+	store, err := Load(dir, 3)
+	assert.NoError(t, err)
+	assert.NoError(t, store.Push(alice, clip("1", "one")))
+	img := p2p.ClipMessage{ID: "2", Format: p2p.FormatImage, Data: []byte{0x89, 'P', 'N', 'G'}, Timestamp: time.Now().Round(0)}
+	assert.NoError(t, store.Push(alice, img))
+
+	reloaded, err := Load(dir, 3)
+	assert.NoError(t, err)
+	clips, err := reloaded.Pending(alice)
+	assert.NoError(t, err)
+	if assert.Len(t, clips, 2) {
+		assert.Equal(t, "one", clips[0].Content)
+		assert.Equal(t, img.Data, clips[1].Data)
+	}
+	peertest.AssertPrivate(t, filepath.Join(dir, OutboxFile))
+	peertest.AssertPrivate(t, filepath.Join(dir, ClipsDir, "2.json"))
+
+	// A smaller size applies to what was kept, and the dropped clip's
+	// file goes with it
+	smaller, err := Load(dir, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{""}, pending(t, smaller, alice))
+	files, err := os.ReadDir(filepath.Join(dir, ClipsDir))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestStore_Remove(t *testing.T) {
+	dir := t.TempDir()
+	alice := peertest.NewID(t)
+
+	store, err := Load(dir, 3)
+	assert.NoError(t, err)
+	assert.NoError(t, store.Push(alice, clip("1", "one")))
+	assert.NoError(t, store.Push(alice, clip("2", "two")))
+
+	assert.NoError(t, store.Remove(alice, "1"))
+	assert.Equal(t, []string{"two"}, pending(t, store, alice))
+
+	assert.NoError(t, store.Remove(alice, "2"))
+	assert.Empty(t, pending(t, store, alice))
+	assert.NoError(t, store.Remove(peertest.NewID(t), "1"))
+
+	reloaded, err := Load(dir, 3)
+	assert.NoError(t, err)
+	assert.Empty(t, pending(t, reloaded, alice))
+
+	files, err := os.ReadDir(filepath.Join(dir, ClipsDir))
+	assert.NoError(t, err)
+	assert.Empty(t, files, "delivered clips are deleted")
+}
+
+func TestStore_OrdersByTimestamp(t *testing.T) {
+	store, err := Load(t.TempDir(), 2)
+	assert.NoError(t, err)
+	alice := peertest.NewID(t)
+
+	now := time.Now()
+	at := func(id, content string, d time.Duration) p2p.ClipMessage {
+		return p2p.ClipMessage{ID: id, Content: content, Timestamp: now.Add(d)}
+	}
+	assert.NoError(t, store.Push(alice, at("2", "two", 2*time.Second)))
+	assert.NoError(t, store.Push(alice, at("1", "one", time.Second)))
+	assert.Equal(t, []string{"one", "two"}, pending(t, store, alice))
+
+	// A late push of an older clip doesn't push out newer ones
+	assert.NoError(t, store.Push(alice, at("0", "zero", 0)))
+	assert.Equal(t, []string{"one", "two"}, pending(t, store, alice))
+
+	assert.NoError(t, store.Push(alice, at("3", "three", 3*time.Second)))
+	assert.Equal(t, []string{"two", "three"}, pending(t, store, alice))
+}
+
+func TestStore_MarkDelivered(t *testing.T) {
+	store, err := Load(t.TempDir(), 3)
+	assert.NoError(t, err)
+	alice := peertest.NewID(t)
+	bob := peertest.NewID(t)
+
+	now := time.Now()
+	old := p2p.ClipMessage{ID: "1", Content: "old", Timestamp: now}
+	assert.NoError(t, store.Push(alice, old))
+	assert.NoError(t, store.Push(bob, old))
+
+	// alice got a newer clip live, the old one would overwrite it
+	assert.NoError(t, store.MarkDelivered(alice, now.Add(time.Second)))
+	assert.Empty(t, pending(t, store, alice))
+	assert.Equal(t, []string{"old"}, pending(t, store, bob))
+	assert.True(t, store.Superseded(alice, now))
+	assert.True(t, store.Superseded(alice, now.Add(time.Second)))
+	assert.False(t, store.Superseded(alice, now.Add(2*time.Second)))
+	assert.False(t, store.Superseded(bob, now))
+
+	// Nor is a clip older than that queued later
+	assert.NoError(t, store.Push(alice, p2p.ClipMessage{ID: "2", Content: "late", Timestamp: now.Add(time.Millisecond)}))
+	assert.Empty(t, pending(t, store, alice))
+
+	assert.NoError(t, store.Push(alice, p2p.ClipMessage{ID: "3", Content: "newer", Timestamp: now.Add(2 * time.Second)}))
+	assert.Equal(t, []string{"newer"}, pending(t, store, alice))
+}
+
+func TestStore_InvalidID(t *testing.T) {
+	store, err := Load(t.TempDir(), 1)
+	assert.NoError(t, err)
+
+	err = store.Push(peertest.NewID(t), clip("../escape", "nope"))
+	assert.ErrorIs(t, err, ErrInvalidID)
+}
+
+func TestStore_SlowerBroadcastOfOlderClip(t *testing.T) {
+	store, err := Load(t.TempDir(), 1)
+	assert.NoError(t, err)
+	alice := peertest.NewID(t)
+
+	// Broadcasts run concurrently, the earlier clip may time out last
+	now := time.Now()
+	assert.NoError(t, store.Push(alice, p2p.ClipMessage{ID: "2", Content: "newer", Timestamp: now.Add(time.Second)}))
+	assert.NoError(t, store.Push(alice, p2p.ClipMessage{ID: "1", Content: "older", Timestamp: now}))
+
+	assert.Equal(t, []string{"newer"}, pending(t, store, alice))
+}
@@ -0,0 +1,42 @@
+package p2p
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	// minRedialDelay is the wait after the first failed dial
+	minRedialDelay = time.Second
+	// maxRedialDelay caps the wait between dials of an unreachable peer
+	maxRedialDelay = 5 * time.Minute
+)
+
+// backoff spaces out retries exponentially, with jitter so peers that
+// dropped together don't redial in lockstep
+type backoff struct {
+	min, max time.Duration
+	attempt  int
+}
+
+func newBackoff() *backoff {
+	return &backoff{min: minRedialDelay, max: maxRedialDelay}
+}
+
+// next returns how long to wait before the next attempt
+func (b *backoff) next() time.Duration {
+	d := b.max
+	if b.attempt < 32 {
+		d = min(b.min<<b.attempt, b.max)
+	}
+	b.attempt++
+
+	// ±20%
+	jitter := time.Duration(rand.Int64N(int64(d)/5*2+1)) - d/5
+	return d + jitter
+}
+
+// reset starts over after a success
+func (b *backoff) reset() {
+	b.attempt = 0
+}
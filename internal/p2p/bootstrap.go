@@ -0,0 +1,188 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// bootstrapDialTimeout bounds a single dial of a bootstrap peer
+const bootstrapDialTimeout = 15 * time.Second
+
+// ErrSelfDial is returned when a bootstrap address points at this node
+var ErrSelfDial = errors.New("address is this node")
+
+// Bootstrapper keeps peers at known addresses connected, for networks
+// mDNS doesn't reach. Peers that drop are redialed with backoff.
+type Bootstrapper struct {
+	node   *Node
+	ctx    context.Context
+	cancel context.CancelFunc
+	sub    event.Subscription
+
+	mu    sync.Mutex
+	peers map[peer.ID]*bootstrapPeer
+}
+
+type bootstrapPeer struct {
+	info peer.AddrInfo
+	// wake interrupts the wait before a redial, when the peer drops
+	wake chan struct{}
+}
+
+// SetupBootstrap dials addrs, multiaddrs ending in /p2p/<peer-id>, and
+// keeps them connected until the node or the bootstrapper closes
+func (n *Node) SetupBootstrap(addrs []string) (*Bootstrapper, error) {
+	infos := make([]peer.AddrInfo, 0, len(addrs))
+	for _, addr := range addrs {
+		info, err := peer.AddrInfoFromString(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bootstrap address %q: %w", addr, err)
+		}
+		infos = append(infos, *info)
+	}
+
+	sub, err := n.host.EventBus().Subscribe(new(event.EvtPeerConnectednessChanged))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(n.ctx)
+	b := &Bootstrapper{
+		node:   n,
+		ctx:    ctx,
+		cancel: cancel,
+		sub:    sub,
+		peers:  make(map[peer.ID]*bootstrapPeer),
+	}
+	go b.watch()
+
+	for _, info := range infos {
+		b.add(info)
+	}
+	return b, nil
+}
+
+// Add keeps the peer at addr connected, like the addresses the
+// bootstrapper was set up with
+func (b *Bootstrapper) Add(addr string) (peer.AddrInfo, error) {
+	info, err := peer.AddrInfoFromString(addr)
+	if err != nil {
+		return peer.AddrInfo{}, err
+	}
+	if info.ID == b.node.ID() {
+		return peer.AddrInfo{}, ErrSelfDial
+	}
+	b.add(*info)
+	return *info, nil
+}
+
+// add starts keeping a peer connected, or adds addresses to a known one
+func (b *Bootstrapper) add(info peer.AddrInfo) {
+	if info.ID == b.node.ID() {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if p, ok := b.peers[info.ID]; ok {
+		p.info.Addrs = append(p.info.Addrs, info.Addrs...)
+		b.node.host.Peerstore().AddAddrs(info.ID, info.Addrs, time.Hour)
+		p.signal()
+		return
+	}
+
+	p := &bootstrapPeer{info: info, wake: make(chan struct{}, 1)}
+	b.peers[info.ID] = p
+	go b.keepConnected(p)
+}
+
+// IsBootstrap reports whether a peer is kept connected by the bootstrapper
+func (b *Bootstrapper) IsBootstrap(id peer.ID) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.peers[id]
+	return ok
+}
+
+// Peers returns the peers kept connected
+func (b *Bootstrapper) Peers() []peer.AddrInfo {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	infos := make([]peer.AddrInfo, 0, len(b.peers))
+	for _, p := range b.peers {
+		infos = append(infos, p.info)
+	}
+	return infos
+}
+
+// Close stops redialing, connections stay open
+func (b *Bootstrapper) Close() error {
+	b.cancel()
+	return b.sub.Close()
+}
+
+// keepConnected dials a peer whenever it isn't connected
+func (b *Bootstrapper) keepConnected(p *bootstrapPeer) {
+	bo := newBackoff()
+	for {
+		b.mu.Lock()
+		info := peer.AddrInfo{ID: p.info.ID, Addrs: slices.Clone(p.info.Addrs)}
+		b.mu.Unlock()
+
+		delay := bo.next()
+		if b.node.host.Network().Connectedness(info.ID) == network.Connected {
+			// Wait for the peer to drop
+			bo.reset()
+			delay = maxRedialDelay
+		} else {
+			ctx, cancel := context.WithTimeout(b.ctx, bootstrapDialTimeout)
+			err := b.node.host.Connect(ctx, info)
+			cancel()
+			if err == nil {
+				bo.reset()
+				delay = maxRedialDelay
+			}
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-b.ctx.Done():
+			timer.Stop()
+			return
+		case <-p.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// watch wakes the redial loop of bootstrap peers that disconnect
+func (b *Bootstrapper) watch() {
+	for e := range b.sub.Out() {
+		evt := e.(event.EvtPeerConnectednessChanged)
+		if evt.Connectedness == network.Connected {
+			continue
+		}
+		b.mu.Lock()
+		if p, ok := b.peers[evt.Peer]; ok {
+			p.signal()
+		}
+		b.mu.Unlock()
+	}
+}
+
+func (p *bootstrapPeer) signal() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,90 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/stretchr/testify/assert"
+)
+
+// dialAddr returns a multiaddr of node others can bootstrap from
+func dialAddr(node *Node) string {
+	return node.DialAddrs()[0]
+}
+
+func TestBootstrap_RedialsAfterDisconnect(t *testing.T) {
+	ctx := context.Background()
+	node1, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node1.Close()
+	node2, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node2.Close()
+
+	b, err := node1.SetupBootstrap([]string{dialAddr(node2)})
+	assert.NoError(t, err)
+	defer b.Close()
+
+	connected := func() bool {
+		return node1.Host().Network().Connectedness(node2.ID()) == network.Connected
+	}
+	assert.Eventually(t, connected, 5*time.Second, 20*time.Millisecond)
+	assert.True(t, b.IsBootstrap(node2.ID()))
+
+	// The remote side drops us, we come back without waiting out a backoff
+	node2.Host().Network().ClosePeer(node1.ID())
+	assert.Eventually(t, connected, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestBootstrap_Add(t *testing.T) {
+	ctx := context.Background()
+	node1, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node1.Close()
+	node2, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node2.Close()
+
+	b, err := node1.SetupBootstrap(nil)
+	assert.NoError(t, err)
+	defer b.Close()
+
+	_, err = b.Add("/ip4/127.0.0.1/tcp/1")
+	assert.Error(t, err, "address without a peer ID")
+	_, err = b.Add(dialAddr(node1))
+	assert.ErrorIs(t, err, ErrSelfDial)
+
+	info, err := b.Add(dialAddr(node2))
+	assert.NoError(t, err)
+	assert.Equal(t, node2.ID(), info.ID)
+	assert.Eventually(t, func() bool {
+		return node1.Host().Network().Connectedness(node2.ID()) == network.Connected
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Len(t, b.Peers(), 1)
+}
+
+func TestSetupBootstrap_InvalidAddr(t *testing.T) {
+	node, err := NewNode(context.Background())
+	assert.NoError(t, err)
+	defer node.Close()
+
+	_, err = node.SetupBootstrap([]string{"not-a-multiaddr"})
+	assert.Error(t, err)
+}
+
+func TestBackoff(t *testing.T) {
+	b := &backoff{min: time.Second, max: 8 * time.Second}
+
+	within := func(d, want time.Duration) bool {
+		return d >= want-want/5 && d <= want+want/5
+	}
+	for _, want := range []time.Duration{1, 2, 4, 8, 8} {
+		d := b.next()
+		assert.True(t, within(d, want*time.Second), "got %s, want about %ds", d, want)
+	}
+
+	b.reset()
+	assert.True(t, within(b.next(), time.Second))
+}
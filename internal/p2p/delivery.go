@@ -0,0 +1,133 @@
+package p2p
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// DefaultAckTimeout is the deadline for a peer to ack a clip. Chunked
+	// transfers get longer, see sendTimeout.
+	DefaultAckTimeout = 10 * time.Second
+	// DefaultBroadcastWorkers is how many peers a clip is sent to at once
+	DefaultBroadcastWorkers = 8
+
+	// minTransferRate is the slowest chunked transfer waited for, in bytes
+	// per second
+	minTransferRate = 32 << 10
+)
+
+var (
+	// ErrRejected is returned when a peer refuses a chunked transfer
+	ErrRejected = errors.New("peer rejected clip")
+	// ErrSuperseded cancels sends of a clip once a newer one is broadcast
+	ErrSuperseded = errors.New("superseded by a newer clip")
+)
+
+// DeliveryStatus is the outcome of sending a clip to one peer
+type DeliveryStatus int
+
+const (
+	DeliveryDelivered DeliveryStatus = iota
+	// DeliveryRejected means the peer received the clip and refused it
+	DeliveryRejected
+	// DeliveryTimedOut means the peer didn't ack the clip in time
+	DeliveryTimedOut
+	// DeliveryFailed means the clip couldn't be sent, e.g. the stream broke
+	DeliveryFailed
+	// DeliverySuperseded means the send was cancelled for a newer clip
+	DeliverySuperseded
+	// DeliverySent means the clip went to a peer that doesn't ack clips,
+	// an older version, so whether it arrived is unknown
+	DeliverySent
+)
+
+func (s DeliveryStatus) String() string {
+	switch s {
+	case DeliveryDelivered:
+		return "delivered"
+	case DeliveryRejected:
+		return "rejected"
+	case DeliveryTimedOut:
+		return "timed out"
+	case DeliverySuperseded:
+		return "superseded"
+	case DeliverySent:
+		return "sent, not acknowledged"
+	default:
+		return "failed"
+	}
+}
+
+// DeliveryResult reports how a broadcast clip fared with one peer
+type DeliveryResult struct {
+	Peer   peer.ID
+	Status DeliveryStatus
+	// Reason explains why the clip wasn't delivered
+	Reason string
+	// Duration is how long the send took, until the ack when delivered
+	Duration time.Duration
+}
+
+// Delivered reports whether the peer acked the clip
+func (r DeliveryResult) Delivered() bool {
+	return r.Status == DeliveryDelivered
+}
+
+// NewMessageID returns a random clip identifier
+func NewMessageID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// deliveryResult classifies the error SendClip returned under ctx
+func deliveryResult(ctx context.Context, peerID peer.ID, err error) DeliveryResult {
+	result := DeliveryResult{Peer: peerID}
+
+	var protoErr *ProtocolError
+	switch {
+	case err == nil:
+		result.Status = DeliveryDelivered
+	case errors.As(err, &protoErr):
+		result.Status = DeliveryRejected
+		result.Reason = protoErr.Message
+	case errors.Is(err, ErrRejected):
+		result.Status = DeliveryRejected
+		result.Reason = err.Error()
+	case errors.Is(context.Cause(ctx), ErrSuperseded):
+		result.Status = DeliverySuperseded
+		result.Reason = "a newer clip was sent"
+	case errors.Is(ctx.Err(), context.DeadlineExceeded),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, os.ErrDeadlineExceeded):
+		result.Status = DeliveryTimedOut
+		result.Reason = "no ack from peer"
+	default:
+		result.Status = DeliveryFailed
+		result.Reason = err.Error()
+	}
+	return result
+}
+
+// sendTimeout is the deadline for delivering a clip to one peer. Chunked
+// transfers are given time to move the payload at minTransferRate.
+func (sh *StreamHandler) sendTimeout(msg ClipMessage) time.Duration {
+	size := len(clipPayload(msg))
+	if size <= sh.chunkSize {
+		return sh.ackTimeout
+	}
+	return sh.transferTimeout(int64(size))
+}
+
+// transferTimeout is how long a chunked transfer of size bytes may take
+// on either end
+func (sh *StreamHandler) transferTimeout(size int64) time.Duration {
+	return sh.ackTimeout + time.Duration(size)*time.Second/minTransferRate
+}
@@ -0,0 +1,217 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSilentNode creates a peer that opens a session but never acks
+func newSilentNode(t *testing.T) *Node {
+	t.Helper()
+	node, err := NewNode(context.Background())
+	assert.NoError(t, err)
+	t.Cleanup(func() { node.Close() })
+
+	handler := NewStreamHandler(node, nil)
+	node.Host().SetStreamHandler(ProtocolV2ID, func(stream network.Stream) {
+		reader := bufio.NewReader(stream)
+		readFrame(reader, maxHeaderSize)
+		hello := handler.localHello()
+		writeFrame(stream, frame{hello: &hello})
+		for {
+			if _, err := readFrame(reader, 1<<20); err != nil {
+				return
+			}
+		}
+	})
+	return node
+}
+
+func TestDeliveryResult_Classify(t *testing.T) {
+	ctx := context.Background()
+	expired, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer cancel()
+	superseded, supersede := context.WithCancelCause(ctx)
+	supersede(ErrSuperseded)
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		err    error
+		status DeliveryStatus
+		reason string
+	}{
+		{"delivered", ctx, nil, DeliveryDelivered, ""},
+		{"protocol error", ctx, &ProtocolError{Code: ErrorCodeTooLarge, Message: "too big"}, DeliveryRejected, "too big"},
+		{"transfer rejected", ctx, fmt.Errorf("%w: no room", ErrRejected), DeliveryRejected, "peer rejected clip: no room"},
+		{"deadline", expired, errors.New("stream reset"), DeliveryTimedOut, "no ack from peer"},
+		{"superseded", superseded, errors.New("stream reset"), DeliverySuperseded, "a newer clip was sent"},
+		{"broken", ctx, errors.New("stream reset"), DeliveryFailed, "stream reset"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := deliveryResult(tt.ctx, "peer", tt.err)
+			assert.Equal(t, tt.status, r.Status)
+			assert.Equal(t, tt.reason, r.Reason)
+		})
+	}
+}
+
+func TestBroadcast_Results(t *testing.T) {
+	ctx := context.Background()
+
+	sender, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer sender.Close()
+
+	accepting, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer accepting.Close()
+
+	refusing, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer refusing.Close()
+
+	silent := newSilentNode(t)
+
+	handler := NewStreamHandler(sender, nil)
+	handler.SetAckTimeout(500 * time.Millisecond)
+	NewStreamHandler(accepting, nil)
+	NewStreamHandler(refusing, nil).SetMaxClipSize(4)
+
+	for _, n := range []*Node{accepting, refusing, silent} {
+		assert.NoError(t, sender.Host().Connect(ctx, n.AddrInfo()))
+	}
+
+	results := handler.Broadcast(ctx, ClipMessage{Content: "more than four bytes", Timestamp: time.Now()})
+
+	statuses := make(map[string]DeliveryStatus)
+	for _, r := range results {
+		switch r.Peer {
+		case accepting.ID():
+			statuses["accepting"] = r.Status
+		case refusing.ID():
+			statuses["refusing"] = r.Status
+		case silent.ID():
+			statuses["silent"] = r.Status
+		}
+	}
+	assert.Equal(t, map[string]DeliveryStatus{
+		"accepting": DeliveryDelivered,
+		"refusing":  DeliveryRejected,
+		"silent":    DeliveryTimedOut,
+	}, statuses)
+}
+
+func TestBroadcast_HungPeersDontAddUp(t *testing.T) {
+	ctx := context.Background()
+
+	sender, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer sender.Close()
+	handler := NewStreamHandler(sender, nil)
+	handler.SetAckTimeout(500 * time.Millisecond)
+
+	for range 3 {
+		assert.NoError(t, sender.Host().Connect(ctx, newSilentNode(t).AddrInfo()))
+	}
+
+	start := time.Now()
+	results := handler.Broadcast(ctx, ClipMessage{Content: "hello", Timestamp: time.Now()})
+	elapsed := time.Since(start)
+
+	assert.Len(t, results, 3)
+	for _, r := range results {
+		assert.Equal(t, DeliveryTimedOut, r.Status)
+	}
+	assert.Less(t, elapsed, time.Second, "peers are waited for at once")
+
+	// One worker waits for each in turn
+	handler.SetBroadcastWorkers(1)
+	start = time.Now()
+	handler.Broadcast(ctx, ClipMessage{Content: "again", Timestamp: time.Now()})
+	assert.GreaterOrEqual(t, time.Since(start), 1500*time.Millisecond)
+}
+
+func TestBroadcast_Superseded(t *testing.T) {
+	ctx := context.Background()
+
+	sender, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer sender.Close()
+	handler := NewStreamHandler(sender, nil)
+	handler.SetAckTimeout(2 * time.Second)
+	assert.NoError(t, sender.Host().Connect(ctx, newSilentNode(t).AddrInfo()))
+
+	now := time.Now()
+	first := make(chan []DeliveryResult, 1)
+	go func() {
+		first <- handler.Broadcast(ctx, ClipMessage{Content: "first", Timestamp: now})
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	second := make(chan []DeliveryResult, 1)
+	go func() {
+		second <- handler.Broadcast(ctx, ClipMessage{Content: "second", Timestamp: now.Add(time.Second)})
+	}()
+
+	select {
+	case results := <-first:
+		if assert.Len(t, results, 1) {
+			assert.Equal(t, DeliverySuperseded, results[0].Status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("first broadcast not cancelled")
+	}
+
+	// A clip older than the one in flight doesn't cancel it
+	late := handler.Broadcast(ctx, ClipMessage{Content: "late", Timestamp: now.Add(time.Millisecond)})
+	if assert.Len(t, late, 1) {
+		assert.Equal(t, DeliverySuperseded, late[0].Status)
+	}
+
+	// Primary is a separate clip
+	primary := handler.Broadcast(ctx, ClipMessage{Selection: SelectionPrimary, Content: "primary", Timestamp: now})
+	if assert.Len(t, primary, 1) {
+		assert.Equal(t, DeliveryTimedOut, primary[0].Status)
+	}
+
+	results := <-second
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, DeliveryTimedOut, results[0].Status)
+	}
+}
+
+func TestDeliver_UnackedPeer(t *testing.T) {
+	ctx := context.Background()
+
+	sender, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer sender.Close()
+
+	// A peer that only speaks 1.0.0 never acks
+	old, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer old.Close()
+	NewStreamHandler(old, nil)
+	old.Host().RemoveStreamHandler(ProtocolV2ID)
+	old.Host().RemoveStreamHandler(TransferProtocolID)
+
+	handler := NewStreamHandler(sender, nil)
+	assert.NoError(t, sender.Host().Connect(ctx, old.AddrInfo()))
+
+	r := handler.Deliver(ctx, old.ID(), ClipMessage{Content: "hello", Timestamp: time.Now()})
+	assert.Equal(t, DeliverySent, r.Status)
+	assert.False(t, r.Delivered())
+
+	_, ok := handler.PeerStats(old.ID())
+	assert.False(t, ok, "unacked sends aren't timed")
+}
@@ -3,33 +3,50 @@ package p2p
 import (
 	"context"
 	"sync"
+	"time"
 
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
 )
 
+// discoveryServiceTag is advertised by nodes outside a private group
 const discoveryServiceTag = "clipp2p"
 
+const (
+	// discoveredPeerTTL is how long a discovered peer that isn't connected
+	// is remembered, and redialed after it drops
+	discoveredPeerTTL = 10 * time.Minute
+	// expireInterval is how often stale discovered peers are dropped
+	expireInterval = time.Minute
+)
+
 // DiscoveryNotifee handles peer discovery events
 type DiscoveryNotifee struct {
 	mu      sync.RWMutex
-	peers   map[peer.ID]peer.AddrInfo
+	peers   map[peer.ID]discoveredPeer
 	onFound func(peer.AddrInfo)
 }
 
+type discoveredPeer struct {
+	info peer.AddrInfo
+	seen time.Time
+}
+
 // NewDiscoveryNotifee creates a notifee that tracks discovered peers
 func NewDiscoveryNotifee(onFound func(peer.AddrInfo)) *DiscoveryNotifee {
 	return &DiscoveryNotifee{
-		peers:   make(map[peer.ID]peer.AddrInfo),
+		peers:   make(map[peer.ID]discoveredPeer),
 		onFound: onFound,
 	}
 }
 
-// HandlePeerFound is called when discovers a new peer
+// HandlePeerFound is called when discovers a new peer. Peers removed since
+// they were last found count as new.
 func (n *DiscoveryNotifee) HandlePeerFound(info peer.AddrInfo) {
 	n.mu.Lock()
 	_, exists := n.peers[info.ID]
-	n.peers[info.ID] = info
+	n.peers[info.ID] = discoveredPeer{info: info, seen: time.Now()}
 	n.mu.Unlock()
 
 	if !exists && n.onFound != nil {
@@ -42,25 +59,61 @@ func (n *DiscoveryNotifee) Peers() []peer.AddrInfo {
 	defer n.mu.RUnlock()
 
 	result := make([]peer.AddrInfo, 0, len(n.peers))
-	for _, info := range n.peers {
-		result = append(result, info)
+	for _, p := range n.peers {
+		result = append(result, p.info)
 	}
 	return result
 }
 
+// Peer returns a discovered peer
+func (n *DiscoveryNotifee) Peer(id peer.ID) (peer.AddrInfo, bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	p, ok := n.peers[id]
+	return p.info, ok
+}
+
 func (n *DiscoveryNotifee) RemovePeer(id peer.ID) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 	delete(n.peers, id)
 }
 
-// Discovery wraps mDNS peer discovery
+// Expire removes peers last found before cutoff, except those keep
+// reports, and returns them
+func (n *DiscoveryNotifee) Expire(cutoff time.Time, keep func(peer.ID) bool) []peer.ID {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var expired []peer.ID
+	for id, p := range n.peers {
+		if keep != nil && keep(id) {
+			// Still useful, count it as seen
+			p.seen = time.Now()
+			n.peers[id] = p
+			continue
+		}
+		if p.seen.Before(cutoff) {
+			delete(n.peers, id)
+			expired = append(expired, id)
+		}
+	}
+	return expired
+}
+
+// Discovery wraps mDNS peer discovery. Peers that drop are forgotten, so
+// mDNS finding them again reconnects them, and redialed with backoff in
+// the meantime.
 type Discovery struct {
-	service mdns.Service
-	notifee *DiscoveryNotifee
-	node    *Node
-	ctx     context.Context
-	cancel  context.CancelFunc
+	service  mdns.Service
+	notifee  *DiscoveryNotifee
+	notifier *ConnectionNotifier
+	node     *Node
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	mu           sync.Mutex
+	reconnecting map[peer.ID]bool
 }
 
 // SetupDiscovery initializes mDNS discovery for the node
@@ -81,19 +134,25 @@ func (n *Node) SetupDiscovery(onPeerFound func(peer.AddrInfo)) (*Discovery, erro
 		}
 	})
 
-	service := mdns.NewMdnsService(n.host, discoveryServiceTag, notifee)
+	service := mdns.NewMdnsService(n.host, n.serviceTag, notifee)
 	if err := service.Start(); err != nil {
 		cancel()
 		return nil, err
 	}
 
-	return &Discovery{
-		service: service,
-		notifee: notifee,
-		node:    n,
-		ctx:     ctx,
-		cancel:  cancel,
-	}, nil
+	d := &Discovery{
+		service:      service,
+		notifee:      notifee,
+		node:         n,
+		ctx:          ctx,
+		cancel:       cancel,
+		reconnecting: make(map[peer.ID]bool),
+	}
+	d.notifier = NewConnectionNotifier(nil, d.handleDisconnect)
+	n.host.Network().Notify(d.notifier)
+	go d.expireLoop()
+
+	return d, nil
 }
 
 func (d *Discovery) Peers() []peer.AddrInfo {
@@ -101,6 +160,83 @@ func (d *Discovery) Peers() []peer.AddrInfo {
 }
 
 func (d *Discovery) Close() error {
+	d.node.host.Network().StopNotify(d.notifier)
 	d.cancel()
 	return d.service.Close()
 }
+
+// handleDisconnect forgets a peer that dropped and redials it. It runs on
+// libp2p's goroutines.
+func (d *Discovery) handleDisconnect(id peer.ID) {
+	info, ok := d.notifee.Peer(id)
+	if !ok {
+		return
+	}
+	d.notifee.RemovePeer(id)
+
+	// Peers only let in to pair are not worth redialing
+	if !d.node.Authorized(id) {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.reconnecting[id] {
+		return
+	}
+	d.reconnecting[id] = true
+	go d.reconnect(info)
+}
+
+// reconnect redials a dropped peer with exponential backoff until it is
+// connected again, by us or by mDNS, or it has been gone too long
+func (d *Discovery) reconnect(info peer.AddrInfo) {
+	defer func() {
+		d.mu.Lock()
+		delete(d.reconnecting, info.ID)
+		d.mu.Unlock()
+	}()
+
+	giveUp := time.Now().Add(discoveredPeerTTL)
+	bo := newBackoff()
+	for time.Now().Before(giveUp) {
+		timer := time.NewTimer(bo.next())
+		select {
+		case <-d.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if d.node.host.Network().Connectedness(info.ID) == network.Connected {
+			return
+		}
+		ctx, cancel := context.WithTimeout(d.ctx, bootstrapDialTimeout)
+		err := d.node.host.Connect(ctx, info)
+		cancel()
+		if err == nil {
+			// Found again, a later drop starts over
+			d.notifee.HandlePeerFound(info)
+			return
+		}
+	}
+}
+
+// expireLoop drops discovered peers that are gone, so they are connected
+// to again when mDNS finds them
+func (d *Discovery) expireLoop() {
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+
+	connected := func(id peer.ID) bool {
+		return d.node.host.Network().Connectedness(id) == network.Connected
+	}
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+			d.notifee.Expire(time.Now().Add(-discoveredPeerTTL), connected)
+		}
+	}
+}
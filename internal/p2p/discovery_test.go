@@ -120,3 +120,66 @@ func TestTwoNodes_DiscoverEachOther(t *testing.T) {
 	defer mu.Unlock()
 	assert.True(t, node1Found || node2Found)
 }
+
+func TestDiscoveryNotifee_FoundAgainAfterRemove(t *testing.T) {
+	found := 0
+	notifee := NewDiscoveryNotifee(func(peer.AddrInfo) { found++ })
+
+	info := peer.AddrInfo{ID: "12D3KooWTestPeer1"}
+	notifee.HandlePeerFound(info)
+	notifee.RemovePeer(info.ID)
+	notifee.HandlePeerFound(info)
+
+	assert.Equal(t, 2, found, "a removed peer found again is new")
+}
+
+func TestDiscoveryNotifee_Expire(t *testing.T) {
+	notifee := NewDiscoveryNotifee(nil)
+	stale := peer.AddrInfo{ID: "12D3KooWTestPeer1"}
+	kept := peer.AddrInfo{ID: "12D3KooWTestPeer2"}
+	notifee.HandlePeerFound(stale)
+	notifee.HandlePeerFound(kept)
+
+	expired := notifee.Expire(time.Now().Add(time.Second), func(id peer.ID) bool {
+		return id == kept.ID
+	})
+	assert.Equal(t, []peer.ID{stale.ID}, expired)
+
+	_, ok := notifee.Peer(kept.ID)
+	assert.True(t, ok)
+	_, ok = notifee.Peer(stale.ID)
+	assert.False(t, ok)
+}
+
+func TestDiscovery_ReconnectsDroppedPeer(t *testing.T) {
+	ctx := context.Background()
+	node1, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node1.Close()
+	node2, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node2.Close()
+
+	discovery, err := node1.SetupDiscovery(nil)
+	assert.NoError(t, err)
+	defer discovery.Close()
+
+	connected := func() bool {
+		return len(node1.Host().Network().ConnsToPeer(node2.ID())) > 0
+	}
+
+	// As if mDNS found node2
+	discovery.notifee.HandlePeerFound(node2.AddrInfo())
+	assert.Eventually(t, connected, 5*time.Second, 20*time.Millisecond)
+
+	// node2 drops us, e.g. its laptop slept
+	node2.Host().Network().ClosePeer(node1.ID())
+	assert.Eventually(t, func() bool { return !connected() }, 5*time.Second, 10*time.Millisecond)
+
+	// Redialed after the first backoff, and known to discovery again
+	assert.Eventually(t, connected, 10*time.Second, 50*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		_, ok := discovery.notifee.Peer(node2.ID())
+		return ok
+	}, 5*time.Second, 20*time.Millisecond)
+}
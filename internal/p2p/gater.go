@@ -0,0 +1,118 @@
+package p2p
+
+import (
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Gater only lets trusted peers connect. Untrusted peers get through while
+// a pairing window is open, or when we dial them to pair.
+type Gater struct {
+	trusted     func(peer.ID) bool
+	onUntrusted func(peer.ID)
+
+	mu      sync.Mutex
+	open    bool
+	targets map[peer.ID]int
+	relays  map[peer.ID]bool
+}
+
+var _ connmgr.ConnectionGater = (*Gater)(nil)
+
+// NewGater creates a gater admitting the peers trusted reports
+func NewGater(trusted func(peer.ID) bool) *Gater {
+	return &Gater{
+		trusted: trusted,
+		targets: make(map[peer.ID]int),
+		relays:  make(map[peer.ID]bool),
+	}
+}
+
+// SetUntrustedHandler registers a callback for untrusted peers trying to
+// connect, whether turned away or let in by the pairing window, they are
+// candidates for pairing. Peers we dial to pair are not reported. It runs
+// on libp2p's goroutines and must not block. Call before the node starts.
+func (g *Gater) SetUntrustedHandler(onUntrusted func(peer.ID)) {
+	g.onUntrusted = onUntrusted
+}
+
+// Trusted reports whether a peer may sync
+func (g *Gater) Trusted(p peer.ID) bool {
+	return g.trusted(p)
+}
+
+// SetPairingWindow lets untrusted peers connect, to pair, while open
+func (g *Gater) SetPairingWindow(open bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.open = open
+}
+
+// allowDial admits an untrusted peer we want to pair with until the
+// returned func is called
+func (g *Gater) allowDial(p peer.ID) func() {
+	g.mu.Lock()
+	g.targets[p]++
+	g.mu.Unlock()
+
+	return func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.targets[p]--; g.targets[p] <= 0 {
+			delete(g.targets, p)
+		}
+	}
+}
+
+// allowRelays admits the relays the node connects through. They are not
+// trusted, so they can't sync.
+func (g *Gater) allowRelays(relays []peer.AddrInfo) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, relay := range relays {
+		g.relays[relay.ID] = true
+	}
+}
+
+// admit decides on a peer once its ID is known
+func (g *Gater) admit(p peer.ID) bool {
+	if g.trusted(p) {
+		return true
+	}
+
+	g.mu.Lock()
+	target := g.targets[p] > 0
+	relay := g.relays[p]
+	allowed := g.open || target || relay
+	g.mu.Unlock()
+
+	if !target && !relay && g.onUntrusted != nil {
+		g.onUntrusted(p)
+	}
+	return allowed
+}
+
+func (g *Gater) InterceptPeerDial(p peer.ID) bool {
+	return g.admit(p)
+}
+
+func (g *Gater) InterceptAddrDial(peer.ID, multiaddr.Multiaddr) bool {
+	return true
+}
+
+func (g *Gater) InterceptAccept(network.ConnMultiaddrs) bool {
+	return true
+}
+
+func (g *Gater) InterceptSecured(_ network.Direction, p peer.ID, _ network.ConnMultiaddrs) bool {
+	return g.admit(p)
+}
+
+func (g *Gater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
@@ -0,0 +1,116 @@
+package p2p
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+// newGatedNode creates a node trusting the peers in the returned set
+func newGatedNode(t *testing.T) (*Node, *trustSet) {
+	t.Helper()
+	trusted := &trustSet{peers: make(map[peer.ID]bool)}
+	node, err := NewNode(context.Background(), WithGater(NewGater(trusted.has)))
+	assert.NoError(t, err)
+	t.Cleanup(func() { node.Close() })
+	return node, trusted
+}
+
+type trustSet struct {
+	mu    sync.Mutex
+	peers map[peer.ID]bool
+}
+
+func (s *trustSet) add(id peer.ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peers[id] = true
+}
+
+func (s *trustSet) has(id peer.ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.peers[id]
+}
+
+func TestGater_RejectsUntrusted(t *testing.T) {
+	ctx := context.Background()
+	node1, _ := newGatedNode(t)
+	node2, _ := newGatedNode(t)
+
+	untrusted := make(chan peer.ID, 4)
+	node1.Gater().SetUntrustedHandler(func(id peer.ID) { untrusted <- id })
+
+	err := node1.Host().Connect(ctx, node2.AddrInfo())
+	assert.Error(t, err)
+	assert.Equal(t, node2.ID(), <-untrusted)
+	assert.Empty(t, node1.AuthorizedPeers())
+}
+
+func TestGater_AdmitsTrusted(t *testing.T) {
+	ctx := context.Background()
+	node1, trusted1 := newGatedNode(t)
+	node2, trusted2 := newGatedNode(t)
+	trusted1.add(node2.ID())
+	trusted2.add(node1.ID())
+
+	err := node1.Host().Connect(ctx, node2.AddrInfo())
+	assert.NoError(t, err)
+	assert.Equal(t, []peer.ID{node2.ID()}, node1.AuthorizedPeers())
+}
+
+func TestGater_PairingWindow(t *testing.T) {
+	ctx := context.Background()
+	node1, trusted1 := newGatedNode(t)
+	node2, _ := newGatedNode(t)
+
+	// node1 dials to pair, node2 shows a code
+	trusted1.add(node2.ID())
+	node2.Gater().SetPairingWindow(true)
+
+	untrusted := make(chan peer.ID, 4)
+	node2.Gater().SetUntrustedHandler(func(id peer.ID) { untrusted <- id })
+
+	err := node1.Host().Connect(ctx, node2.AddrInfo())
+	assert.NoError(t, err)
+
+	// Let in, and still listed as waiting to pair
+	assert.Equal(t, node1.ID(), <-untrusted)
+
+	// Connected, but not allowed to sync until paired
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, node2.AuthorizedPeers())
+	assert.False(t, node2.Authorized(node1.ID()))
+}
+
+func TestStreamHandler_IgnoresUnauthorized(t *testing.T) {
+	ctx := context.Background()
+	node1, trusted1 := newGatedNode(t)
+	node2, _ := newGatedNode(t)
+	trusted1.add(node2.ID())
+	node2.Gater().SetPairingWindow(true)
+
+	var mu sync.Mutex
+	var received []ClipMessage
+	handler1 := NewStreamHandler(node1, nil)
+	handler2 := NewStreamHandler(node2, func(from peer.ID, msg ClipMessage) {
+		mu.Lock()
+		received = append(received, msg)
+		mu.Unlock()
+	})
+
+	err := node1.Host().Connect(ctx, node2.AddrInfo())
+	assert.NoError(t, err)
+
+	err = handler1.SendClip(ctx, node2.ID(), ClipMessage{Content: "sneaky", Timestamp: time.Now()})
+	assert.Error(t, err)
+	assert.Empty(t, handler2.ConnectedPeers())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Empty(t, received)
+}
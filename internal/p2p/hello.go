@@ -0,0 +1,144 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// HelloProtocolID exchanges a single Hello in each direction right after
+// peers connect, so names are known before the first clip
+const HelloProtocolID = "/clipp2p/hello/1.0.0"
+
+// helloTimeout bounds an incoming hello exchange
+const helloTimeout = 10 * time.Second
+
+// SetName sets the name announced to peers in Hello
+func (sh *StreamHandler) SetName(name string) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.name = name
+}
+
+// SetHelloHandler registers a callback for peers whose Hello is new or
+// changed, including a name change seen on a clip. Call before peers
+// connect.
+func (sh *StreamHandler) SetHelloHandler(onHello func(peer.ID, Hello)) {
+	sh.onHello = onHello
+}
+
+// localHello returns the Hello sent to peers
+func (sh *StreamHandler) localHello() Hello {
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	return Hello{
+		Name:         sh.name,
+		Version:      Version,
+		Capabilities: Capabilities,
+		OS:           runtime.GOOS,
+	}
+}
+
+// PeerHello returns the last Hello received from a peer
+func (sh *StreamHandler) PeerHello(peerID peer.ID) (Hello, bool) {
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	h, ok := sh.peerHellos[peerID]
+	return h, ok
+}
+
+// recordHello remembers what a peer announced
+func (sh *StreamHandler) recordHello(peerID peer.ID, h Hello) {
+	sh.mu.Lock()
+	prev, known := sh.peerHellos[peerID]
+	if h.Name == "" {
+		h.Name = sh.peerNames[peerID]
+	}
+	sh.peerHellos[peerID] = h
+	if h.Name != "" {
+		sh.peerNames[peerID] = h.Name
+	}
+	sh.mu.Unlock()
+
+	if !known || prev.Name != h.Name || prev.Version != h.Version || prev.OS != h.OS {
+		sh.notifyHello(peerID, h)
+	}
+}
+
+// recordName remembers the name a peer put on a clip
+func (sh *StreamHandler) recordName(peerID peer.ID, name string) {
+	sh.mu.Lock()
+	changed := sh.peerNames[peerID] != name
+	sh.peerNames[peerID] = name
+	h := sh.peerHellos[peerID]
+	h.Name = name
+	if changed {
+		sh.peerHellos[peerID] = h
+	}
+	sh.mu.Unlock()
+
+	if changed {
+		sh.notifyHello(peerID, h)
+	}
+}
+
+func (sh *StreamHandler) notifyHello(peerID peer.ID, h Hello) {
+	if sh.onHello != nil {
+		sh.onHello(peerID, h)
+	}
+}
+
+// Greet exchanges Hellos with a connected peer
+func (sh *StreamHandler) Greet(ctx context.Context, peerID peer.ID) (Hello, error) {
+	stream, err := sh.node.newStream(ctx, peerID, HelloProtocolID)
+	if err != nil {
+		return Hello{}, fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer stream.Close()
+
+	stop := context.AfterFunc(ctx, func() { stream.Reset() })
+	defer stop()
+
+	hello := sh.localHello()
+	if err := writeFrame(stream, frame{hello: &hello}); err != nil {
+		return Hello{}, fmt.Errorf("failed to write hello: %w", err)
+	}
+
+	f, err := readFrame(bufio.NewReader(stream), maxHeaderSize)
+	if err != nil {
+		return Hello{}, fmt.Errorf("failed to read hello: %w", err)
+	}
+	if f.err != nil {
+		return Hello{}, f.err
+	}
+	if f.hello == nil {
+		return Hello{}, fmt.Errorf("%w: expected hello", ErrMalformedFrame)
+	}
+
+	sh.recordHello(peerID, *f.hello)
+	return *f.hello, nil
+}
+
+// handleHello answers a peer's Hello with our own
+func (sh *StreamHandler) handleHello(stream network.Stream) {
+	if !sh.authorize(stream) {
+		return
+	}
+	defer stream.Close()
+	stream.SetDeadline(time.Now().Add(helloTimeout))
+
+	f, err := readFrame(bufio.NewReader(stream), maxHeaderSize)
+	if err != nil || f.hello == nil {
+		sendError(stream, ErrorCodeMalformed, "expected hello")
+		return
+	}
+	sh.recordHello(stream.Conn().RemotePeer(), *f.hello)
+
+	hello := sh.localHello()
+	writeFrame(stream, frame{hello: &hello})
+}
@@ -0,0 +1,103 @@
+package p2p
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamHandler_Greet(t *testing.T) {
+	ctx := context.Background()
+
+	node1, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node1.Close()
+
+	node2, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node2.Close()
+
+	handler1 := NewStreamHandler(node1, nil)
+	handler1.SetName("Node1")
+
+	var mu sync.Mutex
+	var greeted []Hello
+	handler2 := NewStreamHandler(node2, nil)
+	handler2.SetName("Node2")
+	handler2.SetHelloHandler(func(from peer.ID, h Hello) {
+		mu.Lock()
+		greeted = append(greeted, h)
+		mu.Unlock()
+	})
+
+	err = node1.Host().Connect(ctx, node2.AddrInfo())
+	assert.NoError(t, err)
+
+	hello, err := handler1.Greet(ctx, node2.ID())
+	assert.NoError(t, err)
+	assert.Equal(t, "Node2", hello.Name)
+	assert.Equal(t, runtime.GOOS, hello.OS)
+	assert.Equal(t, Version, hello.Version)
+
+	// Names are known on both sides without sending a clip
+	assert.Equal(t, "Node2", handler1.GetPeerName(node2.ID()))
+	assert.Equal(t, "Node1", handler2.GetPeerName(node1.ID()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	if assert.Len(t, greeted, 1) {
+		assert.Equal(t, "Node1", greeted[0].Name)
+	}
+}
+
+func TestStreamHandler_HelloHandlerOnNameChange(t *testing.T) {
+	ctx := context.Background()
+
+	node1, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node1.Close()
+
+	node2, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node2.Close()
+
+	handler1 := NewStreamHandler(node1, nil)
+	handler1.SetName("Node1")
+
+	names := make(chan string, 4)
+	handler2 := NewStreamHandler(node2, nil)
+	handler2.SetHelloHandler(func(from peer.ID, h Hello) {
+		names <- h.Name
+	})
+
+	err = node1.Host().Connect(ctx, node2.AddrInfo())
+	assert.NoError(t, err)
+
+	_, err = handler1.Greet(ctx, node2.ID())
+	assert.NoError(t, err)
+
+	// Repeating the same Hello is not a change
+	_, err = handler1.Greet(ctx, node2.ID())
+	assert.NoError(t, err)
+
+	err = handler1.SendClip(ctx, node2.ID(), ClipMessage{
+		Content:   "renamed",
+		Timestamp: time.Now(),
+		PeerName:  "Renamed",
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Node1", <-names)
+	select {
+	case name := <-names:
+		assert.Equal(t, "Renamed", name)
+	case <-time.After(time.Second):
+		t.Fatal("name change not reported")
+	}
+	assert.Equal(t, "Renamed", handler2.GetPeerName(node1.ID()))
+}
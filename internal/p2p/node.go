@@ -2,43 +2,188 @@ package p2p
 
 import (
 	"context"
+	"time"
 
 	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/pnet"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	relayv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
 	"github.com/multiformats/go-multiaddr"
 )
 
-// ProtocolID is our custom protocol identifier
+// ProtocolID is our custom protocol identifier. Version 1.0.0 sends
+// newline-delimited JSON and is kept for peers that predate 2.0.0.
 const ProtocolID = "/clipp2p/1.0.0"
 
+// ProtocolV2ID sends length-prefixed protobuf frames after a Hello
+// handshake, see clipp2p.proto
+const ProtocolV2ID = "/clipp2p/2.0.0"
+
+// redialTimeout bounds a dial of a peer known from a previous run
+const redialTimeout = 15 * time.Second
+
 // Node is a peer
 type Node struct {
-	host   host.Host
-	ctx    context.Context
-	cancel context.CancelFunc
+	host       host.Host
+	gater      *Gater
+	relays     *relayKeeper
+	serviceTag string
+	ctx        context.Context
+	cancel     context.CancelFunc
+}
+
+// Option configures a Node
+type Option func(*nodeOptions)
+
+type nodeOptions struct {
+	identity   crypto.PrivKey
+	gater      *Gater
+	psk        pnet.PSK
+	serviceTag string
+	listen     []string
+
+	relays         []peer.AddrInfo
+	relayService   bool
+	relayUnlimited bool
+}
+
+// WithIdentity makes the node use a persistent key, and therefore a
+// stable peer ID, instead of generating one
+func WithIdentity(priv crypto.PrivKey) Option {
+	return func(o *nodeOptions) {
+		o.identity = priv
+	}
+}
+
+// WithGater restricts the node to the peers the gater trusts
+func WithGater(g *Gater) Option {
+	return func(o *nodeOptions) {
+		o.gater = g
+	}
+}
+
+// WithPrivateNetwork only lets peers holding the same 32 byte key complete
+// a handshake with the node
+func WithPrivateNetwork(psk []byte) Option {
+	return func(o *nodeOptions) {
+		o.psk = pnet.PSK(psk)
+	}
+}
+
+// WithServiceTag sets the mDNS service name the node advertises and looks
+// for, only nodes using the same tag discover each other
+func WithServiceTag(tag string) Option {
+	return func(o *nodeOptions) {
+		o.serviceTag = tag
+	}
+}
+
+// WithListenAddrs replaces the default TCP listen addresses, e.g. to use
+// a fixed port peers can bootstrap from or more transports, see
+// ListenAddrs
+func WithListenAddrs(addrs ...string) Option {
+	return func(o *nodeOptions) {
+		o.listen = addrs
+	}
+}
+
+// WithRelays lets peers that can't reach the node directly connect
+// through these circuit relays, and upgrades such connections to direct
+// ones by hole punching where the NATs allow it
+func WithRelays(relays ...peer.AddrInfo) Option {
+	return func(o *nodeOptions) {
+		o.relays = relays
+	}
+}
+
+// WithRelayService makes the node a circuit relay for other peers, and
+// helps them find out whether they are behind a NAT. The node must be
+// publicly reachable. Relayed connections are limited, see relayLimit.
+func WithRelayService() Option {
+	return func(o *nodeOptions) {
+		o.relayService = true
+	}
+}
+
+// WithUnlimitedRelay lifts the limits of WithRelayService, for relays only
+// trusted devices can reach, e.g. in a private group
+func WithUnlimitedRelay() Option {
+	return func(o *nodeOptions) {
+		o.relayService = true
+		o.relayUnlimited = true
+	}
 }
 
 // NewNode creates and starts a node
-func NewNode(ctx context.Context) (*Node, error) {
+func NewNode(ctx context.Context, opts ...Option) (*Node, error) {
+	o := nodeOptions{
+		serviceTag: discoveryServiceTag,
+	}
+	o.listen, _ = ListenAddrs(0, TransportTCP)
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.psk != nil && hasQUIC(o.listen) {
+		return nil, ErrQUICPrivateNetwork
+	}
+
 	nodeCtx, cancel := context.WithCancel(ctx)
 
-	h, err := libp2p.New(
-		libp2p.ListenAddrStrings(
-			"/ip4/0.0.0.0/tcp/0",
-			"/ip6/::/tcp/0",
-		),
-	)
+	libp2pOpts := []libp2p.Option{
+		libp2p.ListenAddrStrings(o.listen...),
+	}
+	if o.identity != nil {
+		libp2pOpts = append(libp2pOpts, libp2p.Identity(o.identity))
+	}
+	if o.gater != nil {
+		libp2pOpts = append(libp2pOpts, libp2p.ConnectionGater(o.gater))
+	}
+	if o.psk != nil {
+		libp2pOpts = append(libp2pOpts, libp2p.PrivateNetwork(o.psk))
+	}
+	if len(o.relays) > 0 {
+		libp2pOpts = append(libp2pOpts,
+			libp2p.EnableAutoRelayWithStaticRelays(o.relays),
+			libp2p.EnableHolePunching(),
+		)
+		// Relays carry the connections, they never sync
+		if o.gater != nil {
+			o.gater.allowRelays(o.relays)
+		}
+	}
+	if o.relayService {
+		relayOpt := relayv2.WithResources(relayResources())
+		if o.relayUnlimited {
+			relayOpt = relayv2.WithInfiniteLimits()
+		}
+		libp2pOpts = append(libp2pOpts,
+			libp2p.EnableRelayService(relayOpt),
+			libp2p.EnableNATService(),
+			libp2p.ForceReachabilityPublic(),
+		)
+	}
+
+	h, err := libp2p.New(libp2pOpts...)
 	if err != nil {
 		cancel()
 		return nil, err
 	}
 
-	return &Node{
-		host:   h,
-		ctx:    nodeCtx,
-		cancel: cancel,
-	}, nil
+	n := &Node{
+		host:       h,
+		gater:      o.gater,
+		serviceTag: o.serviceTag,
+		ctx:        nodeCtx,
+		cancel:     cancel,
+	}
+	if len(o.relays) > 0 {
+		n.relays = newRelayKeeper(n, o.relays)
+	}
+	return n, nil
 }
 
 func (n *Node) ID() peer.ID {
@@ -53,11 +198,52 @@ func (n *Node) Host() host.Host {
 	return n.host
 }
 
+// Authorized reports whether a peer may sync. Without a gater every peer
+// may, with one only trusted peers: others are connected just to pair.
+func (n *Node) Authorized(p peer.ID) bool {
+	return n.gater == nil || n.gater.Trusted(p)
+}
+
+// Gater returns the node's gater, nil if every peer is admitted
+func (n *Node) Gater() *Gater {
+	return n.gater
+}
+
+// AuthorizedPeers returns the connected peers that may sync
+func (n *Node) AuthorizedPeers() []peer.ID {
+	var peers []peer.ID
+	for _, p := range n.host.Network().Peers() {
+		if n.Authorized(p) {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
 func (n *Node) Close() error {
 	n.cancel()
 	return n.host.Close()
 }
 
+// DialAddrs returns the node's addresses including its peer ID, in the
+// form peers pass to SetupBootstrap
+func (n *Node) DialAddrs() []string {
+	addrs := make([]string, 0, len(n.host.Addrs()))
+	for _, addr := range n.host.Addrs() {
+		addrs = append(addrs, addr.String()+"/p2p/"+n.ID().String())
+	}
+	return addrs
+}
+
+// RelayAddrs returns addresses peers can reach the node at through the
+// relays it holds a reservation on, in the form DialAddrs uses
+func (n *Node) RelayAddrs() []string {
+	if n.relays == nil {
+		return nil
+	}
+	return n.relays.addrs()
+}
+
 func (n *Node) AddrInfo() peer.AddrInfo {
 	return peer.AddrInfo{
 		ID:    n.host.ID(),
@@ -65,6 +251,29 @@ func (n *Node) AddrInfo() peer.AddrInfo {
 	}
 }
 
+// Redial dials peers known from a previous run in the background, so sync
+// resumes without waiting for mDNS. Peers that can't be reached are left
+// to discovery.
+func (n *Node) Redial(infos []peer.AddrInfo) {
+	for _, info := range infos {
+		if info.ID == n.ID() || len(info.Addrs) == 0 ||
+			n.host.Network().Connectedness(info.ID) == network.Connected {
+			continue
+		}
+		go func() {
+			ctx, cancel := context.WithTimeout(n.ctx, redialTimeout)
+			defer cancel()
+			n.host.Connect(ctx, info)
+		}()
+	}
+}
+
+// newStream opens a stream, also over relayed connections, which carry
+// clips until hole punching replaces them with direct ones
+func (n *Node) newStream(ctx context.Context, peerID peer.ID, protocols ...protocol.ID) (network.Stream, error) {
+	return n.host.NewStream(network.WithAllowLimitedConn(ctx, "clipp2p"), peerID, protocols...)
+}
+
 // SetupConnectionNotifier registers handlers
 func (n *Node) SetupConnectionNotifier(onConnect, onDisconnect func(peer.ID)) {
 	notifier := NewConnectionNotifier(onConnect, onDisconnect)
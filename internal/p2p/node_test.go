@@ -2,8 +2,12 @@ package p2p
 
 import (
 	"context"
+	"crypto/rand"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestNewNode_CreatesSuccessfully(t *testing.T) {
@@ -47,3 +51,79 @@ func TestNode_HostAccessible(t *testing.T) {
 	// Verify host ID matches node ID
 	assert.Equal(t, h.ID(), node.ID(), "Host().ID() should match node.ID()")
 }
+
+func TestNewNode_WithIdentity(t *testing.T) {
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	assert.NoError(t, err)
+	want, err := peer.IDFromPrivateKey(priv)
+	assert.NoError(t, err)
+
+	// The same key gives the same peer ID across restarts
+	for range 2 {
+		node, err := NewNode(context.Background(), WithIdentity(priv))
+		assert.NoError(t, err)
+		assert.Equal(t, want, node.ID())
+		node.Close()
+	}
+}
+
+func TestNewNode_WithPrivateNetwork(t *testing.T) {
+	ctx := context.Background()
+	newPSK := func() []byte {
+		psk := make([]byte, 32)
+		rand.Read(psk)
+		return psk
+	}
+	psk := newPSK()
+
+	member1, err := NewNode(ctx, WithPrivateNetwork(psk))
+	assert.NoError(t, err)
+	defer member1.Close()
+	member2, err := NewNode(ctx, WithPrivateNetwork(psk))
+	assert.NoError(t, err)
+	defer member2.Close()
+	outsider, err := NewNode(ctx, WithPrivateNetwork(newPSK()))
+	assert.NoError(t, err)
+	defer outsider.Close()
+	public, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer public.Close()
+
+	assert.NoError(t, member1.Host().Connect(ctx, member2.AddrInfo()))
+
+	// Without the key the handshake never completes
+	timeoutCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	assert.Error(t, outsider.Host().Connect(timeoutCtx, member1.AddrInfo()))
+	assert.Error(t, public.Host().Connect(timeoutCtx, member1.AddrInfo()))
+}
+
+func TestNewNode_WithListenAddrs(t *testing.T) {
+	node, err := NewNode(context.Background(), WithListenAddrs("/ip4/127.0.0.1/tcp/0"))
+	assert.NoError(t, err)
+	defer node.Close()
+
+	assert.Len(t, node.Addrs(), 1)
+	assert.Contains(t, node.DialAddrs()[0], "/ip4/127.0.0.1/tcp/")
+	assert.Contains(t, node.DialAddrs()[0], "/p2p/"+node.ID().String())
+}
+
+func TestNode_Redial(t *testing.T) {
+	ctx := context.Background()
+	node1, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node1.Close()
+	node2, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node2.Close()
+
+	// Ourselves and peers without addresses are skipped
+	node1.Redial([]peer.AddrInfo{node1.AddrInfo(), {ID: node2.ID()}})
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, node1.Host().Network().Peers())
+
+	node1.Redial([]peer.AddrInfo{node2.AddrInfo()})
+	assert.Eventually(t, func() bool {
+		return len(node1.Host().Network().ConnsToPeer(node2.ID())) > 0
+	}, 5*time.Second, 20*time.Millisecond)
+}
@@ -0,0 +1,313 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/owenHochwald/clipp2p/internal/pairing"
+)
+
+// PairProtocolID proves both devices know the pairing code, see
+// pairing.SPAKE2
+const PairProtocolID = "/clipp2p/pair/1.0.0"
+
+const (
+	// pairTimeout bounds a pairing exchange
+	pairTimeout = 30 * time.Second
+	// maxPairingFailures is how many wrong guesses a code survives
+	maxPairingFailures = 3
+)
+
+var (
+	// ErrNotPairing is returned when the peer isn't showing a code
+	ErrNotPairing = errors.New("peer is not accepting pairing requests")
+	// ErrNoGater is returned when pairing is set up on a node without a gater
+	ErrNoGater = errors.New("pairing needs a node with a gater")
+)
+
+// pairMessage is one step of the exchange: the initiator sends its share,
+// the responder answers with its share and confirmation, the initiator
+// sends its confirmation and the responder acknowledges.
+type pairMessage struct {
+	Share   []byte `json:"share,omitempty"`
+	Confirm []byte `json:"confirm,omitempty"`
+	Name    string `json:"name,omitempty"`
+	OK      bool   `json:"ok,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Pairing shows codes for other devices to type and pairs with devices
+// showing one, adding paired peers to the trust store
+type Pairing struct {
+	node     *Node
+	store    *pairing.TrustStore
+	name     string
+	onPaired func(pairing.TrustedPeer)
+	onCode   func(code string)
+
+	mu       sync.Mutex
+	code     string
+	failures int
+}
+
+// SetupPairing serves pairing requests. onPaired runs for every new
+// trusted peer, onCode when the code shown changes, empty once closed.
+func (n *Node) SetupPairing(store *pairing.TrustStore, name string, onPaired func(pairing.TrustedPeer), onCode func(string)) (*Pairing, error) {
+	if n.gater == nil {
+		return nil, ErrNoGater
+	}
+
+	p := &Pairing{
+		node:     n,
+		store:    store,
+		name:     name,
+		onPaired: onPaired,
+		onCode:   onCode,
+	}
+	n.host.SetStreamHandler(PairProtocolID, p.handleStream)
+	return p, nil
+}
+
+// Open shows a fresh code and lets untrusted peers connect to use it
+func (p *Pairing) Open() (string, error) {
+	code, err := pairing.NewCode()
+	if err != nil {
+		return "", err
+	}
+
+	p.mu.Lock()
+	p.code = code
+	p.failures = 0
+	p.mu.Unlock()
+
+	p.node.gater.SetPairingWindow(true)
+	p.notifyCode(code)
+	return code, nil
+}
+
+// Close stops accepting pairing requests and drops untrusted peers
+func (p *Pairing) Close() {
+	p.mu.Lock()
+	wasOpen := p.code != ""
+	p.code = ""
+	p.mu.Unlock()
+
+	p.node.gater.SetPairingWindow(false)
+	p.dropUntrusted()
+	if wasOpen {
+		p.notifyCode("")
+	}
+}
+
+// Code returns the code currently shown, empty if pairing is closed
+func (p *Pairing) Code() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.code
+}
+
+// Pair connects to a device showing a code and pairs with it
+func (p *Pairing) Pair(ctx context.Context, info peer.AddrInfo, code string) (trusted pairing.TrustedPeer, err error) {
+	ctx, cancel := context.WithTimeout(ctx, pairTimeout)
+	defer cancel()
+
+	release := p.node.gater.allowDial(info.ID)
+	defer func() {
+		release()
+		if err != nil {
+			p.dropUntrusted()
+		}
+	}()
+
+	if err := p.node.host.Connect(ctx, info); err != nil {
+		return trusted, fmt.Errorf("failed to connect: %w", err)
+	}
+	stream, err := p.node.newStream(ctx, info.ID, PairProtocolID)
+	if err != nil {
+		return trusted, fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer stream.Close()
+
+	stop := context.AfterFunc(ctx, func() { stream.Reset() })
+	defer stop()
+
+	exchange, err := pairing.NewSPAKE2(pairing.Initiator, pairing.NormalizeCode(code), []byte(p.node.ID()), []byte(info.ID))
+	if err != nil {
+		return trusted, err
+	}
+
+	reader := bufio.NewReader(stream)
+	if err := writeJSONLine(stream, pairMessage{Share: exchange.Share(), Name: p.name}); err != nil {
+		return trusted, err
+	}
+
+	var reply pairMessage
+	if err := readPairMessage(reader, &reply); err != nil {
+		return trusted, err
+	}
+	session, err := exchange.Finish(reply.Share)
+	if err != nil {
+		return trusted, err
+	}
+	if err := session.Verify(reply.Confirm); err != nil {
+		writeJSONLine(stream, pairMessage{Error: err.Error()})
+		return trusted, err
+	}
+
+	if err := writeJSONLine(stream, pairMessage{Confirm: session.Confirmation()}); err != nil {
+		return trusted, err
+	}
+	var ack pairMessage
+	if err := readPairMessage(reader, &ack); err != nil {
+		return trusted, err
+	}
+
+	return p.trust(info.ID, reply.Name)
+}
+
+// handleStream answers a device typing our code
+func (p *Pairing) handleStream(stream network.Stream) {
+	defer stream.Close()
+	stream.SetDeadline(time.Now().Add(pairTimeout))
+
+	reader := bufio.NewReader(stream)
+	remotePeer := stream.Conn().RemotePeer()
+
+	var request pairMessage
+	if err := readPairMessage(reader, &request); err != nil {
+		return
+	}
+
+	code := p.Code()
+	if code == "" {
+		writeJSONLine(stream, pairMessage{Error: ErrNotPairing.Error()})
+		return
+	}
+
+	exchange, err := pairing.NewSPAKE2(pairing.Responder, code, []byte(remotePeer), []byte(p.node.ID()))
+	if err != nil {
+		return
+	}
+	session, err := exchange.Finish(request.Share)
+	if err != nil {
+		writeJSONLine(stream, pairMessage{Error: err.Error()})
+		return
+	}
+
+	reply := pairMessage{Share: exchange.Share(), Confirm: session.Confirmation(), Name: p.name}
+	if err := writeJSONLine(stream, reply); err != nil {
+		return
+	}
+
+	// From here on the peer has made its guess, count it even if it
+	// gives up without confirming
+	var confirm pairMessage
+	err = readPairMessage(reader, &confirm)
+	if err == nil {
+		err = session.Verify(confirm.Confirm)
+	}
+	if err != nil {
+		writeJSONLine(stream, pairMessage{Error: err.Error()})
+		p.failed(code)
+		return
+	}
+
+	// The peer proved it knows the code, trust it before acknowledging so
+	// it finds us ready to sync
+	if _, err := p.trust(remotePeer, request.Name); err != nil {
+		writeJSONLine(stream, pairMessage{Error: err.Error()})
+		return
+	}
+	writeJSONLine(stream, pairMessage{OK: true})
+
+	// A code pairs one device, show a new one for the next
+	p.rotate(code)
+}
+
+// readPairMessage reads a step of the exchange, turning a peer's error
+// into an error
+func readPairMessage(r *bufio.Reader, msg *pairMessage) error {
+	if err := readJSONLine(r, maxHeaderSize, msg); err != nil {
+		return fmt.Errorf("failed to read pairing message: %w", err)
+	}
+	if msg.Error != "" {
+		if msg.Error == pairing.ErrConfirmation.Error() {
+			return pairing.ErrConfirmation
+		}
+		return fmt.Errorf("peer refused pairing: %s", msg.Error)
+	}
+	return nil
+}
+
+// trust stores a newly paired peer
+func (p *Pairing) trust(id peer.ID, name string) (pairing.TrustedPeer, error) {
+	trusted := pairing.TrustedPeer{ID: id, Name: name, PairedAt: time.Now()}
+	if err := p.store.Add(trusted); err != nil {
+		return trusted, err
+	}
+	if p.onPaired != nil {
+		p.onPaired(trusted)
+	}
+	return trusted, nil
+}
+
+// failed counts a wrong guess, replacing the code after too many
+func (p *Pairing) failed(code string) {
+	p.mu.Lock()
+	if p.code != code {
+		p.mu.Unlock()
+		return
+	}
+	p.failures++
+	exhausted := p.failures >= maxPairingFailures
+	p.mu.Unlock()
+
+	if exhausted {
+		p.rotate(code)
+	}
+}
+
+// rotate replaces code with a new one if it is still shown
+func (p *Pairing) rotate(code string) {
+	next, err := pairing.NewCode()
+	if err != nil {
+		return
+	}
+
+	p.mu.Lock()
+	if p.code != code {
+		p.mu.Unlock()
+		return
+	}
+	p.code = next
+	p.failures = 0
+	p.mu.Unlock()
+
+	p.notifyCode(next)
+}
+
+func (p *Pairing) notifyCode(code string) {
+	if p.onCode != nil {
+		p.onCode(code)
+	}
+}
+
+// dropUntrusted disconnects peers that were let in to pair but didn't,
+// unless the pairing window is still open
+func (p *Pairing) dropUntrusted() {
+	if p.Code() != "" {
+		return
+	}
+	for _, id := range p.node.host.Network().Peers() {
+		if !p.node.Authorized(id) {
+			p.node.host.Network().ClosePeer(id)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/owenHochwald/clipp2p/internal/pairing"
+)
+
+// newPairingNode creates a gated node trusting what it pairs with
+func newPairingNode(t *testing.T, name string) (*Node, *Pairing, *pairing.TrustStore) {
+	t.Helper()
+	store, err := pairing.LoadTrustStore(t.TempDir())
+	assert.NoError(t, err)
+
+	node, err := NewNode(context.Background(), WithGater(NewGater(store.Trusted)))
+	assert.NoError(t, err)
+	t.Cleanup(func() { node.Close() })
+
+	p, err := node.SetupPairing(store, name, nil, nil)
+	assert.NoError(t, err)
+	return node, p, store
+}
+
+func TestPairing_SameCode(t *testing.T) {
+	ctx := context.Background()
+	laptop, laptopPairing, laptopStore := newPairingNode(t, "laptop")
+	desktop, desktopPairing, desktopStore := newPairingNode(t, "desktop")
+
+	code, err := desktopPairing.Open()
+	assert.NoError(t, err)
+
+	trusted, err := laptopPairing.Pair(ctx, desktop.AddrInfo(), pairing.FormatCode(code))
+	assert.NoError(t, err)
+	assert.Equal(t, desktop.ID(), trusted.ID)
+	assert.Equal(t, "desktop", trusted.Name)
+
+	assert.True(t, laptopStore.Trusted(desktop.ID()))
+	assert.True(t, desktopStore.Trusted(laptop.ID()))
+	assert.True(t, laptop.Authorized(desktop.ID()))
+
+	// The code was used up
+	assert.NotEqual(t, code, desktopPairing.Code())
+	assert.NotEmpty(t, desktopPairing.Code())
+}
+
+func TestPairing_WrongCode(t *testing.T) {
+	ctx := context.Background()
+	laptop, laptopPairing, laptopStore := newPairingNode(t, "laptop")
+	desktop, desktopPairing, desktopStore := newPairingNode(t, "desktop")
+
+	code, err := desktopPairing.Open()
+	assert.NoError(t, err)
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	_, err = laptopPairing.Pair(ctx, desktop.AddrInfo(), wrong)
+	assert.ErrorIs(t, err, pairing.ErrConfirmation)
+	assert.False(t, laptopStore.Trusted(desktop.ID()))
+	assert.False(t, desktopStore.Trusted(laptop.ID()))
+
+	// A single wrong guess doesn't burn the code
+	assert.Equal(t, code, desktopPairing.Code())
+}
+
+func TestPairing_TooManyGuesses(t *testing.T) {
+	ctx := context.Background()
+	_, laptopPairing, _ := newPairingNode(t, "laptop")
+	desktop, desktopPairing, _ := newPairingNode(t, "desktop")
+
+	code, err := desktopPairing.Open()
+	assert.NoError(t, err)
+
+	for range maxPairingFailures {
+		guess := "000000"
+		if code == guess {
+			guess = "111111"
+		}
+		_, err = laptopPairing.Pair(ctx, desktop.AddrInfo(), guess)
+		assert.Error(t, err)
+	}
+
+	// The responder counts the last guess after the initiator gave up
+	assert.Eventually(t, func() bool {
+		return desktopPairing.Code() != code
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestPairing_NotOpen(t *testing.T) {
+	ctx := context.Background()
+	_, laptopPairing, _ := newPairingNode(t, "laptop")
+	desktop, _, _ := newPairingNode(t, "desktop")
+
+	_, err := laptopPairing.Pair(ctx, desktop.AddrInfo(), "123456")
+	assert.Error(t, err)
+}
+
+func TestPairing_CloseDropsUntrusted(t *testing.T) {
+	ctx := context.Background()
+	laptop, _, _ := newPairingNode(t, "laptop")
+	desktop, desktopPairing, _ := newPairingNode(t, "desktop")
+
+	_, err := desktopPairing.Open()
+	assert.NoError(t, err)
+
+	// Let the laptop dial without pairing
+	release := laptop.Gater().allowDial(desktop.ID())
+	defer release()
+	assert.NoError(t, laptop.Host().Connect(ctx, desktop.AddrInfo()))
+
+	desktopPairing.Close()
+	assert.Empty(t, desktop.Host().Network().ConnsToPeer(laptop.ID()))
+	assert.Empty(t, desktopPairing.Code())
+}
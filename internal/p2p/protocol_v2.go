@@ -0,0 +1,144 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/libp2p/go-libp2p/core/network"
+)
+
+// errNoImages refuses to send an image to a peer that can't take one
+var errNoImages = &ProtocolError{Code: ErrorCodeUnsupported, Message: "peer does not accept images"}
+
+// maxFrameSize bounds a clip frame on the 2.0.0 protocol
+func (sh *StreamHandler) maxFrameSize() int {
+	return int(min(sh.maxClipSize+maxHeaderSize, 1<<31-1))
+}
+
+// sendError reports a refused frame to the peer
+func sendError(stream network.Stream, code ErrorCode, format string, args ...any) {
+	writeFrame(stream, frame{err: &ProtocolError{Code: code, Message: fmt.Sprintf(format, args...)}})
+}
+
+// handleStreamV2 serves the 2.0.0 protocol: a Hello from each side, then
+// clip frames until the dialer closes. Refused frames are answered with an
+// error frame instead of being dropped silently, and dialers that keep a
+// session open get an ack for every clip.
+func (sh *StreamHandler) handleStreamV2(stream network.Stream) {
+	if !sh.authorize(stream) {
+		return
+	}
+	defer stream.Close()
+
+	reader := bufio.NewReader(stream)
+	remotePeer := stream.Conn().RemotePeer()
+
+	f, err := readFrame(reader, maxHeaderSize)
+	if err != nil || f.hello == nil {
+		sendError(stream, ErrorCodeMalformed, "expected hello")
+		return
+	}
+	sh.recordHello(remotePeer, *f.hello)
+	acks := f.hello.Supports(CapabilitySession)
+
+	hello := sh.localHello()
+	if err := writeFrame(stream, frame{hello: &hello}); err != nil {
+		return
+	}
+
+	for {
+		f, err := readFrame(reader, sh.maxFrameSize())
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrFrameTooLarge):
+			sendError(stream, ErrorCodeTooLarge, "%v, limit is %d bytes", err, sh.maxClipSize)
+			return
+		case errors.Is(err, ErrMalformedFrame):
+			sendError(stream, ErrorCodeMalformed, "%v", err)
+			return
+		default:
+			return
+		}
+
+		switch {
+		case f.clip != nil:
+			if size := len(clipPayload(*f.clip)); int64(size) > sh.maxClipSize {
+				sendError(stream, ErrorCodeTooLarge, "clip of %d bytes, limit is %d bytes", size, sh.maxClipSize)
+				continue
+			}
+			sh.receive(remotePeer, *f.clip)
+			if acks {
+				if err := writeFrame(stream, frame{ack: &ack{id: f.clip.ID}}); err != nil {
+					return
+				}
+			}
+		case f.err != nil:
+			return
+		default:
+			sendError(stream, ErrorCodeUnsupported, "unexpected frame")
+		}
+	}
+}
+
+// sendV2 exchanges Hellos on an open 2.0.0 stream, sends msg and waits for
+// the peer to either close the stream or refuse the clip
+func (sh *StreamHandler) sendV2(ctx context.Context, stream network.Stream, msg ClipMessage) error {
+	stop := context.AfterFunc(ctx, func() { stream.Reset() })
+	defer stop()
+
+	hello, reader, err := sh.exchangeHelloV2(stream)
+	if err != nil {
+		return err
+	}
+	return sendOnceV2(stream, reader, hello, msg)
+}
+
+// exchangeHelloV2 opens a 2.0.0 stream with a Hello from each side and
+// returns the peer's
+func (sh *StreamHandler) exchangeHelloV2(stream network.Stream) (Hello, *bufio.Reader, error) {
+	reader := bufio.NewReader(stream)
+	hello := sh.localHello()
+	if err := writeFrame(stream, frame{hello: &hello}); err != nil {
+		return Hello{}, nil, fmt.Errorf("failed to write hello: %w", err)
+	}
+
+	f, err := readFrame(reader, maxHeaderSize)
+	if err != nil {
+		return Hello{}, nil, fmt.Errorf("failed to read hello: %w", err)
+	}
+	if f.err != nil {
+		return Hello{}, nil, f.err
+	}
+	if f.hello == nil {
+		return Hello{}, nil, fmt.Errorf("%w: expected hello", ErrMalformedFrame)
+	}
+	sh.recordHello(stream.Conn().RemotePeer(), *f.hello)
+	return *f.hello, reader, nil
+}
+
+// sendOnceV2 sends the only clip of a stream and waits for the peer to
+// close it, peers without sessions don't ack
+func sendOnceV2(stream network.Stream, reader *bufio.Reader, hello Hello, msg ClipMessage) error {
+	if msg.IsImage() && !hello.Supports(CapabilityImage) {
+		return errNoImages
+	}
+
+	if err := writeFrame(stream, frame{clip: &msg}); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	stream.CloseWrite()
+
+	f, err := readFrame(reader, maxHeaderSize)
+	switch {
+	case err == io.EOF:
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to read reply: %w", err)
+	case f.err != nil:
+		return f.err
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTwoNodes_HelloExchange(t *testing.T) {
+	ctx := context.Background()
+
+	node1, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node1.Close()
+
+	node2, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node2.Close()
+
+	handler1 := NewStreamHandler(node1, nil)
+	handler1.SetName("Node1")
+
+	received := make(chan ClipMessage, 1)
+	handler2 := NewStreamHandler(node2, func(from peer.ID, msg ClipMessage) {
+		received <- msg
+	})
+	handler2.SetName("Node2")
+
+	err = node1.Host().Connect(ctx, node2.AddrInfo())
+	assert.NoError(t, err)
+
+	err = handler1.SendClip(ctx, node2.ID(), ClipMessage{
+		Content:   "over protobuf",
+		Timestamp: time.Now(),
+	})
+	assert.NoError(t, err)
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "over protobuf", msg.Content)
+	case <-time.After(time.Second):
+		t.Fatal("clip not received")
+	}
+
+	// Both sides learn each other's name before any named clip
+	assert.Equal(t, "Node2", handler1.GetPeerName(node2.ID()))
+	assert.Equal(t, "Node1", handler2.GetPeerName(node1.ID()))
+
+	hello, ok := handler2.PeerHello(node1.ID())
+	assert.True(t, ok)
+	assert.Equal(t, Version, hello.Version)
+	assert.True(t, hello.Supports(CapabilityImage))
+}
+
+func TestTwoNodes_ErrorFrame(t *testing.T) {
+	ctx := context.Background()
+
+	node1, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node1.Close()
+
+	node2, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node2.Close()
+
+	handler1 := NewStreamHandler(node1, nil)
+	handler2 := NewStreamHandler(node2, nil)
+	handler2.SetMaxClipSize(16)
+
+	err = node1.Host().Connect(ctx, node2.AddrInfo())
+	assert.NoError(t, err)
+
+	err = handler1.SendClip(ctx, node2.ID(), ClipMessage{
+		Content:   "longer than sixteen bytes",
+		Timestamp: time.Now(),
+	})
+
+	var protoErr *ProtocolError
+	if assert.True(t, errors.As(err, &protoErr)) {
+		assert.Equal(t, ErrorCodeTooLarge, protoErr.Code)
+	}
+}
+
+func TestTwoNodes_FallbackToV1(t *testing.T) {
+	ctx := context.Background()
+
+	node1, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node1.Close()
+
+	node2, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node2.Close()
+
+	var mu sync.Mutex
+	var receivedMsgs []ClipMessage
+
+	handler1 := NewStreamHandler(node1, nil)
+	NewStreamHandler(node2, func(from peer.ID, msg ClipMessage) {
+		mu.Lock()
+		receivedMsgs = append(receivedMsgs, msg)
+		mu.Unlock()
+	})
+
+	// Make node2 look like a peer that only speaks 1.0.0
+	node2.Host().RemoveStreamHandler(ProtocolV2ID)
+	node2.Host().RemoveStreamHandler(TransferProtocolID)
+
+	err = node1.Host().Connect(ctx, node2.AddrInfo())
+	assert.NoError(t, err)
+
+	err = handler1.SendClip(ctx, node2.ID(), ClipMessage{
+		Content:   "plain text",
+		Timestamp: time.Now(),
+		PeerName:  "Node1",
+	})
+	assert.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, 1, len(receivedMsgs))
+	assert.Equal(t, "plain text", receivedMsgs[0].Content)
+}
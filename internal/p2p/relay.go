@@ -0,0 +1,127 @@
+package p2p
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/client"
+	relayv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
+	"github.com/multiformats/go-multiaddr"
+)
+
+const (
+	// relayConnDuration and relayConnData limit each connection relayed
+	// for others, so a public relay isn't an open proxy. The data allows
+	// a few clips of the largest size before peers have to reconnect.
+	relayConnDuration = time.Hour
+	relayConnData     = 4 * DefaultMaxClipSize
+
+	// reservationRefresh is how long before expiry a reservation is renewed
+	reservationRefresh = 5 * time.Minute
+	// reserveTimeout bounds a reservation request
+	reserveTimeout = 30 * time.Second
+)
+
+// relayKeeper holds a reservation on each configured relay, so peers that
+// can't reach us directly can connect through it
+type relayKeeper struct {
+	node   *Node
+	relays []peer.AddrInfo
+
+	mu       sync.RWMutex
+	reserved map[peer.ID]time.Time
+}
+
+func newRelayKeeper(n *Node, relays []peer.AddrInfo) *relayKeeper {
+	k := &relayKeeper{
+		node:     n,
+		relays:   relays,
+		reserved: make(map[peer.ID]time.Time),
+	}
+	for _, relay := range relays {
+		go k.keep(relay)
+	}
+	return k
+}
+
+// keep renews the reservation on a relay before it expires, retrying with
+// backoff while the relay is unreachable
+func (k *relayKeeper) keep(relay peer.AddrInfo) {
+	bo := newBackoff()
+	for {
+		ctx, cancel := context.WithTimeout(k.node.ctx, reserveTimeout)
+		rsvp, err := client.Reserve(ctx, k.node.host, relay)
+		cancel()
+
+		var wait time.Duration
+		if err != nil {
+			k.setReserved(relay.ID, time.Time{})
+			wait = bo.next()
+		} else {
+			bo.reset()
+			k.setReserved(relay.ID, rsvp.Expiration)
+			wait = max(time.Until(rsvp.Expiration)-reservationRefresh, minRedialDelay)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-k.node.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (k *relayKeeper) setReserved(id peer.ID, expiration time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if expiration.IsZero() {
+		delete(k.reserved, id)
+	} else {
+		k.reserved[id] = expiration
+	}
+}
+
+// addrs returns the circuit addresses of the relays we hold a reservation on
+func (k *relayKeeper) addrs() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	var addrs []string
+	for _, relay := range k.relays {
+		if time.Now().After(k.reserved[relay.ID]) {
+			continue
+		}
+		for _, addr := range relay.Addrs {
+			addrs = append(addrs, addr.String()+"/p2p/"+relay.ID.String()+"/p2p-circuit/p2p/"+k.node.ID().String())
+		}
+	}
+	return addrs
+}
+
+// ParseRelays parses relay multiaddrs ending in /p2p/<peer-id>
+func ParseRelays(addrs []string) ([]peer.AddrInfo, error) {
+	maddrs := make([]multiaddr.Multiaddr, 0, len(addrs))
+	for _, s := range addrs {
+		addr, err := multiaddr.NewMultiaddr(s)
+		if err != nil {
+			return nil, err
+		}
+		maddrs = append(maddrs, addr)
+	}
+	return peer.AddrInfosFromP2pAddrs(maddrs...)
+}
+
+// relayResources are the default relay resources with room for clips in
+// each relayed connection
+func relayResources() relayv2.Resources {
+	rc := relayv2.DefaultResources()
+	rc.Limit = &relayv2.RelayLimit{
+		Duration: relayConnDuration,
+		Data:     relayConnData,
+	}
+	return rc
+}
@@ -0,0 +1,88 @@
+package p2p
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/assert"
+)
+
+// newRelay starts an in-process relay on loopback
+func newRelay(t *testing.T) (*Node, peer.AddrInfo) {
+	t.Helper()
+	relay, err := NewNode(context.Background(), WithRelayService(), WithListenAddrs("/ip4/127.0.0.1/tcp/0"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { relay.Close() })
+	return relay, relay.AddrInfo()
+}
+
+func TestRelay_ConnectsThroughCircuit(t *testing.T) {
+	ctx := context.Background()
+	_, relayInfo := newRelay(t)
+
+	// Both peers are gated, the relay gets in without being trusted
+	trustedA := &trustSet{peers: make(map[peer.ID]bool)}
+	trustedB := &trustSet{peers: make(map[peer.ID]bool)}
+	nodeA, err := NewNode(ctx, WithGater(NewGater(trustedA.has)), WithRelays(relayInfo))
+	assert.NoError(t, err)
+	defer nodeA.Close()
+	nodeB, err := NewNode(ctx, WithGater(NewGater(trustedB.has)), WithRelays(relayInfo))
+	assert.NoError(t, err)
+	defer nodeB.Close()
+	trustedA.add(nodeB.ID())
+	trustedB.add(nodeA.ID())
+
+	var mu sync.Mutex
+	var received []ClipMessage
+	NewStreamHandler(nodeA, func(from peer.ID, msg ClipMessage) {
+		mu.Lock()
+		received = append(received, msg)
+		mu.Unlock()
+	})
+	handlerB := NewStreamHandler(nodeB, nil)
+
+	// nodeA is reachable through the relay once it holds a reservation
+	assert.Eventually(t, func() bool { return len(nodeA.RelayAddrs()) > 0 }, 10*time.Second, 50*time.Millisecond)
+	circuit := nodeA.RelayAddrs()[0]
+	assert.Contains(t, circuit, "/p2p-circuit/p2p/"+nodeA.ID().String())
+	assert.False(t, nodeA.Authorized(relayInfo.ID), "relays never sync")
+
+	// nodeB only knows the circuit address
+	info, err := peer.AddrInfoFromString(circuit)
+	assert.NoError(t, err)
+	assert.NoError(t, nodeB.Host().Connect(ctx, *info))
+
+	relayed := false
+	for _, conn := range nodeB.Host().Network().ConnsToPeer(nodeA.ID()) {
+		if _, err := conn.RemoteMultiaddr().ValueForProtocol(multiaddr.P_CIRCUIT); err == nil {
+			relayed = true
+			assert.True(t, conn.Stat().Limited, "the relay limits relayed connections")
+		}
+	}
+	assert.True(t, relayed, "connected through the relay")
+
+	err = handlerB.SendClip(ctx, nodeA.ID(), ClipMessage{Content: "via relay", Timestamp: time.Now()})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 1 && received[0].Content == "via relay"
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestParseRelays(t *testing.T) {
+	relay, relayInfo := newRelay(t)
+
+	infos, err := ParseRelays(relay.DialAddrs())
+	assert.NoError(t, err)
+	assert.Len(t, infos, 1)
+	assert.Equal(t, relayInfo.ID, infos[0].ID)
+
+	_, err = ParseRelays([]string{strings.TrimSuffix(relay.DialAddrs()[0], "/p2p/"+relay.ID().String())})
+	assert.Error(t, err, "relay address without a peer ID")
+}
@@ -0,0 +1,161 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// errUnsent is returned when a clip couldn't be written to a session, so
+// it can go out again on a new stream without arriving twice
+var errUnsent = errors.New("failed to write message")
+
+// session is the long-lived 2.0.0 stream to one peer. Clips are sent one at
+// a time and acked before the next, so they arrive in the order sent.
+type session struct {
+	mu     sync.Mutex
+	stream network.Stream
+	reader *bufio.Reader
+	hello  Hello
+}
+
+// sessions keeps one session per peer
+type sessions struct {
+	mu    sync.Mutex
+	peers map[peer.ID]*session
+}
+
+func newSessions() *sessions {
+	return &sessions{peers: make(map[peer.ID]*session)}
+}
+
+// get returns the session to a peer, creating an unopened one
+func (ss *sessions) get(id peer.ID) *session {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	s, ok := ss.peers[id]
+	if !ok {
+		s = &session{}
+		ss.peers[id] = s
+	}
+	return s
+}
+
+// drop forgets the session to a peer and closes its stream. It runs on
+// libp2p's goroutines, so waiting for a send in flight is left to another.
+func (ss *sessions) drop(id peer.ID) {
+	ss.mu.Lock()
+	s, ok := ss.peers[id]
+	delete(ss.peers, id)
+	ss.mu.Unlock()
+
+	if ok {
+		go func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.close()
+		}()
+	}
+}
+
+// open starts using a stream whose Hellos were exchanged
+func (s *session) open(stream network.Stream, reader *bufio.Reader, hello Hello) {
+	s.stream = stream
+	s.reader = reader
+	s.hello = hello
+}
+
+// close resets the stream, the next send opens a new one
+func (s *session) close() {
+	if s.stream != nil {
+		s.stream.Reset()
+	}
+	s.stream = nil
+	s.reader = nil
+}
+
+// send writes a clip and waits for its ack. A stream that fails is closed,
+// a clip the peer refuses leaves it open.
+func (s *session) send(ctx context.Context, msg ClipMessage) error {
+	if msg.IsImage() && !s.hello.Supports(CapabilityImage) {
+		return errNoImages
+	}
+
+	stream := s.stream
+	stop := context.AfterFunc(ctx, func() { stream.Reset() })
+	defer stop()
+
+	if err := writeFrame(stream, frame{clip: &msg}); err != nil {
+		s.close()
+		return fmt.Errorf("%w: %w", errUnsent, err)
+	}
+
+	f, err := readFrame(s.reader, maxHeaderSize)
+	switch {
+	case err != nil:
+		s.close()
+		return fmt.Errorf("failed to read reply: %w", err)
+	case f.err != nil:
+		return f.err
+	case f.ack == nil:
+		s.close()
+		return fmt.Errorf("%w: expected ack", ErrMalformedFrame)
+	case f.ack.id != msg.ID:
+		s.close()
+		return fmt.Errorf("%w: ack for clip %q, sent %q", ErrMalformedFrame, f.ack.id, msg.ID)
+	}
+	return nil
+}
+
+// sendSession sends a clip over the peer's session, opening one when there
+// is none, and reports whether the peer acked it. Peers that don't keep
+// sessions get a stream per clip and don't ack.
+func (sh *StreamHandler) sendSession(ctx context.Context, peerID peer.ID, msg ClipMessage) (bool, error) {
+	s := sh.sessions.get(peerID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stream != nil {
+		err := s.send(ctx, msg)
+		if !errors.Is(err, errUnsent) || ctx.Err() != nil {
+			return true, err
+		}
+		// The stream was reset while idle, e.g. the peer restarted, so the
+		// clip goes out again on a new one. Once written the peer may have
+		// it, and it isn't sent again.
+	}
+
+	stream, err := sh.node.newStream(ctx, peerID, ProtocolV2ID, ProtocolID)
+	if err != nil {
+		return false, fmt.Errorf("failed to open stream: %w", err)
+	}
+
+	if stream.Protocol() != ProtocolV2ID {
+		// Older peers only speak newline-delimited JSON
+		defer stream.Close()
+		return false, writeMessage(stream, msg)
+	}
+
+	stop := context.AfterFunc(ctx, func() { stream.Reset() })
+	hello, reader, err := sh.exchangeHelloV2(stream)
+	stop()
+	if err != nil {
+		stream.Reset()
+		return false, err
+	}
+
+	if !hello.Supports(CapabilitySession) {
+		defer stream.Close()
+		stop = context.AfterFunc(ctx, func() { stream.Reset() })
+		defer stop()
+		return false, sendOnceV2(stream, reader, hello, msg)
+	}
+
+	s.open(stream, reader, hello)
+	return true, s.send(ctx, msg)
+}
@@ -0,0 +1,172 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSessionPair connects two nodes, the second recording clips received
+// and accepting clips of up to maxClipSize bytes
+func newSessionPair(t *testing.T, maxClipSize int64) (*Node, *StreamHandler, *Node, func() []string) {
+	t.Helper()
+	ctx := context.Background()
+
+	node1, err := NewNode(ctx)
+	assert.NoError(t, err)
+	t.Cleanup(func() { node1.Close() })
+
+	node2, err := NewNode(ctx)
+	assert.NoError(t, err)
+	t.Cleanup(func() { node2.Close() })
+
+	var mu sync.Mutex
+	var received []string
+	handler1 := NewStreamHandler(node1, nil)
+	handler2 := NewStreamHandler(node2, func(from peer.ID, msg ClipMessage) {
+		mu.Lock()
+		received = append(received, msg.Content)
+		mu.Unlock()
+	})
+	handler2.SetMaxClipSize(maxClipSize)
+
+	assert.NoError(t, node1.Host().Connect(ctx, node2.AddrInfo()))
+
+	return node1, handler1, node2, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), received...)
+	}
+}
+
+// v2Streams counts the open 2.0.0 streams from node to p
+func v2Streams(node *Node, p peer.ID) int {
+	count := 0
+	for _, conn := range node.Host().Network().ConnsToPeer(p) {
+		for _, s := range conn.GetStreams() {
+			if s.Protocol() == ProtocolV2ID && s.Stat().Direction == network.DirOutbound {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func TestSession_ReusesStream(t *testing.T) {
+	ctx := context.Background()
+	node1, handler1, node2, received := newSessionPair(t, DefaultMaxClipSize)
+
+	var sent []string
+	for i := range 5 {
+		content := fmt.Sprintf("clip %d", i)
+		assert.NoError(t, handler1.SendClip(ctx, node2.ID(), ClipMessage{Content: content, Timestamp: time.Now()}))
+		sent = append(sent, content)
+	}
+
+	// Every clip is acked, so all arrived and in order
+	assert.Equal(t, sent, received())
+	assert.Equal(t, 1, v2Streams(node1, node2.ID()))
+}
+
+func TestSession_ReopensAfterReset(t *testing.T) {
+	ctx := context.Background()
+	_, handler1, node2, received := newSessionPair(t, DefaultMaxClipSize)
+
+	assert.NoError(t, handler1.SendClip(ctx, node2.ID(), ClipMessage{Content: "first", Timestamp: time.Now()}))
+
+	s := handler1.sessions.get(node2.ID())
+	s.mu.Lock()
+	s.stream.Reset()
+	s.mu.Unlock()
+
+	assert.NoError(t, handler1.SendClip(ctx, node2.ID(), ClipMessage{Content: "second", Timestamp: time.Now()}))
+	assert.Equal(t, []string{"first", "second"}, received())
+}
+
+func TestSession_RefusedClipKeepsStream(t *testing.T) {
+	ctx := context.Background()
+	node1, handler1, node2, received := newSessionPair(t, 16)
+
+	err := handler1.SendClip(ctx, node2.ID(), ClipMessage{Content: "longer than sixteen bytes", Timestamp: time.Now()})
+	var protoErr *ProtocolError
+	if assert.ErrorAs(t, err, &protoErr) {
+		assert.Equal(t, ErrorCodeTooLarge, protoErr.Code)
+	}
+
+	assert.NoError(t, handler1.SendClip(ctx, node2.ID(), ClipMessage{Content: "short", Timestamp: time.Now()}))
+	assert.Equal(t, []string{"short"}, received())
+	assert.Equal(t, 1, v2Streams(node1, node2.ID()))
+}
+
+func TestSession_ClosedOnDisconnect(t *testing.T) {
+	ctx := context.Background()
+	node1, handler1, node2, received := newSessionPair(t, DefaultMaxClipSize)
+
+	assert.NoError(t, handler1.SendClip(ctx, node2.ID(), ClipMessage{Content: "before", Timestamp: time.Now()}))
+
+	assert.NoError(t, node1.Host().Network().ClosePeer(node2.ID()))
+	assert.Eventually(t, func() bool {
+		handler1.sessions.mu.Lock()
+		defer handler1.sessions.mu.Unlock()
+		return len(handler1.sessions.peers) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, node1.Host().Connect(ctx, node2.AddrInfo()))
+	assert.NoError(t, handler1.SendClip(ctx, node2.ID(), ClipMessage{Content: "after", Timestamp: time.Now()}))
+	assert.Equal(t, []string{"before", "after"}, received())
+}
+
+func TestSession_NoResendOnceWritten(t *testing.T) {
+	ctx := context.Background()
+
+	sender, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer sender.Close()
+	handler := NewStreamHandler(sender, nil)
+
+	// The peer acks the first clip and drops the stream after reading
+	// the second, as if it crashed before acking
+	receiver, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer receiver.Close()
+	local := NewStreamHandler(receiver, nil)
+	var mu sync.Mutex
+	var received []string
+	receiver.Host().SetStreamHandler(ProtocolV2ID, func(stream network.Stream) {
+		reader := bufio.NewReader(stream)
+		readFrame(reader, maxHeaderSize)
+		hello := local.localHello()
+		writeFrame(stream, frame{hello: &hello})
+		for {
+			f, err := readFrame(reader, 1<<20)
+			if err != nil || f.clip == nil {
+				return
+			}
+			mu.Lock()
+			received = append(received, f.clip.Content)
+			first := len(received) == 1
+			mu.Unlock()
+			if !first {
+				stream.Reset()
+				return
+			}
+			writeFrame(stream, frame{ack: &ack{id: f.clip.ID}})
+		}
+	})
+	assert.NoError(t, sender.Host().Connect(ctx, receiver.AddrInfo()))
+
+	assert.NoError(t, handler.SendClip(ctx, receiver.ID(), ClipMessage{ID: "1", Content: "one", Timestamp: time.Now()}))
+	assert.Error(t, handler.SendClip(ctx, receiver.ID(), ClipMessage{ID: "2", Content: "two", Timestamp: time.Now()}))
+
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"one", "two"}, received)
+}
@@ -0,0 +1,72 @@
+package p2p
+
+import (
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// PeerStats times the clips delivered to a peer
+type PeerStats struct {
+	Delivered int
+	// Failed counts clips rejected, timed out or lost, superseded clips
+	// and clips sent to peers that don't ack don't count
+	Failed int
+	// Last is how long the last delivered clip took to be acked, Average
+	// a moving average weighted towards recent clips
+	Last    time.Duration
+	Average time.Duration
+}
+
+// statsWeight is how many clips the moving average roughly spans
+const statsWeight = 5
+
+// peerStats keeps the stats of every peer sent to
+type peerStats struct {
+	mu    sync.Mutex
+	peers map[peer.ID]PeerStats
+}
+
+func newPeerStats() *peerStats {
+	return &peerStats{peers: make(map[peer.ID]PeerStats)}
+}
+
+// record adds the outcome of a send. Unacked sends say nothing about how
+// long delivery takes.
+func (ps *peerStats) record(r DeliveryResult) {
+	if r.Status == DeliverySuperseded || r.Status == DeliverySent {
+		return
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	s := ps.peers[r.Peer]
+	if !r.Delivered() {
+		s.Failed++
+		ps.peers[r.Peer] = s
+		return
+	}
+
+	s.Delivered++
+	s.Last = r.Duration
+	if s.Delivered == 1 {
+		s.Average = r.Duration
+	} else {
+		s.Average += (r.Duration - s.Average) / statsWeight
+	}
+	ps.peers[r.Peer] = s
+}
+
+func (ps *peerStats) get(id peer.ID) (PeerStats, bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	s, ok := ps.peers[id]
+	return s, ok
+}
+
+// PeerStats returns how clips sent to a peer fared
+func (sh *StreamHandler) PeerStats(peerID peer.ID) (PeerStats, bool) {
+	return sh.stats.get(peerID)
+}
@@ -0,0 +1,45 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeerStats_Record(t *testing.T) {
+	stats := newPeerStats()
+	_, ok := stats.get("peer")
+	assert.False(t, ok)
+
+	stats.record(DeliveryResult{Peer: "peer", Status: DeliveryDelivered, Duration: 10 * time.Millisecond})
+	s, ok := stats.get("peer")
+	assert.True(t, ok)
+	assert.Equal(t, 10*time.Millisecond, s.Average)
+
+	stats.record(DeliveryResult{Peer: "peer", Status: DeliveryDelivered, Duration: 60 * time.Millisecond})
+	stats.record(DeliveryResult{Peer: "peer", Status: DeliveryTimedOut, Duration: time.Second})
+	stats.record(DeliveryResult{Peer: "peer", Status: DeliverySuperseded})
+
+	s, _ = stats.get("peer")
+	assert.Equal(t, PeerStats{
+		Delivered: 2,
+		Failed:    1,
+		Last:      60 * time.Millisecond,
+		Average:   20 * time.Millisecond,
+	}, s)
+}
+
+func TestBroadcast_RecordsStats(t *testing.T) {
+	_, handler1, node2, _ := newSessionPair(t, DefaultMaxClipSize)
+	results := handler1.Broadcast(t.Context(), ClipMessage{Content: "timed", Timestamp: time.Now()})
+	if assert.Len(t, results, 1) {
+		assert.True(t, results[0].Delivered())
+		assert.Positive(t, results[0].Duration)
+	}
+
+	s, ok := handler1.PeerStats(node2.ID())
+	assert.True(t, ok)
+	assert.Equal(t, 1, s.Delivered)
+	assert.Equal(t, results[0].Duration, s.Last)
+}
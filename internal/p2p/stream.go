@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -12,42 +13,145 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 )
 
+// Clip formats carried in ClipMessage.Format
+const (
+	FormatText  = "text"
+	FormatImage = "image/png"
+)
+
+// Selections carried in ClipMessage.Selection
+const (
+	SelectionClipboard = "clipboard"
+	SelectionPrimary   = "primary"
+)
+
 // ClipMessage is the packet sent between peers
 type ClipMessage struct {
+	// ID identifies the clip in acks, see NewMessageID
+	ID        string    `json:"id,omitempty"`
+	Selection string    `json:"selection,omitempty"`
+	Format    string    `json:"format,omitempty"`
 	Content   string    `json:"content"`
+	Data      []byte    `json:"data,omitempty"`
 	Timestamp time.Time `json:"timestamp"`
 	PeerName  string    `json:"peer_name"`
+	// TTL marks the clip as ephemeral, receivers remove it from their
+	// clipboard after this long. Zero means it never expires.
+	TTL time.Duration `json:"ttl,omitempty"`
+	// Queued marks a clip held for the peer while it was offline, which
+	// may be older than what the peer copied since
+	Queued bool `json:"queued,omitempty"`
+}
+
+// IsImage reports whether the message carries image data rather than text.
+// Messages without a format come from older peers and are always text.
+func (m ClipMessage) IsImage() bool {
+	return m.Format == FormatImage
+}
+
+// IsPrimary reports whether the message targets the PRIMARY selection.
+// Messages without a selection come from older peers and are always CLIPBOARD.
+func (m ClipMessage) IsPrimary() bool {
+	return m.Selection == SelectionPrimary
 }
 
 // StreamHandler manages protocol streams for messages
 type StreamHandler struct {
-	node      *Node
-	onReceive func(from peer.ID, msg ClipMessage)
-	mu        sync.RWMutex
-	peerNames map[peer.ID]string
+	node       *Node
+	onReceive  func(from peer.ID, msg ClipMessage)
+	onProgress func(Progress)
+	onHello    func(peer.ID, Hello)
+	mu         sync.RWMutex
+	name       string
+	peerNames  map[peer.ID]string
+	peerHellos map[peer.ID]Hello
+	sessions   *sessions
+
+	chunkSize   int
+	maxClipSize int64
+	ackTimeout  time.Duration
+
+	broadcastWorkers int
+	inflight         map[string]*inflightBroadcast
+	stats            *peerStats
 }
 
 func NewStreamHandler(node *Node, onReceive func(from peer.ID, msg ClipMessage)) *StreamHandler {
 	sh := &StreamHandler{
-		node:      node,
-		onReceive: onReceive,
-		peerNames: make(map[peer.ID]string),
+		node:        node,
+		onReceive:   onReceive,
+		peerNames:   make(map[peer.ID]string),
+		peerHellos:  make(map[peer.ID]Hello),
+		sessions:    newSessions(),
+		chunkSize:   DefaultChunkSize,
+		maxClipSize: DefaultMaxClipSize,
+		ackTimeout:  DefaultAckTimeout,
+
+		broadcastWorkers: DefaultBroadcastWorkers,
+		inflight:         make(map[string]*inflightBroadcast),
+		stats:            newPeerStats(),
 	}
 
 	node.host.SetStreamHandler(ProtocolID, sh.handleStream)
+	node.host.SetStreamHandler(ProtocolV2ID, sh.handleStreamV2)
+	node.host.SetStreamHandler(HelloProtocolID, sh.handleHello)
+	node.host.SetStreamHandler(TransferProtocolID, sh.handleTransfer)
+	node.host.Network().Notify(NewConnectionNotifier(nil, sh.sessions.drop))
 
 	return sh
 }
 
+// SetMaxClipSize sets the largest clip accepted from peers, in bytes.
+// Call before peers connect.
+func (sh *StreamHandler) SetMaxClipSize(size int64) {
+	sh.maxClipSize = size
+}
+
+// SetChunkSize sets the payload size above which clips are sent in chunks.
+// Call before peers connect.
+func (sh *StreamHandler) SetChunkSize(size int) {
+	sh.chunkSize = size
+}
+
+// SetAckTimeout sets how long Broadcast waits for each peer to ack a clip,
+// chunked transfers get longer. Call before peers connect.
+func (sh *StreamHandler) SetAckTimeout(timeout time.Duration) {
+	sh.ackTimeout = timeout
+}
+
+// SetBroadcastWorkers sets how many peers Broadcast sends to at once.
+// Call before peers connect.
+func (sh *StreamHandler) SetBroadcastWorkers(n int) {
+	sh.broadcastWorkers = max(n, 1)
+}
+
+// SetProgressHandler registers a callback for chunked transfer progress.
+// Call before peers connect.
+func (sh *StreamHandler) SetProgressHandler(onProgress func(Progress)) {
+	sh.onProgress = onProgress
+}
+
+// maxLineSize bounds a single-message line. Payloads grow when JSON
+// encoded, base64 by a third and escaped text by more.
+func (sh *StreamHandler) maxLineSize() int {
+	return int(min(2*sh.maxClipSize+maxHeaderSize, 1<<31-1))
+}
+
 func (sh *StreamHandler) handleStream(stream network.Stream) {
+	if !sh.authorize(stream) {
+		return
+	}
 	defer stream.Close()
 
 	reader := bufio.NewReader(stream)
 	remotePeer := stream.Conn().RemotePeer()
 
 	for {
-		line, err := reader.ReadBytes('\n')
+		line, err := readLine(reader, sh.maxLineSize())
 		if err != nil {
+			if errors.Is(err, ErrLineTooLong) {
+				stream.Reset()
+			}
 			return
 		}
 
@@ -55,26 +159,68 @@ func (sh *StreamHandler) handleStream(stream network.Stream) {
 		if err := json.Unmarshal(line, &msg); err != nil {
 			continue
 		}
-
-		if msg.PeerName != "" {
-			sh.mu.Lock()
-			sh.peerNames[remotePeer] = msg.PeerName
-			sh.mu.Unlock()
+		if int64(len(clipPayload(msg))) > sh.maxClipSize {
+			continue
 		}
 
-		if sh.onReceive != nil {
-			sh.onReceive(remotePeer, msg)
-		}
+		sh.receive(remotePeer, msg)
+	}
+}
+
+// authorize resets streams from peers connected only to pair
+func (sh *StreamHandler) authorize(stream network.Stream) bool {
+	if sh.node.Authorized(stream.Conn().RemotePeer()) {
+		return true
 	}
+	stream.Reset()
+	return false
 }
 
+// receive records the sender's name and hands a message to the app
+func (sh *StreamHandler) receive(remotePeer peer.ID, msg ClipMessage) {
+	if msg.PeerName != "" {
+		sh.recordName(remotePeer, msg.PeerName)
+	}
+
+	if sh.onReceive != nil {
+		sh.onReceive(remotePeer, msg)
+	}
+}
+
+// SendClip sends a clip to a peer, speaking the newest protocol it
+// supports. Clips go over one stream kept open per peer, payloads larger
+// than the chunk size are streamed in chunks on their own.
 func (sh *StreamHandler) SendClip(ctx context.Context, peerID peer.ID, msg ClipMessage) error {
-	stream, err := sh.node.host.NewStream(ctx, peerID, ProtocolID)
+	_, err := sh.sendClip(ctx, peerID, msg)
+	return err
+}
+
+// sendClip is SendClip, also reporting whether the peer acked the clip.
+// Peers speaking 1.0.0, or 2.0.0 without sessions, don't.
+func (sh *StreamHandler) sendClip(ctx context.Context, peerID peer.ID, msg ClipMessage) (bool, error) {
+	if len(clipPayload(msg)) <= sh.chunkSize {
+		return sh.sendSession(ctx, peerID, msg)
+	}
+
+	stream, err := sh.node.newStream(ctx, peerID, TransferProtocolID, ProtocolV2ID, ProtocolID)
 	if err != nil {
-		return fmt.Errorf("failed to open stream: %w", err)
+		return false, fmt.Errorf("failed to open stream: %w", err)
 	}
 	defer stream.Close()
 
+	switch stream.Protocol() {
+	case TransferProtocolID:
+		return true, sh.sendChunked(ctx, stream, msg)
+	case ProtocolV2ID:
+		return false, sh.sendV2(ctx, stream, msg)
+	default:
+		// Older peers only speak newline-delimited JSON
+		return false, writeMessage(stream, msg)
+	}
+}
+
+// writeMessage writes a clip as one JSON line
+func writeMessage(stream network.Stream, msg ClipMessage) error {
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
@@ -88,17 +234,94 @@ func (sh *StreamHandler) SendClip(ctx context.Context, peerID peer.ID, msg ClipM
 	return nil
 }
 
-func (sh *StreamHandler) Broadcast(ctx context.Context, msg ClipMessage) []error {
-	peers := sh.node.host.Network().Peers()
-	var errs []error
+// Broadcast sends a clip to every authorized peer at once, as many at a
+// time as SetBroadcastWorkers allows, and reports how it fared with each.
+// Clips without an ID are given one. Sends of an earlier clip of the same
+// selection still in flight are cancelled, they would be overwritten.
+func (sh *StreamHandler) Broadcast(ctx context.Context, msg ClipMessage) []DeliveryResult {
+	if msg.ID == "" {
+		msg.ID = NewMessageID()
+	}
+
+	ctx, cancel := sh.supersede(ctx, msg)
+	defer cancel()
+
+	peers := sh.node.AuthorizedPeers()
+	results := make([]DeliveryResult, len(peers))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(sh.broadcastWorkers, len(peers)) {
+		wg.Go(func() {
+			for i := range next {
+				results[i] = sh.Deliver(ctx, peers[i], msg)
+			}
+		})
+	}
+	for i := range peers {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	return results
+}
+
+// supersede cancels the broadcast in flight for the clip's selection and
+// returns the context of this one, cancelled in turn by the next. A clip
+// older than the one in flight is superseded right away. The returned
+// cancel must be called once the broadcast is done.
+func (sh *StreamHandler) supersede(ctx context.Context, msg ClipMessage) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	key := msg.Selection
+	if key == "" {
+		key = SelectionClipboard
+	}
 
-	for _, peerID := range peers {
-		if err := sh.SendClip(ctx, peerID, msg); err != nil {
-			errs = append(errs, err)
+	b := &inflightBroadcast{cancel: cancel, timestamp: msg.Timestamp}
+	sh.mu.Lock()
+	if prev, ok := sh.inflight[key]; ok && prev.timestamp.After(msg.Timestamp) {
+		cancel(ErrSuperseded)
+	} else {
+		if ok {
+			prev.cancel(ErrSuperseded)
 		}
+		sh.inflight[key] = b
+	}
+	sh.mu.Unlock()
+
+	return ctx, func() {
+		sh.mu.Lock()
+		if sh.inflight[key] == b {
+			delete(sh.inflight, key)
+		}
+		sh.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// inflightBroadcast is a broadcast whose sends a newer clip cancels
+type inflightBroadcast struct {
+	cancel    context.CancelCauseFunc
+	timestamp time.Time
+}
+
+// Deliver sends a clip to one peer within the per-peer deadline and reports
+// how it fared
+func (sh *StreamHandler) Deliver(ctx context.Context, peerID peer.ID, msg ClipMessage) DeliveryResult {
+	ctx, cancel := context.WithTimeout(ctx, sh.sendTimeout(msg))
+	defer cancel()
+
+	start := time.Now()
+	acked, err := sh.sendClip(ctx, peerID, msg)
+	result := deliveryResult(ctx, peerID, err)
+	result.Duration = time.Since(start)
+	if result.Status == DeliveryDelivered && !acked {
+		result.Status = DeliverySent
+		result.Reason = "peer doesn't ack clips"
 	}
 
-	return errs
+	sh.stats.record(result)
+	return result
 }
 
 func (sh *StreamHandler) GetPeerName(peerID peer.ID) string {
@@ -116,5 +339,5 @@ func (sh *StreamHandler) GetPeerName(peerID peer.ID) string {
 }
 
 func (sh *StreamHandler) ConnectedPeers() []peer.ID {
-	return sh.node.host.Network().Peers()
+	return sh.node.AuthorizedPeers()
 }
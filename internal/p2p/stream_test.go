@@ -127,10 +127,11 @@ func TestTwoNodes_Broadcast(t *testing.T) {
 		PeerName:  "Broadcaster",
 	}
 
-	errs := handler1.Broadcast(ctx, testMsg)
-	assert.Empty(t, errs)
-
-	time.Sleep(100 * time.Millisecond)
+	results := handler1.Broadcast(ctx, testMsg)
+	assert.Len(t, results, 2)
+	for _, r := range results {
+		assert.True(t, r.Delivered(), r.Reason)
+	}
 
 	mu.Lock()
 	defer mu.Unlock()
@@ -139,4 +140,54 @@ func TestTwoNodes_Broadcast(t *testing.T) {
 	assert.Equal(t, 1, len(node3Msgs))
 	assert.Equal(t, "Broadcast message", node2Msgs[0].Content)
 	assert.Equal(t, "Broadcast message", node3Msgs[0].Content)
+
+	// Both peers got the same ID, given by Broadcast
+	assert.NotEmpty(t, node2Msgs[0].ID)
+	assert.Equal(t, node2Msgs[0].ID, node3Msgs[0].ID)
+}
+
+func TestTwoNodes_SendImage(t *testing.T) {
+	ctx := context.Background()
+
+	node1, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node1.Close()
+
+	node2, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node2.Close()
+
+	var mu sync.Mutex
+	var receivedMsgs []ClipMessage
+
+	handler1 := NewStreamHandler(node1, nil)
+	NewStreamHandler(node2, func(from peer.ID, msg ClipMessage) {
+		mu.Lock()
+		receivedMsgs = append(receivedMsgs, msg)
+		mu.Unlock()
+	})
+
+	err = node1.Host().Connect(ctx, node2.AddrInfo())
+	assert.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Binary data including newlines must survive the line-based framing
+	data := []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n', 0x00}
+	err = handler1.SendClip(ctx, node2.ID(), ClipMessage{
+		Format:    FormatImage,
+		Data:      data,
+		Timestamp: time.Now(),
+		PeerName:  "Node1",
+	})
+	assert.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, 1, len(receivedMsgs))
+	assert.True(t, receivedMsgs[0].IsImage())
+	assert.Equal(t, data, receivedMsgs[0].Data)
 }
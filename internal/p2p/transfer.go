@@ -0,0 +1,352 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// TransferProtocolID carries clips too large for a single message as a
+// declared size followed by checksummed chunks
+const TransferProtocolID = "/clipp2p/transfer/1.0.0"
+
+const (
+	// DefaultChunkSize is the payload size above which clips are chunked
+	DefaultChunkSize = 64 * 1024
+	// DefaultMaxClipSize is the largest clip a receiver accepts
+	DefaultMaxClipSize = 64 << 20
+
+	// maxHeaderSize bounds the control lines of a transfer
+	maxHeaderSize = 64 * 1024
+	// progressInterval throttles progress reports
+	progressInterval = 100 * time.Millisecond
+)
+
+var (
+	// ErrClipTooLarge is returned when a clip exceeds the maximum size
+	ErrClipTooLarge = errors.New("clip exceeds maximum size")
+	// ErrChunkCorrupt is returned when a chunk or the reassembled payload
+	// doesn't match its checksum
+	ErrChunkCorrupt = errors.New("transfer failed integrity check")
+	// ErrLineTooLong is returned when a message line exceeds its bound
+	ErrLineTooLong = errors.New("message line too long")
+	// ErrMalformedTransfer is returned for a transfer header that declares
+	// no payload
+	ErrMalformedTransfer = errors.New("malformed transfer header")
+)
+
+// Progress reports how far a chunked transfer got
+type Progress struct {
+	// ID identifies the transfer on both ends
+	ID       string
+	Peer     peer.ID
+	Incoming bool
+	Bytes    int64
+	Total    int64
+	// Done is set on the last report, Err when the transfer failed
+	Done bool
+	Err  error
+}
+
+// transferHeader opens a transfer. Clip carries everything but the payload.
+type transferHeader struct {
+	ID       string      `json:"id"`
+	Clip     ClipMessage `json:"clip"`
+	Size     int64       `json:"size"`
+	Checksum string      `json:"sha256"`
+}
+
+// transferReply accepts or rejects a transfer, and confirms it at the end
+type transferReply struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// clipPayload returns the bytes a clip carries
+func clipPayload(msg ClipMessage) []byte {
+	if msg.IsImage() {
+		return msg.Data
+	}
+	return []byte(msg.Content)
+}
+
+// withoutPayload returns msg with its payload removed
+func withoutPayload(msg ClipMessage) ClipMessage {
+	msg.Content = ""
+	msg.Data = nil
+	return msg
+}
+
+// withPayload returns msg carrying data
+func withPayload(msg ClipMessage, data []byte) ClipMessage {
+	if msg.IsImage() {
+		msg.Data = data
+	} else {
+		msg.Content = string(data)
+	}
+	return msg
+}
+
+// newTransferID returns a random transfer identifier
+func newTransferID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// readLine reads a newline terminated line of at most limit bytes
+func readLine(r *bufio.Reader, limit int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > limit {
+			return nil, ErrLineTooLong
+		}
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+// writeJSONLine writes v as a single JSON line
+func writeJSONLine(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// readJSONLine reads a JSON line of at most limit bytes into v
+func readJSONLine(r *bufio.Reader, limit int, v any) error {
+	line, err := readLine(r, limit)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(line, v)
+}
+
+// writeChunks frames data as chunks of at most chunkSize bytes, each
+// prefixed with its length and CRC-32
+func writeChunks(w io.Writer, data []byte, chunkSize int, progress func(int64)) error {
+	var prefix [8]byte
+	for sent := 0; sent < len(data); {
+		n := min(chunkSize, len(data)-sent)
+		chunk := data[sent : sent+n]
+
+		binary.BigEndian.PutUint32(prefix[:4], uint32(n))
+		binary.BigEndian.PutUint32(prefix[4:], crc32.ChecksumIEEE(chunk))
+		if _, err := w.Write(prefix[:]); err != nil {
+			return err
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+
+		sent += n
+		if progress != nil {
+			progress(int64(sent))
+		}
+	}
+	return nil
+}
+
+// readChunks reads chunks until size bytes arrived. A chunk claiming more
+// than what is left is rejected before it is read.
+func readChunks(r io.Reader, size int64, progress func(int64)) ([]byte, error) {
+	data := make([]byte, 0, size)
+	var prefix [8]byte
+	for int64(len(data)) < size {
+		if _, err := io.ReadFull(r, prefix[:]); err != nil {
+			return nil, err
+		}
+		n := int64(binary.BigEndian.Uint32(prefix[:4]))
+		if n == 0 || n > size-int64(len(data)) {
+			return nil, fmt.Errorf("%w: chunk of %d bytes", ErrChunkCorrupt, n)
+		}
+
+		chunk := data[len(data) : int64(len(data))+n]
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return nil, err
+		}
+		if crc32.ChecksumIEEE(chunk) != binary.BigEndian.Uint32(prefix[4:]) {
+			return nil, fmt.Errorf("%w: chunk at offset %d", ErrChunkCorrupt, len(data))
+		}
+
+		data = data[:int64(len(data))+n]
+		if progress != nil {
+			progress(int64(len(data)))
+		}
+	}
+	return data, nil
+}
+
+// checksum returns the hex SHA-256 of data
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// progressReporter throttles progress reports of one transfer
+type progressReporter struct {
+	report func(Progress)
+	base   Progress
+	last   time.Time
+}
+
+func newProgressReporter(report func(Progress), base Progress) *progressReporter {
+	return &progressReporter{report: report, base: base}
+}
+
+// update reports the byte count unless a report went out recently
+func (p *progressReporter) update(n int64) {
+	if p.report == nil || time.Since(p.last) < progressInterval {
+		return
+	}
+	p.last = time.Now()
+	progress := p.base
+	progress.Bytes = n
+	p.report(progress)
+}
+
+// done sends the final report
+func (p *progressReporter) done(n int64, err error) {
+	if p.report == nil {
+		return
+	}
+	progress := p.base
+	progress.Bytes = n
+	progress.Done = true
+	progress.Err = err
+	p.report(progress)
+}
+
+// sendChunked transfers msg over an open transfer stream and waits for
+// the receiver to confirm it
+func (sh *StreamHandler) sendChunked(ctx context.Context, stream network.Stream, msg ClipMessage) (err error) {
+	payload := clipPayload(msg)
+	header := transferHeader{
+		ID:       newTransferID(),
+		Clip:     withoutPayload(msg),
+		Size:     int64(len(payload)),
+		Checksum: checksum(payload),
+	}
+
+	// Unblock reads and writes once the caller gives up
+	stop := context.AfterFunc(ctx, func() { stream.Reset() })
+	defer stop()
+
+	progress := newProgressReporter(sh.onProgress, Progress{
+		ID:    header.ID,
+		Peer:  stream.Conn().RemotePeer(),
+		Total: header.Size,
+	})
+	var sent int64
+	defer func() { progress.done(sent, err) }()
+
+	reader := bufio.NewReader(stream)
+	if err := writeJSONLine(stream, header); err != nil {
+		return fmt.Errorf("failed to write transfer header: %w", err)
+	}
+	if err := readReply(reader); err != nil {
+		return err
+	}
+
+	err = writeChunks(stream, payload, sh.chunkSize, func(n int64) {
+		sent = n
+		progress.update(n)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write chunks: %w", err)
+	}
+	stream.CloseWrite()
+
+	return readReply(reader)
+}
+
+// readReply reads a transferReply and turns a rejection into an error
+func readReply(r *bufio.Reader) error {
+	var reply transferReply
+	if err := readJSONLine(r, maxHeaderSize, &reply); err != nil {
+		return fmt.Errorf("failed to read transfer reply: %w", err)
+	}
+	if !reply.OK {
+		return fmt.Errorf("%w: %s", ErrRejected, reply.Error)
+	}
+	return nil
+}
+
+// handleTransfer receives a chunked clip, refusing it before anything is
+// allocated if it is larger than the configured maximum. The transfer is
+// confirmed once the clip was handled, as sessions ack it.
+func (sh *StreamHandler) handleTransfer(stream network.Stream) {
+	if !sh.authorize(stream) {
+		return
+	}
+	defer stream.Close()
+	stream.SetDeadline(time.Now().Add(sh.ackTimeout))
+
+	reader := bufio.NewReader(stream)
+	remotePeer := stream.Conn().RemotePeer()
+
+	var header transferHeader
+	if err := readJSONLine(reader, maxHeaderSize, &header); err != nil {
+		stream.Reset()
+		return
+	}
+	if header.Size <= 0 {
+		writeJSONLine(stream, transferReply{
+			Error: fmt.Sprintf("%s: size %d", ErrMalformedTransfer, header.Size),
+		})
+		return
+	}
+	if header.Size > sh.maxClipSize {
+		writeJSONLine(stream, transferReply{
+			Error: fmt.Sprintf("%s (%d > %d bytes)", ErrClipTooLarge, header.Size, sh.maxClipSize),
+		})
+		return
+	}
+
+	// The whole payload is reserved up front, a peer stalling mid-transfer
+	// holds it no longer than a sender would wait
+	stream.SetDeadline(time.Now().Add(sh.transferTimeout(header.Size)))
+	if err := writeJSONLine(stream, transferReply{OK: true}); err != nil {
+		return
+	}
+
+	progress := newProgressReporter(sh.onProgress, Progress{
+		ID:       header.ID,
+		Peer:     remotePeer,
+		Incoming: true,
+		Total:    header.Size,
+	})
+	var received int64
+	payload, err := readChunks(reader, header.Size, func(n int64) {
+		received = n
+		progress.update(n)
+	})
+	if err == nil && checksum(payload) != header.Checksum {
+		err = ErrChunkCorrupt
+	}
+	progress.done(received, err)
+	if err != nil {
+		writeJSONLine(stream, transferReply{Error: err.Error()})
+		return
+	}
+	sh.receive(remotePeer, withPayload(header.Clip, payload))
+	writeJSONLine(stream, transferReply{OK: true})
+}
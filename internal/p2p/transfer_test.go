@@ -0,0 +1,214 @@
+package p2p
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunks_RoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("clipp2p\n"), 1000)
+
+	var buf bytes.Buffer
+	var sent []int64
+	err := writeChunks(&buf, data, 1024, func(n int64) { sent = append(sent, n) })
+	assert.NoError(t, err)
+	assert.Len(t, sent, 8)
+
+	var received int64
+	got, err := readChunks(&buf, int64(len(data)), func(n int64) { received = n })
+	assert.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.Equal(t, int64(len(data)), received)
+}
+
+func TestChunks_CorruptChunk(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 100)
+
+	var buf bytes.Buffer
+	assert.NoError(t, writeChunks(&buf, data, 64, nil))
+
+	// Flip a payload byte of the first chunk
+	framed := buf.Bytes()
+	framed[8] ^= 0xff
+
+	_, err := readChunks(bytes.NewReader(framed), int64(len(data)), nil)
+	assert.ErrorIs(t, err, ErrChunkCorrupt)
+}
+
+func TestChunks_OversizedChunk(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, writeChunks(&buf, make([]byte, 100), 100, nil))
+
+	// A chunk larger than the declared total is refused before reading it
+	_, err := readChunks(&buf, 10, nil)
+	assert.ErrorIs(t, err, ErrChunkCorrupt)
+}
+
+func TestReadLine_Limit(t *testing.T) {
+	reader := bufio.NewReaderSize(strings.NewReader(strings.Repeat("a", 100)+"\nshort\n"), 16)
+
+	_, err := readLine(reader, 50)
+	assert.ErrorIs(t, err, ErrLineTooLong)
+
+	reader = bufio.NewReaderSize(strings.NewReader(strings.Repeat("a", 40)+"\n"), 16)
+	line, err := readLine(reader, 50)
+	assert.NoError(t, err)
+	assert.Len(t, line, 41)
+}
+
+func TestTwoNodes_ChunkedTransfer(t *testing.T) {
+	ctx := context.Background()
+
+	node1, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node1.Close()
+
+	node2, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node2.Close()
+
+	var mu sync.Mutex
+	var receivedMsgs []ClipMessage
+	var progress []Progress
+
+	handler1 := NewStreamHandler(node1, nil)
+	handler1.SetChunkSize(4096)
+	handler1.SetProgressHandler(func(p Progress) {
+		mu.Lock()
+		progress = append(progress, p)
+		mu.Unlock()
+	})
+
+	handler2 := NewStreamHandler(node2, func(from peer.ID, msg ClipMessage) {
+		mu.Lock()
+		receivedMsgs = append(receivedMsgs, msg)
+		mu.Unlock()
+	})
+
+	err = node1.Host().Connect(ctx, node2.AddrInfo())
+	assert.NoError(t, err)
+
+	content := strings.Repeat("large clipboard payload\n", 10000)
+	err = handler1.SendClip(ctx, node2.ID(), ClipMessage{
+		Content:   content,
+		Timestamp: time.Now(),
+		PeerName:  "Node1",
+	})
+	assert.NoError(t, err)
+
+	// The receiver confirms the transfer once it handled the clip
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, 1, len(receivedMsgs))
+	assert.Equal(t, content, receivedMsgs[0].Content)
+	assert.Equal(t, "Node1", receivedMsgs[0].PeerName)
+	assert.Equal(t, "Node1", handler2.GetPeerName(node1.ID()))
+
+	if assert.NotEmpty(t, progress) {
+		last := progress[len(progress)-1]
+		assert.True(t, last.Done)
+		assert.NoError(t, last.Err)
+		assert.False(t, last.Incoming)
+		assert.Equal(t, int64(len(content)), last.Bytes)
+		assert.Equal(t, int64(len(content)), last.Total)
+	}
+}
+
+func TestTwoNodes_ChunkedTransferTooLarge(t *testing.T) {
+	ctx := context.Background()
+
+	node1, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node1.Close()
+
+	node2, err := NewNode(ctx)
+	assert.NoError(t, err)
+	defer node2.Close()
+
+	var mu sync.Mutex
+	var receivedMsgs []ClipMessage
+
+	handler1 := NewStreamHandler(node1, nil)
+	handler1.SetChunkSize(1024)
+
+	handler2 := NewStreamHandler(node2, func(from peer.ID, msg ClipMessage) {
+		mu.Lock()
+		receivedMsgs = append(receivedMsgs, msg)
+		mu.Unlock()
+	})
+	handler2.SetMaxClipSize(8 * 1024)
+
+	err = node1.Host().Connect(ctx, node2.AddrInfo())
+	assert.NoError(t, err)
+
+	err = handler1.SendClip(ctx, node2.ID(), ClipMessage{
+		Format:    FormatImage,
+		Data:      make([]byte, 16*1024),
+		Timestamp: time.Now(),
+	})
+	assert.ErrorContains(t, err, ErrClipTooLarge.Error())
+
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Empty(t, receivedMsgs)
+}
+
+// openTransfer opens a transfer stream from node1 to node2 and sends header
+func openTransfer(t *testing.T, node1, node2 *Node, header transferHeader) (network.Stream, *bufio.Reader) {
+	t.Helper()
+	assert.NoError(t, node1.Host().Connect(t.Context(), node2.AddrInfo()))
+	stream, err := node1.newStream(t.Context(), node2.ID(), TransferProtocolID)
+	assert.NoError(t, err)
+	t.Cleanup(func() { stream.Reset() })
+	assert.NoError(t, writeJSONLine(stream, header))
+	return stream, bufio.NewReader(stream)
+}
+
+func TestTransfer_MalformedHeader(t *testing.T) {
+	node1, err := NewNode(t.Context())
+	assert.NoError(t, err)
+	defer node1.Close()
+	node2, err := NewNode(t.Context())
+	assert.NoError(t, err)
+	defer node2.Close()
+	NewStreamHandler(node2, nil)
+
+	for _, size := range []int64{0, -1} {
+		_, reader := openTransfer(t, node1, node2, transferHeader{ID: "t", Size: size})
+		err := readReply(reader)
+		assert.ErrorIs(t, err, ErrRejected)
+		assert.ErrorContains(t, err, ErrMalformedTransfer.Error())
+	}
+}
+
+// A sender that stops after the header doesn't hold the receiver's buffer
+// longer than the transfer may take
+func TestTransfer_StalledSender(t *testing.T) {
+	node1, err := NewNode(t.Context())
+	assert.NoError(t, err)
+	defer node1.Close()
+	node2, err := NewNode(t.Context())
+	assert.NoError(t, err)
+	defer node2.Close()
+	handler2 := NewStreamHandler(node2, nil)
+	handler2.SetAckTimeout(200 * time.Millisecond)
+
+	_, reader := openTransfer(t, node1, node2, transferHeader{ID: "t", Size: 1024})
+	assert.NoError(t, readReply(reader))
+
+	start := time.Now()
+	assert.Error(t, readReply(reader))
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
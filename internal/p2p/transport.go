@@ -0,0 +1,113 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Transports nodes can listen on
+const (
+	TransportTCP = "tcp"
+	// TransportQUIC handshakes faster and copes better with flaky Wi-Fi
+	TransportQUIC = "quic"
+	// TransportWebSocket gets through proxies that only pass HTTP
+	TransportWebSocket = "ws"
+)
+
+// TransportRelay names connections through a circuit relay
+const TransportRelay = "relay"
+
+var (
+	// ErrUnknownTransport is returned for transports other than the above
+	ErrUnknownTransport = errors.New("unknown transport")
+	// ErrQUICPrivateNetwork is returned for QUIC listeners on a node with a
+	// private network key, which libp2p can't protect
+	ErrQUICPrivateNetwork = errors.New("QUIC can't be used in a private network")
+)
+
+// ListenAddrs returns IPv4 and IPv6 listen addresses for each transport,
+// TCP when none is given. WebSocket listens on port+1 so it can share a
+// fixed port with TCP, port zero picks free ports.
+func ListenAddrs(port int, transports ...string) ([]string, error) {
+	if len(transports) == 0 {
+		transports = []string{TransportTCP}
+	}
+
+	wsPort := port
+	if port != 0 {
+		wsPort = port + 1
+	}
+
+	var addrs []string
+	for _, t := range transports {
+		switch t {
+		case TransportTCP:
+			addrs = append(addrs,
+				fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", port),
+				fmt.Sprintf("/ip6/::/tcp/%d", port))
+		case TransportQUIC:
+			addrs = append(addrs,
+				fmt.Sprintf("/ip4/0.0.0.0/udp/%d/quic-v1", port),
+				fmt.Sprintf("/ip6/::/udp/%d/quic-v1", port))
+		case TransportWebSocket:
+			addrs = append(addrs,
+				fmt.Sprintf("/ip4/0.0.0.0/tcp/%d/ws", wsPort),
+				fmt.Sprintf("/ip6/::/tcp/%d/ws", wsPort))
+		default:
+			return nil, fmt.Errorf("%w %q", ErrUnknownTransport, t)
+		}
+	}
+	return addrs, nil
+}
+
+// transportOf names the transport of an address
+func transportOf(addr multiaddr.Multiaddr) string {
+	var name string
+	multiaddr.ForEach(addr, func(c multiaddr.Component) bool {
+		switch c.Protocol().Code {
+		case multiaddr.P_CIRCUIT:
+			name = TransportRelay
+			return false
+		case multiaddr.P_QUIC_V1:
+			name = TransportQUIC
+		case multiaddr.P_WS, multiaddr.P_WSS:
+			name = TransportWebSocket
+		case multiaddr.P_TCP:
+			if name == "" {
+				name = TransportTCP
+			}
+		}
+		return true
+	})
+	return name
+}
+
+// Transport names the transport connecting to a peer, a direct one if
+// there is one, empty if the peer isn't connected
+func (n *Node) Transport(p peer.ID) string {
+	var name string
+	for _, conn := range n.host.Network().ConnsToPeer(p) {
+		if conn.IsClosed() {
+			continue
+		}
+		name = transportOf(conn.RemoteMultiaddr())
+		if name != TransportRelay {
+			return name
+		}
+	}
+	return name
+}
+
+// hasQUIC reports whether any address listens on QUIC
+func hasQUIC(addrs []string) bool {
+	for _, s := range addrs {
+		addr, err := multiaddr.NewMultiaddr(s)
+		if err == nil && transportOf(addr) == TransportQUIC {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package p2p
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenAddrs(t *testing.T) {
+	addrs, err := ListenAddrs(4001, TransportTCP, TransportQUIC, TransportWebSocket)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"/ip4/0.0.0.0/tcp/4001", "/ip6/::/tcp/4001",
+		"/ip4/0.0.0.0/udp/4001/quic-v1", "/ip6/::/udp/4001/quic-v1",
+		"/ip4/0.0.0.0/tcp/4002/ws", "/ip6/::/tcp/4002/ws",
+	}, addrs)
+
+	// Without transports, e.g. no -transports flag, only TCP listens
+	addrs, err = ListenAddrs(4001)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/ip4/0.0.0.0/tcp/4001", "/ip6/::/tcp/4001"}, addrs)
+
+	_, err = ListenAddrs(0, "carrier-pigeon")
+	assert.ErrorIs(t, err, ErrUnknownTransport)
+}
+
+func TestTransportOf(t *testing.T) {
+	for addr, want := range map[string]string{
+		"/ip4/10.0.0.1/tcp/4001":                     TransportTCP,
+		"/ip4/10.0.0.1/udp/4001/quic-v1":             TransportQUIC,
+		"/ip4/10.0.0.1/tcp/4002/ws":                  TransportWebSocket,
+		"/dns4/example.com/tcp/443/tls/ws":           TransportWebSocket,
+		"/ip4/10.0.0.1/tcp/4001/p2p-circuit":         TransportRelay,
+		"/ip4/10.0.0.1/udp/4001/quic-v1/p2p-circuit": TransportRelay,
+	} {
+		assert.Equal(t, want, transportOf(multiaddr.StringCast(addr)), addr)
+	}
+}
+
+func TestNewNode_QUICInPrivateNetwork(t *testing.T) {
+	addrs, _ := ListenAddrs(0, TransportQUIC)
+	_, err := NewNode(context.Background(), WithPrivateNetwork(make([]byte, 32)), WithListenAddrs(addrs...))
+	assert.ErrorIs(t, err, ErrQUICPrivateNetwork)
+}
+
+// Two nodes listening on a single transport sync over it
+func TestSync_PerTransport(t *testing.T) {
+	for transport, listen := range map[string]string{
+		TransportTCP:       "/ip4/127.0.0.1/tcp/0",
+		TransportQUIC:      "/ip4/127.0.0.1/udp/0/quic-v1",
+		TransportWebSocket: "/ip4/127.0.0.1/tcp/0/ws",
+	} {
+		t.Run(transport, func(t *testing.T) {
+			ctx := context.Background()
+			node1, err := NewNode(ctx, WithListenAddrs(listen))
+			assert.NoError(t, err)
+			defer node1.Close()
+			node2, err := NewNode(ctx, WithListenAddrs(listen))
+			assert.NoError(t, err)
+			defer node2.Close()
+
+			var mu sync.Mutex
+			var received []ClipMessage
+			handler1 := NewStreamHandler(node1, nil)
+			NewStreamHandler(node2, func(from peer.ID, msg ClipMessage) {
+				mu.Lock()
+				received = append(received, msg)
+				mu.Unlock()
+			})
+
+			assert.NoError(t, node1.Host().Connect(ctx, node2.AddrInfo()))
+			assert.Equal(t, transport, node1.Transport(node2.ID()))
+			assert.Equal(t, transport, node2.Transport(node1.ID()))
+
+			// Large enough to be chunked too
+			large := make([]byte, 3*DefaultChunkSize)
+			for i := range large {
+				large[i] = 'a' + byte(i%26)
+			}
+			assert.NoError(t, handler1.SendClip(ctx, node2.ID(), ClipMessage{Content: "over " + transport, Timestamp: time.Now()}))
+			assert.NoError(t, handler1.SendClip(ctx, node2.ID(), ClipMessage{Content: string(large), Timestamp: time.Now()}))
+
+			assert.Eventually(t, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(received) == 2 &&
+					received[0].Content == "over "+transport &&
+					received[1].Content == string(large)
+			}, 5*time.Second, 20*time.Millisecond)
+		})
+	}
+}
@@ -0,0 +1,416 @@
+package p2p
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Capabilities announced in Hello
+const (
+	CapabilityImage   = "image"
+	CapabilityPrimary = "primary"
+	CapabilityTTL     = "ttl"
+	CapabilityChunked = "chunked"
+	// CapabilitySession keeps a 2.0.0 stream open across clips, every clip
+	// is answered with an ack or an error frame
+	CapabilitySession = "session"
+)
+
+// Capabilities lists what this version of clipp2p supports
+var Capabilities = []string{CapabilityImage, CapabilityPrimary, CapabilityTTL, CapabilityChunked, CapabilitySession}
+
+// Version is the clipp2p version announced to peers
+var Version = "1.0.0"
+
+var (
+	// ErrMalformedFrame is returned when a frame can't be decoded
+	ErrMalformedFrame = errors.New("malformed frame")
+	// ErrFrameTooLarge is returned when a frame exceeds its bound
+	ErrFrameTooLarge = errors.New("frame too large")
+)
+
+// Hello introduces a peer at the start of a stream
+type Hello struct {
+	Name         string
+	Version      string
+	Capabilities []string
+	// OS is the peer's operating system as in runtime.GOOS
+	OS string
+}
+
+// Supports reports whether the peer announced a capability
+func (h Hello) Supports(capability string) bool {
+	return slices.Contains(h.Capabilities, capability)
+}
+
+// ErrorCode classifies a ProtocolError
+type ErrorCode uint32
+
+const (
+	ErrorCodeUnknown ErrorCode = iota
+	ErrorCodeMalformed
+	ErrorCodeTooLarge
+	ErrorCodeUnsupported
+)
+
+func (c ErrorCode) String() string {
+	switch c {
+	case ErrorCodeMalformed:
+		return "malformed"
+	case ErrorCodeTooLarge:
+		return "too large"
+	case ErrorCodeUnsupported:
+		return "unsupported"
+	default:
+		return "unknown"
+	}
+}
+
+// ProtocolError is an error reported by a peer in an error frame
+type ProtocolError struct {
+	Code    ErrorCode
+	Message string
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("peer error (%s): %s", e.Code, e.Message)
+}
+
+// frame is one message of the 2.0.0 protocol, exactly one field is set
+type frame struct {
+	hello *Hello
+	clip  *ClipMessage
+	err   *ProtocolError
+	ack   *ack
+}
+
+// ack confirms a clip on a session stream
+type ack struct {
+	// id is the ID of the clip acked
+	id string
+}
+
+// Field numbers, see clipp2p.proto
+const (
+	frameHello protowire.Number = 1
+	frameClip  protowire.Number = 2
+	frameError protowire.Number = 3
+	frameAck   protowire.Number = 4
+
+	helloName         protowire.Number = 1
+	helloVersion      protowire.Number = 2
+	helloCapabilities protowire.Number = 3
+	helloOS           protowire.Number = 4
+
+	clipSelection protowire.Number = 1
+	clipFormat    protowire.Number = 2
+	clipContent   protowire.Number = 3
+	clipData      protowire.Number = 4
+	clipTimestamp protowire.Number = 5
+	clipPeerName  protowire.Number = 6
+	clipTTL       protowire.Number = 7
+	clipID        protowire.Number = 8
+	clipQueued    protowire.Number = 9
+
+	errorCode    protowire.Number = 1
+	errorMessage protowire.Number = 2
+
+	ackID protowire.Number = 1
+)
+
+// writeFrame writes f prefixed with its varint length
+func writeFrame(w io.Writer, f frame) error {
+	body := marshalFrame(f)
+	data := protowire.AppendVarint(make([]byte, 0, len(body)+binary.MaxVarintLen64), uint64(len(body)))
+	_, err := w.Write(append(data, body...))
+	return err
+}
+
+// readFrame reads a frame of at most limit bytes. Oversized frames are
+// refused before their body is read.
+func readFrame(r *bufio.Reader, limit int) (frame, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		if err != io.EOF && err != io.ErrUnexpectedEOF {
+			err = fmt.Errorf("%w: %v", ErrMalformedFrame, err)
+		}
+		return frame{}, err
+	}
+	if size > uint64(limit) {
+		return frame{}, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, size)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return frame{}, err
+	}
+	return unmarshalFrame(body)
+}
+
+func marshalFrame(f frame) []byte {
+	var b []byte
+	switch {
+	case f.hello != nil:
+		b = appendEmbedded(b, frameHello, marshalHello(*f.hello))
+	case f.clip != nil:
+		b = appendEmbedded(b, frameClip, marshalClip(*f.clip))
+	case f.err != nil:
+		b = appendEmbedded(b, frameError, marshalError(*f.err))
+	case f.ack != nil:
+		b = appendEmbedded(b, frameAck, appendString(nil, ackID, f.ack.id))
+	}
+	return b
+}
+
+func marshalHello(h Hello) []byte {
+	var b []byte
+	b = appendString(b, helloName, h.Name)
+	b = appendString(b, helloVersion, h.Version)
+	for _, c := range h.Capabilities {
+		b = protowire.AppendTag(b, helloCapabilities, protowire.BytesType)
+		b = protowire.AppendString(b, c)
+	}
+	b = appendString(b, helloOS, h.OS)
+	return b
+}
+
+func marshalClip(m ClipMessage) []byte {
+	var b []byte
+	b = appendString(b, clipSelection, m.Selection)
+	b = appendString(b, clipFormat, m.Format)
+	b = appendString(b, clipContent, m.Content)
+	b = appendBytes(b, clipData, m.Data)
+	if !m.Timestamp.IsZero() {
+		b = appendInt(b, clipTimestamp, m.Timestamp.UnixNano())
+	}
+	b = appendString(b, clipPeerName, m.PeerName)
+	b = appendInt(b, clipTTL, int64(m.TTL))
+	b = appendString(b, clipID, m.ID)
+	if m.Queued {
+		b = appendInt(b, clipQueued, 1)
+	}
+	return b
+}
+
+func marshalError(e ProtocolError) []byte {
+	var b []byte
+	b = appendInt(b, errorCode, int64(e.Code))
+	b = appendString(b, errorMessage, e.Message)
+	return b
+}
+
+// appendString appends a string field, omitting it when empty
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendBytes appends a bytes field, omitting it when empty
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	return appendEmbedded(b, num, v)
+}
+
+// appendEmbedded appends an embedded message, even an empty one
+func appendEmbedded(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// appendInt appends a varint field, omitting it when zero
+func appendInt(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func unmarshalFrame(b []byte) (frame, error) {
+	var f frame
+	err := decodeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if typ != protowire.BytesType {
+			return 0, nil
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return n, nil
+		}
+
+		var err error
+		switch num {
+		case frameHello:
+			var h Hello
+			h, err = unmarshalHello(v)
+			f = frame{hello: &h}
+		case frameClip:
+			var m ClipMessage
+			m, err = unmarshalClip(v)
+			f = frame{clip: &m}
+		case frameError:
+			var e ProtocolError
+			e, err = unmarshalError(v)
+			f = frame{err: &e}
+		case frameAck:
+			var a ack
+			a, err = unmarshalAck(v)
+			f = frame{ack: &a}
+		}
+		return n, err
+	})
+	if err != nil {
+		return frame{}, err
+	}
+	return f, nil
+}
+
+func unmarshalHello(b []byte) (Hello, error) {
+	var h Hello
+	err := decodeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case helloName:
+			return consumeString(typ, b, &h.Name), nil
+		case helloVersion:
+			return consumeString(typ, b, &h.Version), nil
+		case helloCapabilities:
+			var c string
+			n := consumeString(typ, b, &c)
+			if n > 0 {
+				h.Capabilities = append(h.Capabilities, c)
+			}
+			return n, nil
+		case helloOS:
+			return consumeString(typ, b, &h.OS), nil
+		}
+		return 0, nil
+	})
+	return h, err
+}
+
+func unmarshalClip(b []byte) (ClipMessage, error) {
+	var m ClipMessage
+	err := decodeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case clipSelection:
+			return consumeString(typ, b, &m.Selection), nil
+		case clipFormat:
+			return consumeString(typ, b, &m.Format), nil
+		case clipContent:
+			return consumeString(typ, b, &m.Content), nil
+		case clipData:
+			if typ != protowire.BytesType {
+				return 0, nil
+			}
+			v, n := protowire.ConsumeBytes(b)
+			m.Data = v
+			return n, nil
+		case clipTimestamp:
+			var v int64
+			n := consumeInt(typ, b, &v)
+			m.Timestamp = time.Unix(0, v)
+			return n, nil
+		case clipPeerName:
+			return consumeString(typ, b, &m.PeerName), nil
+		case clipTTL:
+			var v int64
+			n := consumeInt(typ, b, &v)
+			m.TTL = time.Duration(v)
+			return n, nil
+		case clipID:
+			return consumeString(typ, b, &m.ID), nil
+		case clipQueued:
+			var v int64
+			n := consumeInt(typ, b, &v)
+			m.Queued = v != 0
+			return n, nil
+		}
+		return 0, nil
+	})
+	return m, err
+}
+
+func unmarshalError(b []byte) (ProtocolError, error) {
+	var e ProtocolError
+	err := decodeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case errorCode:
+			var v int64
+			n := consumeInt(typ, b, &v)
+			e.Code = ErrorCode(v)
+			return n, nil
+		case errorMessage:
+			return consumeString(typ, b, &e.Message), nil
+		}
+		return 0, nil
+	})
+	return e, err
+}
+
+func unmarshalAck(b []byte) (ack, error) {
+	var a ack
+	err := decodeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if num == ackID {
+			return consumeString(typ, b, &a.id), nil
+		}
+		return 0, nil
+	})
+	return a, err
+}
+
+// decodeFields walks the fields of a message. field returns how many bytes
+// of the value it consumed, zero skips the field so that fields added by
+// newer peers are ignored.
+func decodeFields(b []byte, field func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("%w: %v", ErrMalformedFrame, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		n, err := field(num, typ, b)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return fmt.Errorf("%w: %v", ErrMalformedFrame, protowire.ParseError(n))
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// consumeString decodes a string field, zero if the wire type doesn't match
+func consumeString(typ protowire.Type, b []byte, dst *string) int {
+	if typ != protowire.BytesType {
+		return 0
+	}
+	v, n := protowire.ConsumeString(b)
+	*dst = v
+	return n
+}
+
+// consumeInt decodes a varint field, zero if the wire type doesn't match
+func consumeInt(typ protowire.Type, b []byte, dst *int64) int {
+	if typ != protowire.VarintType {
+		return 0
+	}
+	v, n := protowire.ConsumeVarint(b)
+	*dst = int64(v)
+	return n
+}
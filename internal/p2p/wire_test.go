@@ -0,0 +1,117 @@
+package p2p
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestFrame_ClipRoundTrip(t *testing.T) {
+	msg := ClipMessage{
+		Selection: SelectionPrimary,
+		Format:    FormatImage,
+		Data:      []byte{0x89, 'P', 'N', 'G', 0x00, '\n'},
+		Timestamp: time.Unix(1700000000, 123),
+		PeerName:  "laptop",
+		TTL:       30 * time.Second,
+		ID:        "0123abcd",
+		Queued:    true,
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, writeFrame(&buf, frame{clip: &msg}))
+
+	f, err := readFrame(bufio.NewReader(&buf), 1024)
+	assert.NoError(t, err)
+	if assert.NotNil(t, f.clip) {
+		assert.Equal(t, msg.Selection, f.clip.Selection)
+		assert.Equal(t, msg.Format, f.clip.Format)
+		assert.Equal(t, msg.Data, f.clip.Data)
+		assert.True(t, msg.Timestamp.Equal(f.clip.Timestamp))
+		assert.Equal(t, msg.PeerName, f.clip.PeerName)
+		assert.Equal(t, msg.TTL, f.clip.TTL)
+		assert.Equal(t, msg.ID, f.clip.ID)
+		assert.True(t, f.clip.Queued)
+	}
+}
+
+func TestFrame_HelloAndError(t *testing.T) {
+	hello := Hello{Name: "desktop", Version: "1.2.3", Capabilities: []string{CapabilityImage, CapabilityTTL}}
+	protoErr := ProtocolError{Code: ErrorCodeTooLarge, Message: "no thanks"}
+
+	var buf bytes.Buffer
+	assert.NoError(t, writeFrame(&buf, frame{hello: &hello}))
+	assert.NoError(t, writeFrame(&buf, frame{err: &protoErr}))
+	reader := bufio.NewReader(&buf)
+
+	f, err := readFrame(reader, 1024)
+	assert.NoError(t, err)
+	if assert.NotNil(t, f.hello) {
+		assert.Equal(t, hello, *f.hello)
+		assert.True(t, f.hello.Supports(CapabilityTTL))
+		assert.False(t, f.hello.Supports(CapabilityChunked))
+	}
+
+	f, err = readFrame(reader, 1024)
+	assert.NoError(t, err)
+	if assert.NotNil(t, f.err) {
+		assert.Equal(t, protoErr, *f.err)
+		assert.Contains(t, f.err.Error(), "too large")
+	}
+}
+
+func TestFrame_Ack(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, writeFrame(&buf, frame{ack: &ack{id: "0123abcd"}}))
+
+	f, err := readFrame(bufio.NewReader(&buf), 1024)
+	assert.NoError(t, err)
+	if assert.NotNil(t, f.ack) {
+		assert.Equal(t, "0123abcd", f.ack.id)
+	}
+	assert.Nil(t, f.clip)
+}
+
+func TestFrame_UnknownFieldsSkipped(t *testing.T) {
+	// A newer peer may add fields, they must not break decoding
+	clip := marshalClip(ClipMessage{Content: "hello"})
+	clip = protowire.AppendTag(clip, 99, protowire.VarintType)
+	clip = protowire.AppendVarint(clip, 42)
+	clip = protowire.AppendTag(clip, 100, protowire.BytesType)
+	clip = protowire.AppendString(clip, "future")
+
+	f, err := unmarshalFrame(appendEmbedded(nil, frameClip, clip))
+	assert.NoError(t, err)
+	if assert.NotNil(t, f.clip) {
+		assert.Equal(t, "hello", f.clip.Content)
+	}
+}
+
+func TestFrame_UnknownFrameType(t *testing.T) {
+	f, err := unmarshalFrame(appendEmbedded(nil, 42, []byte("future")))
+	assert.NoError(t, err)
+	assert.Nil(t, f.hello)
+	assert.Nil(t, f.clip)
+	assert.Nil(t, f.err)
+}
+
+func TestFrame_Malformed(t *testing.T) {
+	body := appendEmbedded(nil, frameClip, marshalClip(ClipMessage{Content: "hello"}))
+
+	_, err := unmarshalFrame(body[:len(body)-2])
+	assert.ErrorIs(t, err, ErrMalformedFrame)
+}
+
+func TestFrame_TooLarge(t *testing.T) {
+	msg := ClipMessage{Content: string(make([]byte, 2048))}
+
+	var buf bytes.Buffer
+	assert.NoError(t, writeFrame(&buf, frame{clip: &msg}))
+
+	_, err := readFrame(bufio.NewReader(&buf), 1024)
+	assert.ErrorIs(t, err, ErrFrameTooLarge)
+}
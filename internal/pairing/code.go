@@ -0,0 +1,39 @@
+package pairing
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// CodeDigits is the length of a pairing code
+const CodeDigits = 6
+
+// NewCode returns a random pairing code of CodeDigits digits
+func NewCode() (string, error) {
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(CodeDigits), nil)
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", CodeDigits, n), nil
+}
+
+// FormatCode splits a code in two halves for reading it out, "123 456"
+func FormatCode(code string) string {
+	if len(code) != CodeDigits {
+		return code
+	}
+	return code[:CodeDigits/2] + " " + code[CodeDigits/2:]
+}
+
+// NormalizeCode drops the separators people type between digits
+func NormalizeCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, code)
+}
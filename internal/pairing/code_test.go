@@ -0,0 +1,23 @@
+package pairing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCode(t *testing.T) {
+	for range 20 {
+		code, err := NewCode()
+		assert.NoError(t, err)
+		assert.Regexp(t, `^[0-9]{6}$`, code)
+	}
+}
+
+func TestFormatAndNormalizeCode(t *testing.T) {
+	assert.Equal(t, "012 345", FormatCode("012345"))
+	assert.Equal(t, "12345", FormatCode("12345"))
+
+	assert.Equal(t, "012345", NormalizeCode("012 345"))
+	assert.Equal(t, "012345", NormalizeCode("012-345"))
+}
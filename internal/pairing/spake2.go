@@ -0,0 +1,271 @@
+// Package pairing lets two devices prove they know the same short code
+// without revealing it, and remembers the devices paired that way.
+package pairing
+
+import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+
+	"filippo.io/bigmod"
+	"filippo.io/nistec"
+)
+
+// The code is verified with SPAKE2 (RFC 9382) over P-256, as the
+// SPAKE2-P256-SHA256-HKDF-HMAC suite. A passive observer learns nothing
+// about the code and an active attacker gets one guess per exchange. Points
+// and scalars go through nistec and bigmod, which run in constant time.
+
+// Role is the side of the exchange, the two sides blind their share with
+// different points
+type Role int
+
+const (
+	// Initiator is the device the code is typed on
+	Initiator Role = iota
+	// Responder is the device showing the code
+	Responder
+)
+
+var (
+	// ErrInvalidShare is returned for a peer share that is not a valid point
+	ErrInvalidShare = errors.New("invalid pairing share")
+	// ErrConfirmation is returned when the peer used a different code
+	ErrConfirmation = errors.New("pairing codes don't match")
+)
+
+// shareLength is the size of an uncompressed P-256 point, the only
+// encoding accepted for shares
+const shareLength = 65
+
+var (
+	// M and N are the P-256 points of RFC 9382, section 6, no one knows
+	// their discrete log
+	pointM = mustPoint("02886e2f97ace46e55ba9dd7242579f2993b64e16ef3dcab95afd497333d8fa12f")
+	pointN = mustPoint("03d8bbd6c639c62937b04d997f38c3770719c629d7014d49a24b4f98baa1292b49")
+
+	// order is the order of the P-256 group
+	order = mustModulus("ffffffff00000000ffffffffffffffffbce6faada7179e84f3b9cac2fc632551")
+	// shift is 2²⁵⁶ mod order, to reduce 512-bit values
+	shift = mustScalar("00000000ffffffff00000000000000004319055258e8617b0c46353d039cdaaf")
+)
+
+// SPAKE2 is one side of an exchange. Both sides send Share to the other
+// and pass the share they got to Finish.
+type SPAKE2 struct {
+	role     Role
+	idA, idB []byte
+	// w is the code as a scalar and x the secret scalar of this side,
+	// both big-endian and reduced
+	w, x  []byte
+	share []byte
+}
+
+// NewSPAKE2 starts an exchange for code between the initiator idA and the
+// responder idB
+func NewSPAKE2(role Role, code string, idA, idB []byte) (*SPAKE2, error) {
+	var random [64]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		return nil, err
+	}
+	return newSPAKE2(role, passwordScalar(code, idA, idB), reduce(random[:]), idA, idB)
+}
+
+// newSPAKE2 starts an exchange with the given scalars, which the tests
+// take from the RFC vectors
+func newSPAKE2(role Role, w, x, idA, idB []byte) (*SPAKE2, error) {
+	blind := pointM
+	if role == Responder {
+		blind = pointN
+	}
+	// share = x*G + w*blind
+	share, err := nistec.NewP256Point().ScalarBaseMult(x)
+	if err != nil {
+		return nil, err
+	}
+	masked, err := nistec.NewP256Point().ScalarMult(blind, w)
+	if err != nil {
+		return nil, err
+	}
+	share.Add(share, masked)
+
+	return &SPAKE2{
+		role:  role,
+		idA:   idA,
+		idB:   idB,
+		w:     w,
+		x:     x,
+		share: share.Bytes(),
+	}, nil
+}
+
+// Share returns the message to send to the other side
+func (s *SPAKE2) Share() []byte {
+	return s.share
+}
+
+// Finish derives the shared keys from the other side's share
+func (s *SPAKE2) Finish(peerShare []byte) (*Session, error) {
+	// The uncompressed encoding can't hold the identity, so a share that
+	// parses is a point the peer can't have forced
+	if len(peerShare) != shareLength {
+		return nil, ErrInvalidShare
+	}
+	peerPoint, err := nistec.NewP256Point().SetBytes(peerShare)
+	if err != nil {
+		return nil, ErrInvalidShare
+	}
+
+	blind := pointN
+	if s.role == Responder {
+		blind = pointM
+	}
+	// K = x*(peerShare - w*blind), subtracting as adding (order-w)*blind
+	w, err := bigmod.NewNat().SetBytes(s.w, order)
+	if err != nil {
+		return nil, err
+	}
+	negW := bigmod.NewNat().ExpandFor(order).Sub(w, order)
+	unmasked, err := nistec.NewP256Point().ScalarMult(blind, negW.Bytes(order))
+	if err != nil {
+		return nil, err
+	}
+	unmasked.Add(unmasked, peerPoint)
+	k, err := nistec.NewP256Point().ScalarMult(unmasked, s.x)
+	if err != nil {
+		return nil, err
+	}
+	kBytes := k.Bytes()
+	if len(kBytes) != shareLength {
+		return nil, ErrInvalidShare
+	}
+
+	shareA, shareB := s.share, peerShare
+	if s.role == Responder {
+		shareA, shareB = peerShare, s.share
+	}
+
+	var transcript []byte
+	transcript = appendWithLength(transcript, s.idA)
+	transcript = appendWithLength(transcript, s.idB)
+	transcript = appendWithLength(transcript, shareA)
+	transcript = appendWithLength(transcript, shareB)
+	transcript = appendWithLength(transcript, kBytes)
+	transcript = appendWithLength(transcript, s.w)
+
+	hash := sha256.Sum256(transcript)
+	confirmKeys, err := hkdf.Key(sha256.New, hash[16:], nil, "ConfirmationKeys", 32)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		role:     s.role,
+		Key:      hash[:16],
+		confirmA: mac(confirmKeys[:16], transcript),
+		confirmB: mac(confirmKeys[16:], transcript),
+	}, nil
+}
+
+// Session holds the outcome of an exchange. Both sides hold the same Key
+// only if they used the same code, which the confirmations prove.
+type Session struct {
+	role     Role
+	Key      []byte
+	confirmA []byte
+	confirmB []byte
+}
+
+// Confirmation returns the proof to send to the other side
+func (s *Session) Confirmation() []byte {
+	if s.role == Initiator {
+		return s.confirmA
+	}
+	return s.confirmB
+}
+
+// Verify checks the other side's proof
+func (s *Session) Verify(peerConfirmation []byte) error {
+	want := s.confirmB
+	if s.role == Responder {
+		want = s.confirmA
+	}
+	if !hmac.Equal(want, peerConfirmation) {
+		return ErrConfirmation
+	}
+	return nil
+}
+
+// passwordScalar maps the code, bound to both identities, to a scalar
+func passwordScalar(code string, idA, idB []byte) []byte {
+	var b []byte
+	b = appendWithLength(b, []byte("clipp2p pairing"))
+	b = appendWithLength(b, idA)
+	b = appendWithLength(b, idB)
+	b = appendWithLength(b, []byte(code))
+	sum := sha512.Sum512(b)
+	return reduce(sum[:])
+}
+
+// reduce maps 64 bytes to a scalar, 512 bits reduced mod order keep the
+// bias negligible
+func reduce(b []byte) []byte {
+	// b = hi*2²⁵⁶ + lo, each half is below 2*order so it loads reduced
+	hi, err := bigmod.NewNat().SetOverflowingBytes(b[:32], order)
+	if err != nil {
+		panic(err)
+	}
+	lo, err := bigmod.NewNat().SetOverflowingBytes(b[32:], order)
+	if err != nil {
+		panic(err)
+	}
+	return hi.Mul(shift, order).Add(lo, order).Bytes(order)
+}
+
+func appendWithLength(b, data []byte) []byte {
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func mac(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func mustPoint(s string) *nistec.P256Point {
+	p, err := nistec.NewP256Point().SetBytes(mustHex(s))
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func mustModulus(s string) *bigmod.Modulus {
+	m, err := bigmod.NewModulus(mustHex(s))
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func mustScalar(s string) *bigmod.Nat {
+	n, err := bigmod.NewNat().SetBytes(mustHex(s), order)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,123 @@
+package pairing
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func exchange(t *testing.T, codeA, codeB string) (*Session, *Session) {
+	t.Helper()
+	idA, idB := []byte("laptop"), []byte("desktop")
+
+	a, err := NewSPAKE2(Initiator, codeA, idA, idB)
+	assert.NoError(t, err)
+	b, err := NewSPAKE2(Responder, codeB, idA, idB)
+	assert.NoError(t, err)
+
+	sessionA, err := a.Finish(b.Share())
+	assert.NoError(t, err)
+	sessionB, err := b.Finish(a.Share())
+	assert.NoError(t, err)
+	return sessionA, sessionB
+}
+
+func TestSPAKE2_SameCode(t *testing.T) {
+	a, b := exchange(t, "123456", "123456")
+
+	assert.Equal(t, a.Key, b.Key)
+	assert.NoError(t, a.Verify(b.Confirmation()))
+	assert.NoError(t, b.Verify(a.Confirmation()))
+	assert.NotEqual(t, a.Confirmation(), b.Confirmation())
+}
+
+func TestSPAKE2_DifferentCode(t *testing.T) {
+	a, b := exchange(t, "123456", "123457")
+
+	assert.NotEqual(t, a.Key, b.Key)
+	assert.ErrorIs(t, a.Verify(b.Confirmation()), ErrConfirmation)
+	assert.ErrorIs(t, b.Verify(a.Confirmation()), ErrConfirmation)
+}
+
+func TestSPAKE2_BoundToIdentities(t *testing.T) {
+	a, err := NewSPAKE2(Initiator, "123456", []byte("laptop"), []byte("desktop"))
+	assert.NoError(t, err)
+	// The responder thinks it talks to someone else
+	b, err := NewSPAKE2(Responder, "123456", []byte("attacker"), []byte("desktop"))
+	assert.NoError(t, err)
+
+	sessionA, err := a.Finish(b.Share())
+	assert.NoError(t, err)
+	sessionB, err := b.Finish(a.Share())
+	assert.NoError(t, err)
+	assert.Error(t, sessionB.Verify(sessionA.Confirmation()))
+}
+
+func TestSPAKE2_SharesAreRandom(t *testing.T) {
+	a1, err := NewSPAKE2(Initiator, "123456", nil, nil)
+	assert.NoError(t, err)
+	a2, err := NewSPAKE2(Initiator, "123456", nil, nil)
+	assert.NoError(t, err)
+	assert.NotEqual(t, a1.Share(), a2.Share())
+}
+
+func TestSPAKE2_InvalidShare(t *testing.T) {
+	a, err := NewSPAKE2(Initiator, "123456", nil, nil)
+	assert.NoError(t, err)
+
+	_, err = a.Finish([]byte("not a point"))
+	assert.ErrorIs(t, err, ErrInvalidShare)
+}
+
+// The first SPAKE2-P256-SHA256-HKDF-HMAC vector of RFC 9382, appendix B
+func TestSPAKE2_RFCVector(t *testing.T) {
+	w := mustHex("2ee57912099d31560b3a44b1184b9b4866e904c49d12ac5042c97dca461b1a5f")
+	x := mustHex("43dd0fd7215bdcb482879fca3220c6a968e66d70b1356cac18bb26c84a78d729")
+	y := mustHex("dcb60106f276b02606d8ef0a328c02e4b629f84f89786af5befb0bc75b6e66be")
+	idA, idB := []byte("server"), []byte("client")
+
+	a, err := newSPAKE2(Initiator, w, x, idA, idB)
+	assert.NoError(t, err)
+	b, err := newSPAKE2(Responder, w, y, idA, idB)
+	assert.NoError(t, err)
+	assert.Equal(t, "04a56fa807caaa53a4d28dbb9853b9815c61a411118a6fe516a8798434751470f9010153ac33d0d5f2047ffdb1a3e42c9b4e6be662766e1eeb4116988ede5f912c", hex.EncodeToString(a.Share()))
+	assert.Equal(t, "0406557e482bd03097ad0cbaa5df82115460d951e3451962f1eaf4367a420676d09857ccbc522686c83d1852abfa8ed6e4a1155cf8f1543ceca528afb591a1e0b7", hex.EncodeToString(b.Share()))
+
+	sessionA, err := a.Finish(b.Share())
+	assert.NoError(t, err)
+	sessionB, err := b.Finish(a.Share())
+	assert.NoError(t, err)
+	for _, s := range []*Session{sessionA, sessionB} {
+		assert.Equal(t, "0e0672dc86f8e45565d338b0540abe69", hex.EncodeToString(s.Key))
+		assert.Equal(t, "58ad4aa88e0b60d5061eb6b5dd93e80d9c4f00d127c65b3b35b1b5281fee38f0", hex.EncodeToString(s.confirmA))
+		assert.Equal(t, "d3e2e547f1ae04f2dbdbf0fc4b79f8ecff2dff314b5d32fe9fcef2fb26dc459b", hex.EncodeToString(s.confirmB))
+	}
+}
+
+func TestSPAKE2_OnlyUncompressedShares(t *testing.T) {
+	a, err := NewSPAKE2(Initiator, "123456", nil, nil)
+	assert.NoError(t, err)
+
+	// The identity, and a valid point in compressed form
+	for _, share := range [][]byte{{0}, pointN.BytesCompressed()} {
+		_, err = a.Finish(share)
+		assert.ErrorIs(t, err, ErrInvalidShare)
+	}
+}
+
+func TestReduce(t *testing.T) {
+	n := new(big.Int).SetBytes(mustHex("ffffffff00000000ffffffffffffffffbce6faada7179e84f3b9cac2fc632551"))
+	for _, b := range [][]byte{
+		make([]byte, 64),
+		bytes.Repeat([]byte{0xff}, 64),
+		append(n.FillBytes(make([]byte, 32)), n.FillBytes(make([]byte, 32))...),
+		sha512.New().Sum(nil),
+	} {
+		want := new(big.Int).Mod(new(big.Int).SetBytes(b), n)
+		assert.Equal(t, want.FillBytes(make([]byte, 32)), reduce(b))
+	}
+}
@@ -0,0 +1,135 @@
+package pairing
+
+import (
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/owenHochwald/clipp2p/internal/datadir"
+)
+
+// TrustFile is the name of the trusted peers file in the data directory
+const TrustFile = "trusted_peers.json"
+
+// TrustedPeer is a device paired with this one
+type TrustedPeer struct {
+	ID       peer.ID   `json:"id"`
+	Name     string    `json:"name,omitempty"`
+	PairedAt time.Time `json:"paired_at"`
+}
+
+// TrustStore is the persisted allowlist of paired peers
+type TrustStore struct {
+	path  string
+	mu    sync.RWMutex
+	peers map[peer.ID]TrustedPeer
+}
+
+// LoadTrustStore reads the trusted peers kept in dir. A missing file is an
+// empty allowlist.
+func LoadTrustStore(dir string) (*TrustStore, error) {
+	s := &TrustStore{
+		path:  filepath.Join(dir, TrustFile),
+		peers: make(map[peer.ID]TrustedPeer),
+	}
+	if err := s.loadLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// loadLocked replaces the peers with those in the file, which another
+// process such as "clipp2p trusted remove" may have changed
+func (s *TrustStore) loadLocked() error {
+	var peers []TrustedPeer
+	if _, err := datadir.ReadJSON(s.path, &peers); err != nil {
+		return err
+	}
+	clear(s.peers)
+	for _, p := range peers {
+		s.peers[p.ID] = p
+	}
+	return nil
+}
+
+// Reload rereads the allowlist to pick up changes made by another process
+// and returns the peers that are no longer trusted
+func (s *TrustStore) Reload() ([]peer.ID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := make([]peer.ID, 0, len(s.peers))
+	for id := range s.peers {
+		before = append(before, id)
+	}
+	if err := s.loadLocked(); err != nil {
+		return nil, err
+	}
+
+	var removed []peer.ID
+	for _, id := range before {
+		if _, ok := s.peers[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	return removed, nil
+}
+
+// Trusted reports whether a peer is paired
+func (s *TrustStore) Trusted(id peer.ID) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.peers[id]
+	return ok
+}
+
+// Add trusts a peer and saves the allowlist. The file is reread first so
+// peers removed by another process stay removed.
+func (s *TrustStore) Add(p TrustedPeer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return err
+	}
+	s.peers[p.ID] = p
+	return s.saveLocked()
+}
+
+// Remove stops trusting a peer, reporting whether it was trusted
+func (s *TrustStore) Remove(id peer.ID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return false, err
+	}
+	if _, ok := s.peers[id]; !ok {
+		return false, nil
+	}
+	delete(s.peers, id)
+	return true, s.saveLocked()
+}
+
+// List returns the trusted peers, oldest pairing first
+func (s *TrustStore) List() []TrustedPeer {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	peers := make([]TrustedPeer, 0, len(s.peers))
+	for _, p := range s.peers {
+		peers = append(peers, p)
+	}
+	slices.SortFunc(peers, func(a, b TrustedPeer) int {
+		return a.PairedAt.Compare(b.PairedAt)
+	})
+	return peers
+}
+
+func (s *TrustStore) saveLocked() error {
+	peers := make([]TrustedPeer, 0, len(s.peers))
+	for _, p := range s.peers {
+		peers = append(peers, p)
+	}
+	return datadir.WriteJSON(s.path, peers)
+}
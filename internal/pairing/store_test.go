@@ -0,0 +1,91 @@
+package pairing
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/owenHochwald/clipp2p/internal/peertest"
+)
+
+func TestTrustStore_Persists(t *testing.T) {
+	dir := t.TempDir()
+	alice := peertest.NewID(t)
+	bob := peertest.NewID(t)
+
+	store, err := LoadTrustStore(dir)
+	assert.NoError(t, err)
+	assert.False(t, store.Trusted(alice))
+
+	now := time.Now()
+	assert.NoError(t, store.Add(TrustedPeer{ID: bob, Name: "Bob", PairedAt: now}))
+	assert.NoError(t, store.Add(TrustedPeer{ID: alice, Name: "Alice", PairedAt: now.Add(-time.Hour)}))
+
+	reloaded, err := LoadTrustStore(dir)
+	assert.NoError(t, err)
+	assert.True(t, reloaded.Trusted(alice))
+	assert.True(t, reloaded.Trusted(bob))
+
+	list := reloaded.List()
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "Alice", list[0].Name)
+		assert.Equal(t, "Bob", list[1].Name)
+	}
+
+	peertest.AssertPrivate(t, filepath.Join(dir, TrustFile))
+}
+
+func TestTrustStore_Remove(t *testing.T) {
+	dir := t.TempDir()
+	alice := peertest.NewID(t)
+
+	store, err := LoadTrustStore(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, store.Add(TrustedPeer{ID: alice}))
+
+	removed, err := store.Remove(alice)
+	assert.NoError(t, err)
+	assert.True(t, removed)
+
+	removed, err = store.Remove(alice)
+	assert.NoError(t, err)
+	assert.False(t, removed)
+
+	reloaded, err := LoadTrustStore(dir)
+	assert.NoError(t, err)
+	assert.False(t, reloaded.Trusted(alice))
+}
+
+func TestTrustStore_SeesOtherProcesses(t *testing.T) {
+	dir := t.TempDir()
+	alice := peertest.NewID(t)
+	bob := peertest.NewID(t)
+
+	running, err := LoadTrustStore(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, running.Add(TrustedPeer{ID: alice}))
+
+	// e.g. clipp2p trusted remove while the app runs
+	cli, err := LoadTrustStore(dir)
+	assert.NoError(t, err)
+	_, err = cli.Remove(alice)
+	assert.NoError(t, err)
+
+	// Adding another peer doesn't bring the removed one back
+	assert.NoError(t, running.Add(TrustedPeer{ID: bob}))
+	assert.False(t, running.Trusted(alice))
+	reloaded, err := LoadTrustStore(dir)
+	assert.NoError(t, err)
+	assert.False(t, reloaded.Trusted(alice))
+	assert.True(t, reloaded.Trusted(bob))
+
+	_, err = cli.Remove(bob)
+	assert.NoError(t, err)
+	removed, err := running.Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, []peer.ID{bob}, removed)
+	assert.False(t, running.Trusted(bob))
+}
@@ -0,0 +1,36 @@
+// Package peertest holds helpers shared by the tests of the stores kept in
+// the data directory.
+package peertest
+
+import (
+	"crypto/rand"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+// NewID returns the ID of a new random peer
+func NewID(t testing.TB) peer.ID {
+	t.Helper()
+	_, pub, err := crypto.GenerateEd25519Key(rand.Reader)
+	assert.NoError(t, err)
+	id, err := peer.IDFromPublicKey(pub)
+	assert.NoError(t, err)
+	return id
+}
+
+// AssertPrivate checks that a saved file is readable only by the user
+func AssertPrivate(t testing.TB, path string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		return
+	}
+	info, err := os.Stat(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+}
@@ -1,6 +1,8 @@
 package ui
 
 import (
+	"slices"
+	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -13,21 +15,171 @@ type ClipEntry struct {
 	Timestamp time.Time
 	IsLocal   bool
 	PeerName  string
+	Image     *ImageInfo
+	Selection string
+	// NotSynced is why the clip was kept local, empty if it was sent
+	NotSynced string
+	Redacted  bool
+	// ExpiresAt is when a received ephemeral clip leaves the clipboard
+	ExpiresAt time.Time
+	Expired   bool
+	// TTL is the lifetime requested for a sent ephemeral clip
+	TTL time.Duration
+	// ID identifies a sent clip, Deliveries are filled in once every
+	// peer acked it or gave up
+	ID         string
+	Deliveries []Delivery
+	Pending    bool
+	// QueuedFor names the offline peers a sent clip waits for
+	QueuedFor []string
+	// Queued marks a received clip held for this device while it was
+	// offline, HistoryOnly one that wasn't written to the clipboard
+	Queued      bool
+	HistoryOnly bool
+}
+
+// delivered records a late delivery of a queued clip, replacing the
+// peer's earlier attempt
+func (e *ClipEntry) delivered(d Delivery) {
+	e.QueuedFor = slices.DeleteFunc(e.QueuedFor, func(name string) bool {
+		return name == d.PeerName
+	})
+	for i := range e.Deliveries {
+		if e.Deliveries[i].PeerName == d.PeerName {
+			e.Deliveries[i] = d
+			return
+		}
+	}
+	e.Deliveries = append(e.Deliveries, d)
+}
+
+// Delivery is how a sent clip fared with one peer
+type Delivery struct {
+	PeerName  string
+	Delivered bool
+	// Unacknowledged means the clip went to a peer that doesn't ack, so
+	// whether it arrived is unknown
+	Unacknowledged bool
+	// Status is delivered, rejected, timed out or failed
+	Status string
+	Reason string
+}
+
+// Live reports whether the entry is an ephemeral clip still in the clipboard
+func (e ClipEntry) Live() bool {
+	return !e.ExpiresAt.IsZero() && !e.Expired
+}
+
+// ImageInfo describes an image clip
+type ImageInfo struct {
+	Width  int
+	Height int
+	Size   int
+}
+
+// Transfer is a chunked clip transfer in progress
+type Transfer struct {
+	ID       string
+	PeerName string
+	Incoming bool
+	Bytes    int64
+	Total    int64
+}
+
+// PairingRequest is an unpaired device that tried to connect
+type PairingRequest struct {
+	ID   peer.ID
+	Seen time.Time
+}
+
+// PairingState is the pairing screen. The code is shown for other devices
+// to type, a code shown on a selected waiting device is typed in Input.
+type PairingState struct {
+	Code     string
+	Selected int
+	Input    string
+	Typing   bool
+	Status   string
+	Failed   bool
+}
+
+// KnownPeer is a peer synced with before
+type KnownPeer struct {
+	ID       peer.ID
+	Name     string
+	LastSeen time.Time
+}
+
+// AddPeerState is the screen for dialing a peer by multiaddr
+type AddPeerState struct {
+	Input  string
+	Status string
+	Failed bool
 }
 
 // PeerInfo is a connected peer
 type PeerInfo struct {
-	ID   peer.ID
-	Name string
+	ID      peer.ID
+	Name    string
+	OS      string
+	Version string
+	// Bootstrap is set for peers dialed at a configured or added address
+	// rather than found by mDNS
+	Bootstrap bool
+	// Transport is how the peer is connected, e.g. tcp, quic or relay
+	Transport string
+	Timing    PeerTiming
+}
+
+// PeerTiming is how clips sent to a peer fared
+type PeerTiming struct {
+	Delivered int
+	Failed    int
+	// Last and Average are how long delivered clips took to be acked
+	Last    time.Duration
+	Average time.Duration
 }
 
 type Model struct {
-	History    []ClipEntry
-	Peers      []PeerInfo
+	History []ClipEntry
+	Peers   []PeerInfo
+	// KnownPeers are peers synced with before, the ones not in Peers are
+	// shown as offline
+	KnownPeers []KnownPeer
 	SyncActive bool
 	MaxHistory int
 	PeerName   string
-	quitting   bool
+	Backend    string
+	// Fingerprint identifies this device's key, peers can compare it
+	Fingerprint string
+	// Group is the private group synced in, empty outside a group
+	Group     string
+	Transfers []Transfer
+	quitting  bool
+
+	// SecretPrompt is a clip waiting for the user to confirm it
+	SecretPrompt *SecretPromptMsg
+	// OnSecretDecision is called when the user answers a SecretPrompt
+	OnSecretDecision func(id uint64, send bool)
+
+	// Pairing is the pairing screen, nil when it isn't shown
+	Pairing *PairingState
+	// PairingRequests are unpaired devices waiting to pair, newest first
+	PairingRequests []PairingRequest
+	// OnPairingOpen and OnPairingClose are called when the pairing screen
+	// opens and closes, OnPair when a code is entered for a device
+	OnPairingOpen  func()
+	OnPairingClose func()
+	OnPair         func(id peer.ID, code string)
+
+	// AddPeer is the add peer screen, nil when it isn't shown
+	AddPeer *AddPeerState
+	// ListenAddrs are the addresses other devices can add this one by
+	ListenAddrs []string
+	// OnAddPeerOpen is called when the add peer screen opens, OnAddPeer
+	// with a multiaddr entered on it
+	OnAddPeerOpen func()
+	OnAddPeer     func(addr string)
 }
 
 type ClipReceivedMsg struct {
@@ -35,16 +187,127 @@ type ClipReceivedMsg struct {
 	Timestamp time.Time
 	PeerName  string
 	PeerID    peer.ID
+	Image     *ImageInfo
+	Selection string
+	ExpiresAt time.Time
+	// Queued is set for clips held for this device while it was offline,
+	// HistoryOnly when the clip was not written to the clipboard
+	Queued      bool
+	HistoryOnly bool
 }
 
 type ClipSentMsg struct {
 	Content   string
 	Timestamp time.Time
+	Image     *ImageInfo
+	Selection string
+	NotSynced string
+	Redacted  bool
+	TTL       time.Duration
+	// ID is set for clips broadcast to peers, see ClipDeliveredMsg
+	ID string
+}
+
+// ClipDeliveredMsg reports how a sent clip fared with each peer, and the
+// offline peers it was queued for
+type ClipDeliveredMsg struct {
+	ID         string
+	Deliveries []Delivery
+	QueuedFor  []string
+}
+
+// QueuedClipSentMsg reports a queued clip sent to a peer that reconnected
+type QueuedClipSentMsg struct {
+	ID       string
+	Delivery Delivery
+}
+
+// ClipExpiredMsg reports that the latest received clip of a selection was
+// removed from the clipboard
+type ClipExpiredMsg struct {
+	Selection string
+	Restored  bool
+}
+
+// ClipExpiryCancelledMsg reports that the clipboard of a selection changed
+// before the received clip expired
+type ClipExpiryCancelledMsg struct {
+	Selection string
+}
+
+// countdownTickMsg redraws expiry countdowns
+type countdownTickMsg struct{}
+
+// SecretPromptMsg asks the user whether a clip that looks like a secret
+// may be synced
+type SecretPromptMsg struct {
+	ID        uint64
+	Detectors []string
+	Preview   string
 }
 
+// TransferProgressMsg reports progress of a chunked transfer. The last
+// report has Done set, and Err if the transfer failed.
+type TransferProgressMsg struct {
+	ID       string
+	PeerName string
+	Incoming bool
+	Bytes    int64
+	Total    int64
+	Done     bool
+	Err      string
+}
+
+// PeerConnectedMsg adds a peer, or updates it when sent again with what
+// the peer announced about itself
 type PeerConnectedMsg struct {
-	ID   peer.ID
-	Name string
+	ID        peer.ID
+	Name      string
+	OS        string
+	Version   string
+	Bootstrap bool
+	Transport string
+}
+
+// KnownPeersMsg replaces the known peers, most recently seen first
+type KnownPeersMsg struct {
+	Peers []KnownPeer
+}
+
+// ListenAddrsMsg replaces the addresses this device can be added by
+type ListenAddrsMsg struct {
+	Addrs []string
+}
+
+// AddPeerResultMsg reports whether an added address was accepted, Err is
+// empty if it is being dialed
+type AddPeerResultMsg struct {
+	Addr string
+	Err  string
+}
+
+// PairingCodeMsg shows the code other devices type to pair, empty once
+// pairing closed
+type PairingCodeMsg struct {
+	Code string
+}
+
+// PairingRequestsMsg replaces the list of devices waiting to pair
+type PairingRequestsMsg struct {
+	Requests []PairingRequest
+}
+
+// PairingResultMsg reports a pairing attempt, Err is empty on success
+type PairingResultMsg struct {
+	PeerID peer.ID
+	Name   string
+	Err    string
+}
+
+// PeerTimingMsg updates the timing of clips sent to a peer
+type PeerTimingMsg struct {
+	ID     peer.ID
+	Timing PeerTiming
 }
 
 type PeerDisconnectedMsg struct {
@@ -77,6 +340,12 @@ func (m Model) Init() tea.Cmd {
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.Pairing != nil {
+			return m.updatePairing(msg)
+		}
+		if m.AddPeer != nil {
+			return m.updateAddPeer(msg)
+		}
 		switch msg.String() {
 		case "q", "ctrl+c":
 			m.quitting = true
@@ -87,19 +356,99 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "c":
 			m.History = make([]ClipEntry, 0)
 			return m, nil
+		case "a":
+			m.AddPeer = &AddPeerState{}
+			if m.OnAddPeerOpen != nil {
+				m.OnAddPeerOpen()
+			}
+			return m, nil
+		case "p":
+			// Without pairing there is no code to wait for
+			if m.OnPairingOpen == nil {
+				return m, nil
+			}
+			m.Pairing = &PairingState{Status: "Waiting for a code..."}
+			m.OnPairingOpen()
+			return m, nil
+		case "y", "n":
+			if m.SecretPrompt != nil {
+				if m.OnSecretDecision != nil {
+					m.OnSecretDecision(m.SecretPrompt.ID, msg.String() == "y")
+				}
+				m.SecretPrompt = nil
+			}
+			return m, nil
 		}
 
+	case SecretPromptMsg:
+		m.SecretPrompt = &msg
+		return m, nil
+
 	case ClipReceivedMsg:
 		entry := ClipEntry{
-			Content:   msg.Content,
-			Timestamp: msg.Timestamp,
-			IsLocal:   false,
-			PeerName:  msg.PeerName,
+			Content:     msg.Content,
+			Timestamp:   msg.Timestamp,
+			IsLocal:     false,
+			PeerName:    msg.PeerName,
+			Image:       msg.Image,
+			Selection:   msg.Selection,
+			ExpiresAt:   msg.ExpiresAt,
+			Queued:      msg.Queued,
+			HistoryOnly: msg.HistoryOnly,
 		}
 		m.History = append(m.History, entry)
 		if len(m.History) > m.MaxHistory {
 			m.History = m.History[1:]
 		}
+		if entry.Live() {
+			return m, countdownTick()
+		}
+		return m, nil
+
+	case ClipExpiredMsg:
+		m.endCountdown(msg.Selection, true)
+		return m, nil
+
+	case ClipExpiryCancelledMsg:
+		m.endCountdown(msg.Selection, false)
+		return m, nil
+
+	case countdownTickMsg:
+		if m.hasLiveClips() {
+			return m, countdownTick()
+		}
+		return m, nil
+
+	case TransferProgressMsg:
+		m.updateTransfer(msg)
+		return m, nil
+
+	case PairingCodeMsg:
+		if m.Pairing != nil {
+			m.Pairing.Code = msg.Code
+			if msg.Code == "" {
+				// Pairing closed on its own, e.g. the window ran out
+				m.Pairing = nil
+			}
+		}
+		return m, nil
+
+	case PairingRequestsMsg:
+		m.PairingRequests = msg.Requests
+		if m.Pairing != nil && m.Pairing.Selected >= len(msg.Requests) {
+			m.Pairing.Selected = max(len(msg.Requests)-1, 0)
+		}
+		return m, nil
+
+	case PairingResultMsg:
+		if m.Pairing != nil {
+			m.Pairing.Failed = msg.Err != ""
+			if msg.Err != "" {
+				m.Pairing.Status = "Pairing failed: " + msg.Err
+			} else {
+				m.Pairing.Status = "Paired with " + pairingName(msg.PeerID, msg.Name)
+			}
+		}
 		return m, nil
 
 	case ClipSentMsg:
@@ -108,6 +457,13 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			Timestamp: msg.Timestamp,
 			IsLocal:   true,
 			PeerName:  m.PeerName,
+			Image:     msg.Image,
+			Selection: msg.Selection,
+			NotSynced: msg.NotSynced,
+			Redacted:  msg.Redacted,
+			TTL:       msg.TTL,
+			ID:        msg.ID,
+			Pending:   msg.ID != "",
 		}
 		m.History = append(m.History, entry)
 		if len(m.History) > m.MaxHistory {
@@ -115,17 +471,70 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case ClipDeliveredMsg:
+		for i := range m.History {
+			if m.History[i].ID == msg.ID {
+				m.History[i].Deliveries = msg.Deliveries
+				m.History[i].QueuedFor = msg.QueuedFor
+				m.History[i].Pending = false
+			}
+		}
+		return m, nil
+
+	case QueuedClipSentMsg:
+		for i := range m.History {
+			if m.History[i].ID == msg.ID {
+				m.History[i].delivered(msg.Delivery)
+			}
+		}
+		return m, nil
+
+	case AddPeerResultMsg:
+		if m.AddPeer != nil {
+			m.AddPeer.Failed = msg.Err != ""
+			if msg.Err != "" {
+				m.AddPeer.Status = "Can't add peer: " + msg.Err
+			} else {
+				m.AddPeer.Status = "Dialing " + msg.Addr
+			}
+		}
+		return m, nil
+
 	case PeerConnectedMsg:
+		info := PeerInfo{
+			ID:        msg.ID,
+			Name:      msg.Name,
+			OS:        msg.OS,
+			Version:   msg.Version,
+			Bootstrap: msg.Bootstrap,
+			Transport: msg.Transport,
+		}
 		for i, p := range m.Peers {
 			if p.ID == msg.ID {
-				m.Peers[i].Name = msg.Name
+				info.Bootstrap = info.Bootstrap || p.Bootstrap
+				if info.OS == "" {
+					info.OS = p.OS
+				}
+				if info.Version == "" {
+					info.Version = p.Version
+				}
+				if info.Transport == "" {
+					info.Transport = p.Transport
+				}
+				info.Timing = p.Timing
+				m.Peers[i] = info
 				return m, nil
 			}
 		}
-		m.Peers = append(m.Peers, PeerInfo{
-			ID:   msg.ID,
-			Name: msg.Name,
-		})
+		m.Peers = append(m.Peers, info)
+		return m, nil
+
+	case PeerTimingMsg:
+		for i := range m.Peers {
+			if m.Peers[i].ID == msg.ID {
+				m.Peers[i].Timing = msg.Timing
+			}
+		}
 		return m, nil
 
 	case PeerDisconnectedMsg:
@@ -137,6 +546,14 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case ListenAddrsMsg:
+		m.ListenAddrs = msg.Addrs
+		return m, nil
+
+	case KnownPeersMsg:
+		m.KnownPeers = msg.Peers
+		return m, nil
+
 	case PeersUpdatedMsg:
 		m.Peers = msg.Peers
 		return m, nil
@@ -153,6 +570,153 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// updatePairing handles keys while the pairing screen is shown
+func (m Model) updatePairing(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := *m.Pairing
+	m.Pairing = &p
+	key := msg.String()
+
+	if p.Typing {
+		switch key {
+		case "esc":
+			p.Typing = false
+			p.Input = ""
+		case "backspace":
+			if len(p.Input) > 0 {
+				p.Input = p.Input[:len(p.Input)-1]
+			}
+		case "enter":
+			if p.Selected < len(m.PairingRequests) && m.OnPair != nil {
+				id := m.PairingRequests[p.Selected].ID
+				m.OnPair(id, p.Input)
+				p.Status = "Pairing with " + pairingName(id, "") + "..."
+				p.Failed = false
+			}
+			p.Typing = false
+			p.Input = ""
+		default:
+			if len(key) == 1 && (key[0] >= '0' && key[0] <= '9' || key == " " || key == "-") {
+				p.Input += key
+			}
+		}
+		return m, nil
+	}
+
+	switch key {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc", "p", "q":
+		m.Pairing = nil
+		if m.OnPairingClose != nil {
+			m.OnPairingClose()
+		}
+	case "up", "k":
+		if p.Selected > 0 {
+			p.Selected--
+		}
+	case "down", "j":
+		if p.Selected < len(m.PairingRequests)-1 {
+			p.Selected++
+		}
+	case "enter":
+		if p.Selected < len(m.PairingRequests) {
+			p.Typing = true
+		}
+	}
+	return m, nil
+}
+
+// updateAddPeer handles keys while the add peer screen is shown
+func (m Model) updateAddPeer(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	a := *m.AddPeer
+	m.AddPeer = &a
+
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		m.quitting = true
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.AddPeer = nil
+	case tea.KeyBackspace:
+		if len(a.Input) > 0 {
+			a.Input = a.Input[:len(a.Input)-1]
+		}
+	case tea.KeyEnter:
+		if a.Input != "" && m.OnAddPeer != nil {
+			m.OnAddPeer(strings.TrimSpace(a.Input))
+			a.Input = ""
+		}
+	case tea.KeyRunes:
+		// Pasted addresses arrive as one message
+		a.Input += strings.TrimSpace(string(msg.Runes))
+	}
+	return m, nil
+}
+
+// pairingName is how a device is called on the pairing screen
+func pairingName(id peer.ID, name string) string {
+	if name != "" {
+		return name
+	}
+	return shortID(id)
+}
+
+// countdownTick schedules the next countdown redraw
+func countdownTick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return countdownTickMsg{}
+	})
+}
+
+// hasLiveClips reports whether any countdown is still running
+func (m Model) hasLiveClips() bool {
+	for _, e := range m.History {
+		if e.Live() {
+			return true
+		}
+	}
+	return false
+}
+
+// endCountdown stops the countdowns of a selection, marking them expired
+// or leaving them as regular entries when the clipboard moved on
+func (m *Model) endCountdown(selection string, expired bool) {
+	for i := range m.History {
+		if m.History[i].Live() && m.History[i].Selection == selection {
+			if expired {
+				m.History[i].Expired = true
+			} else {
+				m.History[i].ExpiresAt = time.Time{}
+			}
+		}
+	}
+}
+
+// updateTransfer tracks a transfer until its final report
+func (m *Model) updateTransfer(msg TransferProgressMsg) {
+	for i, t := range m.Transfers {
+		if t.ID == msg.ID && t.Incoming == msg.Incoming {
+			if msg.Done {
+				m.Transfers = append(m.Transfers[:i], m.Transfers[i+1:]...)
+			} else {
+				m.Transfers[i].Bytes = msg.Bytes
+			}
+			return
+		}
+	}
+	if msg.Done {
+		return
+	}
+	m.Transfers = append(m.Transfers, Transfer{
+		ID:       msg.ID,
+		PeerName: msg.PeerName,
+		Incoming: msg.Incoming,
+		Bytes:    msg.Bytes,
+		Total:    msg.Total,
+	})
+}
+
 // IsQuitting returns whether the user has requested to quit
 func (m Model) IsQuitting() bool {
 	return m.quitting
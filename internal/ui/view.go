@@ -3,11 +3,14 @@ package ui
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
-)
+	"github.com/libp2p/go-libp2p/core/peer"
 
-const version = "v1.0"
+	"github.com/owenHochwald/clipp2p/internal/clipboard"
+	"github.com/owenHochwald/clipp2p/internal/p2p"
+)
 
 var (
 	primaryColor   = lipgloss.Color("#00FF00") // Green
@@ -35,6 +38,9 @@ var (
 	timestampStyle = lipgloss.NewStyle().
 			Foreground(dimColor)
 
+	infoStyle = lipgloss.NewStyle().
+			Foreground(dimColor)
+
 	localTagStyle = lipgloss.NewStyle().
 			Foreground(localColor).
 			Bold(true)
@@ -43,6 +49,12 @@ var (
 			Foreground(remoteColor).
 			Bold(true)
 
+	selectionTagStyle = lipgloss.NewStyle().
+				Foreground(secondaryColor)
+
+	warningStyle = lipgloss.NewStyle().
+			Foreground(localColor)
+
 	contentStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#FFFFFF"))
 
@@ -70,8 +82,20 @@ func (m Model) View() string {
 	var b strings.Builder
 
 	// Title
-	title := titleStyle.Render(fmt.Sprintf("CLIP-P2P [%s]", version))
+	title := titleStyle.Render(fmt.Sprintf("CLIP-P2P [v%s]", p2p.Version))
 	b.WriteString(title)
+	if m.Backend != "" {
+		b.WriteString("  ")
+		b.WriteString(infoStyle.Render("clipboard: " + m.Backend))
+	}
+	if m.Fingerprint != "" {
+		b.WriteString("  ")
+		b.WriteString(infoStyle.Render("id: " + m.Fingerprint))
+	}
+	if m.Group != "" {
+		b.WriteString("  ")
+		b.WriteString(selectionTagStyle.Render("group: " + m.Group))
+	}
 	b.WriteString("\n")
 
 	// Divider
@@ -81,10 +105,30 @@ func (m Model) View() string {
 
 	// Connection status
 	b.WriteString(m.renderStatus())
-	b.WriteString("\n\n")
+	b.WriteString("\n")
+	if offline := m.renderOffline(); offline != "" {
+		b.WriteString(offline)
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	if len(m.Transfers) > 0 {
+		b.WriteString(m.renderTransfers())
+	}
+
+	// The pairing screen takes the place of the history
+	if m.Pairing != nil {
+		b.WriteString(m.renderPairing())
+	} else if m.AddPeer != nil {
+		b.WriteString(m.renderAddPeer())
+	} else {
+		b.WriteString(m.renderHistory())
+	}
 
-	// History section
-	b.WriteString(m.renderHistory())
+	if m.SecretPrompt != nil {
+		b.WriteString(m.renderSecretPrompt())
+		b.WriteString("\n\n")
+	}
 
 	// Footer divider
 	b.WriteString(divider)
@@ -126,13 +170,18 @@ func (m Model) getPeerNames() string {
 	for _, p := range m.Peers {
 		name := p.Name
 		if name == "" {
-			idStr := p.ID.String()
-			if len(idStr) > 8 {
-				name = idStr[:8] + "..."
-			} else {
-				name = idStr
-			}
+			name = shortID(p.ID)
+		}
+		if p.OS != "" {
+			name += " (" + p.OS + ")"
+		}
+		if p.Transport != "" {
+			name += " via " + p.Transport
 		}
+		if p.Bootstrap {
+			name += " [bootstrap]"
+		}
+		name += formatTiming(p.Timing)
 		names = append(names, name)
 	}
 
@@ -143,6 +192,153 @@ func (m Model) getPeerNames() string {
 	return strings.Join(names, ", ")
 }
 
+// formatTiming shows how long a peer takes to ack clips, empty before the
+// first clip
+func formatTiming(t PeerTiming) string {
+	var s string
+	if t.Delivered > 0 {
+		s = fmt.Sprintf(" ack %s (avg %s)", formatLatency(t.Last), formatLatency(t.Average))
+	}
+	if t.Failed > 0 {
+		s += fmt.Sprintf(" %d failed", t.Failed)
+	}
+	return s
+}
+
+// formatLatency rounds a duration to what is worth reading
+func formatLatency(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
+
+// renderOffline lists known peers that aren't connected, empty if none
+func (m Model) renderOffline() string {
+	connected := make(map[peer.ID]bool, len(m.Peers))
+	for _, p := range m.Peers {
+		connected[p.ID] = true
+	}
+
+	var names []string
+	for _, k := range m.KnownPeers {
+		if connected[k.ID] {
+			continue
+		}
+		name := k.Name
+		if name == "" {
+			name = shortID(k.ID)
+		}
+		names = append(names, fmt.Sprintf("%s (seen %s)", name, formatAgo(time.Since(k.LastSeen))))
+	}
+	if len(names) == 0 {
+		return ""
+	}
+
+	// Limit display to the 3 most recently seen
+	text := strings.Join(names[:min(len(names), 3)], ", ")
+	if len(names) > 3 {
+		text += fmt.Sprintf(" +%d more", len(names)-3)
+	}
+	return infoStyle.Render("[-] KNOWN, OFFLINE: " + text)
+}
+
+// formatAgo rounds a duration for "seen ... ago"
+func formatAgo(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
+
+// shortID abbreviates a peer ID for display
+func shortID(id peer.ID) string {
+	idStr := id.String()
+	if len(idStr) > 8 {
+		return idStr[:8] + "..."
+	}
+	return idStr
+}
+
+func (m Model) renderPairing() string {
+	p := m.Pairing
+	var b strings.Builder
+
+	b.WriteString("PAIRING:\n")
+	code := p.Code
+	if code == "" {
+		code = "..."
+	}
+	b.WriteString("  Type this code on the other device: ")
+	b.WriteString(titleStyle.Render(code))
+	b.WriteString("\n\n")
+
+	b.WriteString("  Devices waiting to pair:\n")
+	if len(m.PairingRequests) == 0 {
+		b.WriteString(infoStyle.Render("    None yet, open pairing on the other device"))
+		b.WriteString("\n")
+	}
+	for i, r := range m.PairingRequests {
+		cursor := "  "
+		if i == p.Selected {
+			cursor = keyStyle.Render("> ")
+		}
+		seen := timestampStyle.Render("seen " + r.Seen.Format("3:04 PM"))
+		b.WriteString(fmt.Sprintf("  %s%s  %s\n", cursor, contentStyle.Render(r.ID.String()), seen))
+	}
+	b.WriteString("\n")
+
+	if p.Typing {
+		b.WriteString("  Code shown on that device: ")
+		b.WriteString(contentStyle.Render(p.Input + "_"))
+		b.WriteString("\n\n")
+	}
+
+	if p.Status != "" {
+		style := infoStyle
+		if p.Failed {
+			style = warningStyle
+		}
+		b.WriteString("  " + style.Render(p.Status))
+		b.WriteString("\n\n")
+	}
+	return b.String()
+}
+
+func (m Model) renderAddPeer() string {
+	a := m.AddPeer
+	var b strings.Builder
+
+	b.WriteString("ADD PEER:\n")
+	if len(m.ListenAddrs) > 0 {
+		b.WriteString("  Other devices can add this one at:\n")
+		for _, addr := range m.ListenAddrs {
+			b.WriteString("    " + infoStyle.Render(addr) + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("  Address: ")
+	b.WriteString(contentStyle.Render(a.Input + "_"))
+	b.WriteString("\n\n")
+
+	if a.Status != "" {
+		style := infoStyle
+		if a.Failed {
+			style = warningStyle
+		}
+		b.WriteString("  " + style.Render(a.Status))
+		b.WriteString("\n\n")
+	}
+	return b.String()
+}
+
 func (m Model) renderHistory() string {
 	var b strings.Builder
 
@@ -182,11 +378,139 @@ func (m Model) renderHistoryEntry(entry ClipEntry) string {
 		tag = remoteTagStyle.Render("[Remote]")
 	}
 
+	// Only the non-default selection is labelled to keep entries short
+	if entry.Selection == clipboard.SelectionPrimary.String() {
+		tag += " " + selectionTagStyle.Render("[PRIMARY]")
+	}
+
 	// Content
-	content := truncateContent(entry.Content, 35)
+	var content string
+	if entry.Image != nil {
+		content = formatImage(*entry.Image)
+	} else {
+		content = truncateContent(entry.Content, 35)
+	}
 	contentRendered := contentStyle.Render(content)
 
-	return fmt.Sprintf("  %s  %s  %s", ts, tag, contentRendered)
+	line := fmt.Sprintf("  %s  %s  %s", ts, tag, contentRendered)
+	if entry.NotSynced != "" {
+		line += "  " + warningStyle.Render(fmt.Sprintf("not synced (%s)", entry.NotSynced))
+	} else if entry.Redacted {
+		line += "  " + warningStyle.Render("(redacted)")
+	}
+	line += renderDelivery(entry)
+	if entry.Queued {
+		label := "(queued while offline)"
+		if entry.HistoryOnly {
+			label = "(queued while offline, not copied)"
+		}
+		line += "  " + infoStyle.Render(label)
+	}
+
+	switch {
+	case entry.Live():
+		remaining := time.Until(entry.ExpiresAt).Round(time.Second)
+		if remaining < 0 {
+			remaining = 0
+		}
+		line += "  " + warningStyle.Render(fmt.Sprintf("expires in %s", remaining))
+	case entry.Expired:
+		line += "  " + infoStyle.Render("(expired)")
+	case entry.TTL > 0:
+		line += "  " + infoStyle.Render(fmt.Sprintf("(ephemeral %s)", entry.TTL))
+	}
+
+	// Peers that didn't get the clip are listed under it
+	for _, d := range entry.Deliveries {
+		if d.Delivered {
+			continue
+		}
+		detail := fmt.Sprintf("%s: %s", d.PeerName, d.Status)
+		if d.Reason != "" {
+			detail += " (" + d.Reason + ")"
+		}
+		style := warningStyle
+		if d.Unacknowledged {
+			style = infoStyle
+		}
+		line += "\n" + "            " + style.Render(detail)
+	}
+	return line
+}
+
+// renderDelivery summarizes how many peers acked a sent clip
+func renderDelivery(entry ClipEntry) string {
+	if entry.Pending {
+		return "  " + infoStyle.Render("sending...")
+	}
+
+	var queued string
+	if len(entry.QueuedFor) > 0 {
+		queued = "  " + infoStyle.Render("queued for "+strings.Join(entry.QueuedFor, ", "))
+	}
+	if len(entry.Deliveries) == 0 {
+		return queued
+	}
+
+	delivered, unacked := 0, 0
+	for _, d := range entry.Deliveries {
+		switch {
+		case d.Delivered:
+			delivered++
+		case d.Unacknowledged:
+			unacked++
+		}
+	}
+	summary := fmt.Sprintf("delivered to %d/%d", delivered, len(entry.Deliveries))
+	if unacked > 0 {
+		summary += fmt.Sprintf(", %d sent without ack", unacked)
+	}
+	switch {
+	case delivered+unacked < len(entry.Deliveries):
+		return "  " + warningStyle.Render(summary) + queued
+	case unacked > 0:
+		return "  " + infoStyle.Render(summary) + queued
+	default:
+		return "  " + connectedStyle.Render(summary) + queued
+	}
+}
+
+func (m Model) renderTransfers() string {
+	var b strings.Builder
+
+	b.WriteString("TRANSFERS:\n")
+	for _, t := range m.Transfers {
+		direction := "to"
+		if t.Incoming {
+			direction = "from"
+		}
+		label := infoStyle.Render(fmt.Sprintf("%s %s", direction, t.PeerName))
+		size := fmt.Sprintf("%s / %s", formatBytes(int(t.Bytes)), formatBytes(int(t.Total)))
+		b.WriteString(fmt.Sprintf("  %s  %s  %s\n", renderProgressBar(t.Bytes, t.Total, 20), size, label))
+	}
+
+	b.WriteString("\n")
+	return b.String()
+}
+
+// renderProgressBar draws a bar of width cells followed by the percentage
+func renderProgressBar(done, total int64, width int) string {
+	var ratio float64
+	if total > 0 {
+		ratio = min(float64(done)/float64(total), 1)
+	}
+	filled := int(ratio * float64(width))
+	bar := connectedStyle.Render(strings.Repeat("█", filled)) +
+		dividerStyle.Render(strings.Repeat("░", width-filled))
+	return fmt.Sprintf("%s %3d%%", bar, int(ratio*100))
+}
+
+func (m Model) renderSecretPrompt() string {
+	p := m.SecretPrompt
+	warning := warningStyle.Render(fmt.Sprintf("[!] Possible secret (%s): ", strings.Join(p.Detectors, ", ")))
+	preview := contentStyle.Render(truncateContent(p.Preview, 35))
+	keys := keyStyle.Render("(y)") + " Send anyway  " + keyStyle.Render("(n)") + " Keep local"
+	return warning + preview + "\n    " + footerStyle.Render(keys)
 }
 
 func truncateContent(content string, maxLen int) string {
@@ -201,7 +525,36 @@ func truncateContent(content string, maxLen int) string {
 	return content
 }
 
+func formatImage(img ImageInfo) string {
+	if img.Width == 0 || img.Height == 0 {
+		return fmt.Sprintf("[Image, %s]", formatBytes(img.Size))
+	}
+	return fmt.Sprintf("[Image %dx%d, %s]", img.Width, img.Height, formatBytes(img.Size))
+}
+
+func formatBytes(n int) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := unit, 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
+
 func (m Model) renderFooter() string {
+	if m.Pairing != nil {
+		return m.renderPairingFooter()
+	}
+	if m.AddPeer != nil {
+		add := keyStyle.Render("(enter)") + " Add"
+		back := keyStyle.Render("(esc)") + " Done"
+		return footerStyle.Render(fmt.Sprintf("%s  %s", add, back))
+	}
+
 	quit := keyStyle.Render("(q)") + " Quit"
 	toggle := keyStyle.Render("(s)") + " Toggle Sync "
 
@@ -214,5 +567,28 @@ func (m Model) renderFooter() string {
 
 	clear := keyStyle.Render("(c)") + " Clear History"
 
-	return footerStyle.Render(fmt.Sprintf("%s  %s%s  %s", quit, toggle, syncStatus, clear))
+	// Pairing is only offered when it is required
+	var pair string
+	if m.OnPairingOpen != nil {
+		pair = "  " + keyStyle.Render("(p)") + " Pair"
+		if n := len(m.PairingRequests); n > 0 {
+			pair += warningStyle.Render(fmt.Sprintf(" [%d waiting]", n))
+		}
+	}
+
+	add := keyStyle.Render("(a)") + " Add Peer"
+
+	return footerStyle.Render(fmt.Sprintf("%s  %s%s  %s%s  %s", quit, toggle, syncStatus, clear, pair, add))
+}
+
+func (m Model) renderPairingFooter() string {
+	if m.Pairing.Typing {
+		submit := keyStyle.Render("(enter)") + " Pair"
+		cancel := keyStyle.Render("(esc)") + " Cancel"
+		return footerStyle.Render(fmt.Sprintf("%s  %s", submit, cancel))
+	}
+	move := keyStyle.Render("(↑/↓)") + " Select"
+	enter := keyStyle.Render("(enter)") + " Enter Their Code"
+	back := keyStyle.Render("(esc)") + " Done"
+	return footerStyle.Render(fmt.Sprintf("%s  %s  %s", move, enter, back))
 }